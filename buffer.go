@@ -1,9 +1,12 @@
-package main
+package simpledb
 
 import (
+	"container/list"
+	"context"
 	"errors"
 	"simpledb/log"
 	"simpledb/storage"
+	"sync"
 	"time"
 )
 
@@ -16,116 +19,357 @@ var (
 	ErrBufferFull    = errors.New("no available buffer")
 )
 
+// BufferManager is safe for concurrent use. A single mutex guards the page table,
+// the pin counts and the replacement policy.
 type BufferManager struct {
-	Available int
+	mu        sync.Mutex
+	available int
 	pool      []*Buffer
-	final     int
-	fm        storage.FileManager
-	lm        *log.LogManager
-	count     int
+	// pages maps each block in the pool to its buffer
+	pages  map[storage.Block]*Buffer
+	final  int
+	fm     storage.FileManager
+	lm     *log.LogManager
+	count  int
+	policy ReplacementPolicy
+	// free holds the frames which hold no block, in pool order
+	free          []int
+	scanResistant bool
+	// lastPinned is the number of the block of each file pinned last, to detect sequential scans
+	lastPinned map[string]int
+	// waiters queues the pins waiting for a buffer, the oldest first
+	waiters        *list.List
+	writerInterval time.Duration
+	writerMaxPages int
+	writer         *backgroundWriter
 }
 
 type BufferManagerOptions func(bm *BufferManager)
 
+// WithFinalizeTime sets how long Pin waits for a buffer before it returns ErrBufferFull
 func WithFinalizeTime(ms int) BufferManagerOptions {
 	return func(bm *BufferManager) {
 		bm.final = ms
 	}
 }
 
+// WithReplacementPolicy sets the policy choosing the buffers to replace, LRUPolicy by default,
+// whose operations take constant time whatever the size of the pool
+func WithReplacementPolicy(policy ReplacementPolicy) BufferManagerOptions {
+	return func(bm *BufferManager) {
+		bm.policy = policy
+	}
+}
+
+// WithScanResistance makes the replacement policy replace first the buffers read by sequential scans,
+// so that a large scan does not push the frequently used blocks out of the pool.
+// A pin of the block following the block of the same file pinned last counts as sequential.
+func WithScanResistance() BufferManagerOptions {
+	return func(bm *BufferManager) {
+		bm.scanResistant = true
+	}
+}
+
 func NewBufferManager(fm storage.FileManager, lm *log.LogManager, bufCnt int, opts ...BufferManagerOptions) *BufferManager {
 	pool := make([]*Buffer, bufCnt)
+	free := make([]int, bufCnt)
 	for i := 0; i < bufCnt; i++ {
 		pool[i] = NewBuffer(fm, lm)
+		pool[i].frame = i
+		free[i] = i
 	}
 
 	mng := &BufferManager{
-		Available: bufCnt,
-		pool:      pool,
-		final:     FinalizeTimeMs,
-		fm:        fm,
-		lm:        lm,
-		count:     bufCnt,
+		available:  bufCnt,
+		pool:       pool,
+		pages:      make(map[storage.Block]*Buffer, bufCnt),
+		final:      FinalizeTimeMs,
+		fm:         fm,
+		lm:         lm,
+		count:      bufCnt,
+		policy:     NewLRUPolicy(),
+		free:       free,
+		lastPinned: make(map[string]int),
+		waiters:    list.New(),
 	}
 
 	for _, opt := range opts {
 		opt(mng)
 	}
+	if mng.writerInterval > 0 && mng.writerMaxPages > 0 {
+		mng.writer = newBackgroundWriter(mng, mng.writerInterval, mng.writerMaxPages)
+	}
 
 	return mng
 }
 
+// Available returns the number of unpinned buffers
+func (bm *BufferManager) Available() int {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.available
+}
+
+// GetBuf returns the buffer holding the block, unless the block is not in the pool or still being read
 func (bm *BufferManager) GetBuf(block *storage.Block) (*Buffer, error) {
-	for _, buf := range bm.pool {
-		if buf.block != nil && buf.block.Equals(block) {
-			return buf, nil
-		}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if buf, found := bm.pages[*block]; found && buf.latch == nil {
+		return buf, nil
 	}
 	return nil, ErrBlockNotFound
 }
 
-func (bm *BufferManager) FlushAll(txnum int) {
+// FlushAll writes the buffers modified by the transaction.
+// The buffers are collected under the lock and written outside it.
+func (bm *BufferManager) FlushAll(txnum int) error {
+	bm.mu.Lock()
+	bufs := []*Buffer{}
 	for _, buf := range bm.pool {
 		if buf.ModifyingTx() == txnum {
-			buf.Flush()
+			bufs = append(bufs, buf)
+		}
+	}
+	bm.mu.Unlock()
+
+	for _, buf := range bufs {
+		// a buffer replaced in the meantime has been written by its replacement
+		err := buf.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard empties the buffers holding blocks of the file without writing them, before the file is removed.
+// The buffers which are pinned, or whose I/O is pending, keep their blocks.
+func (bm *BufferManager) Discard(filename string) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	for _, buf := range bm.pool {
+		if buf.block == nil || buf.block.Filename != filename || buf.IsPinned() || buf.latch != nil {
+			continue
 		}
+		// the frame leaves the policy for the free frames
+		bm.policy.Pinned(buf.frame)
+		delete(bm.pages, *buf.block)
+		buf.SetModified(-1, -1)
+		buf.setBlock(nil)
+		bm.free = append(bm.free, buf.frame)
 	}
 }
 
 // Pin 指定したblockをbufferに読み込む
 func (bm *BufferManager) Pin(block *storage.Block) (*Buffer, error) {
-	// check if the block is already in the buffer pool
-	for _, bp := range bm.pool {
-		if bp.block != nil && bp.block.Equals(block) {
-			if bp.IsPinned() {
-				// allocate another buffer
-				return bp, nil
-			} else {
-				// repin and return the buffer
-				bp.Pin()
-				return bp, nil
+	return bm.PinContext(context.Background(), block)
+}
+
+// PinContext pins the block, waiting until a buffer is unpinned if every buffer is pinned.
+// The waiting pins are served in arrival order. It returns ErrBufferFull once it has waited
+// for the finalize time, and the error of the context if the context is done first.
+// The block is read, and the block it replaces written, without holding up the other pins.
+func (bm *BufferManager) PinContext(ctx context.Context, block *storage.Block) (*Buffer, error) {
+	ctx, cancel := context.WithTimeoutCause(ctx, time.Duration(bm.final)*time.Millisecond, ErrBufferFull)
+	defer cancel()
+
+	bm.mu.Lock()
+	sequential := bm.sequential(block)
+
+	var waiter *list.Element
+	for {
+		var wake <-chan struct{}
+		if buf, found := bm.pages[*block]; found && buf.latch != nil {
+			// the block is being read into, or written from, a buffer
+			wake = buf.latch
+		} else if waiter == bm.waiters.Front() || !bm.needsBuffer(block) {
+			// a pin which needs a buffer queues behind the pins already waiting for one,
+			// so only the oldest waiter, or a new pin if none waits, may take a buffer
+			buf, assigned := bm.tryPin(block, sequential)
+			if buf != nil {
+				if waiter != nil {
+					bm.waiters.Remove(waiter)
+					// leave the remaining buffers to the next waiter
+					bm.wakeNext()
+				}
+				bm.mu.Unlock()
+				if assigned {
+					return bm.assign(buf, block)
+				}
+				return buf, nil
 			}
 		}
-	}
+		if wake == nil {
+			if waiter == nil {
+				waiter = bm.waiters.PushBack(make(chan struct{}, 1))
+			}
+			wake = waiter.Value.(chan struct{})
+		}
 
-	// check if the block is not in the buffer pool
-	remain := bm.final
-	for {
-		if remain < 0 {
-			// buffer pool is full, return error
-			return nil, ErrBufferFull
+		bm.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
 		}
+		bm.mu.Lock()
 
-		// check if there is an unpinned buffer
-		// this is naive implementation
-		for _, buf := range bm.pool {
-			if !buf.IsPinned() {
-				buf.Flush()
-				buf.block = block
-				buf.Pin()
-				return buf, nil
+		if ctx.Err() != nil {
+			if waiter != nil {
+				bm.waiters.Remove(waiter)
+				// the waiter may have been woken before it gave up
+				bm.wakeNext()
 			}
+			bm.mu.Unlock()
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+// needsBuffer returns true unless the block is in a pinned buffer
+func (bm *BufferManager) needsBuffer(block *storage.Block) bool {
+	buf, found := bm.pages[*block]
+	return !found || !buf.IsPinned()
+}
+
+// wakeNext wakes the oldest waiting pin if a buffer is unpinned
+func (bm *BufferManager) wakeNext() {
+	front := bm.waiters.Front()
+	if front == nil || bm.available == 0 {
+		return
+	}
+	select {
+	case front.Value.(chan struct{}) <- struct{}{}:
+	default:
+		// the waiter has already been woken
+	}
+}
+
+// tryPin pins the block if it is in the pool. Otherwise it reserves a buffer for the block,
+// replacing the block of another buffer if needed, and returns true: the caller then assigns
+// the block to the buffer with assign. It returns nil if every buffer is pinned.
+// The caller holds the lock.
+func (bm *BufferManager) tryPin(block *storage.Block, sequential bool) (*Buffer, bool) {
+	// check if the block is already in the buffer pool
+	if bp, found := bm.pages[*block]; found {
+		bp.sequential = sequential
+		bm.policy.Pinned(bp.frame)
+		if !bp.IsPinned() {
+			bm.available--
+		}
+		bp.Pin()
+		return bp, false
+	}
+
+	// use a frame which holds no block, then ask the policy for a victim
+	buf := bm.unusedBuffer()
+	if buf == nil {
+		frame, ok := bm.policy.Victim()
+		if !ok {
+			return nil, false
+		}
+		buf = bm.pool[frame]
+	}
+	// both blocks stay in the page table until the I/O is done,
+	// so that their pins wait for the latch instead of reading stale contents
+	buf.latch = make(chan struct{})
+	bm.pages[*block] = buf
+	buf.sequential = sequential
+	buf.Pin()
+	bm.policy.Pinned(buf.frame)
+	bm.available--
+	return buf, true
+}
+
+// assign writes the block a reserved buffer holds and reads the block into it,
+// then opens the latch of the buffer
+func (bm *BufferManager) assign(buf *Buffer, block *storage.Block) (*Buffer, error) {
+	err := buf.assignToBlock(block)
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	defer close(buf.latch)
+	buf.latch = nil
+	delete(bm.pages, *block)
+	if err == nil {
+		if buf.block != nil {
+			delete(bm.pages, *buf.block)
+		}
+		buf.setBlock(block)
+		bm.pages[*block] = buf
+		return buf, nil
+	}
+
+	// release the reservation
+	buf.Unpin()
+	bm.available++
+	if buf.ModifyingTx() >= 0 {
+		// the previous block could not be written, so the buffer keeps it
+		bm.policy.Unpinned(buf.frame, buf.sequential)
+	} else {
+		if buf.block != nil {
+			delete(bm.pages, *buf.block)
 		}
-		time.Sleep(10 * time.Millisecond)
-		remain -= 10
+		buf.setBlock(nil)
+		bm.free = append(bm.free, buf.frame)
+	}
+	bm.wakeNext()
+	return nil, err
+}
+
+// unusedBuffer returns a frame which holds no block, or nil once every frame has been used
+func (bm *BufferManager) unusedBuffer() *Buffer {
+	if len(bm.free) == 0 {
+		return nil
+	}
+	frame := bm.free[0]
+	bm.free = bm.free[1:]
+	return bm.pool[frame]
+}
+
+// sequential records the pin of the block and returns true if it continues a scan of its file.
+// It is always false unless the buffer manager is scan resistant.
+func (bm *BufferManager) sequential(block *storage.Block) bool {
+	if !bm.scanResistant {
+		return false
+	}
+	last, found := bm.lastPinned[block.Filename]
+	if !found && len(bm.lastPinned) >= bm.count {
+		// forget the files of finished scans, such as temporary tables
+		clear(bm.lastPinned)
 	}
+	bm.lastPinned[block.Filename] = block.Num
+	return found && block.Num == last+1
 }
 
 func (bm *BufferManager) Unpin(buf *Buffer) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
 	buf.Unpin()
 	if !buf.IsPinned() {
-		bm.Available++
+		bm.available++
+		bm.policy.Unpinned(buf.frame, buf.sequential)
+		bm.wakeNext()
 	}
 }
 
+// Buffer holds a block of the pool. The buffer manager guards the pin count and the latch,
+// while the buffer guards the modification state, which transactions set without the manager.
+// The block changes under both locks, so that either is enough to read it.
 type Buffer struct {
 	Contents *storage.Page
 	fm       storage.FileManager
 	lm       *log.LogManager
 	block    *storage.Block
 	pincnt   int
+	mu       sync.Mutex
 	txnum    int
 	lsn      int
+	// frame is the index of the buffer in the pool
+	frame int
+	// sequential is set if the block was last pinned by a sequential scan
+	sequential bool
+	// latch is closed once the buffer is assigned to its new block, nil when no I/O is pending
+	latch chan struct{}
 }
 
 func NewBuffer(fm storage.FileManager, lm *log.LogManager) *Buffer {
@@ -149,6 +393,8 @@ func (b *Buffer) IsPinned() bool {
 }
 
 func (b *Buffer) SetModified(txnum, lsn int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	b.txnum = txnum
 	if lsn > 0 {
 		b.lsn = lsn
@@ -156,15 +402,53 @@ func (b *Buffer) SetModified(txnum, lsn int) {
 }
 
 func (b *Buffer) ModifyingTx() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	return b.txnum
 }
 
-func (b *Buffer) Flush() {
-	if b.txnum >= 0 {
-		b.lm.Flush(b.lsn)
-		b.fm.Write(b.block, b.Contents)
-		b.txnum = -1
+// Flush writes the buffer if it was modified, after the log records of the modifications
+func (b *Buffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flush()
+}
+
+func (b *Buffer) flush() error {
+	if b.txnum < 0 {
+		return nil
+	}
+	err := b.lm.Flush(b.lsn)
+	if err != nil {
+		return err
 	}
+	err = b.fm.Write(b.block, b.Contents)
+	if err != nil {
+		return err
+	}
+	b.txnum = -1
+	return nil
+}
+
+// assignToBlock writes the block the buffer holds if it was modified, and reads the new block into it.
+// The buffer is still modified if the write fails, and holds no valid contents if the read fails.
+// The buffer manager sets the block afterward with setBlock.
+func (b *Buffer) assignToBlock(block *storage.Block) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.flush()
+	if err != nil {
+		return err
+	}
+	b.lsn = -1
+	return b.fm.Read(block, b.Contents)
+}
+
+// setBlock changes the block of the buffer. The caller holds the lock of the buffer manager.
+func (b *Buffer) setBlock(block *storage.Block) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.block = block
 }
 
 func (b *Buffer) Pin() {
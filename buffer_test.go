@@ -1,15 +1,20 @@
-package main
+package simpledb
 
 import (
+	"context"
+	"fmt"
 	"simpledb/log"
 	"simpledb/storage"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestBufferManager_Pin(t *testing.T) {
-	fm := storage.NewFileManager(400)
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
 	lm, err := log.NewLogManager(fm, "test.db")
 	require.NoError(t, err)
 
@@ -22,57 +27,335 @@ func TestBufferManager_Pin(t *testing.T) {
 	})
 
 	t.Run("already pinned", func(t *testing.T) {
-		_, err := bm.Pin(storage.NewBlock("buffertest", 1))
-		require.NoError(t, err)
-		_, err = bm.Pin(storage.NewBlock("buffertest", 1))
+		other, err := bm.Pin(storage.NewBlock("buffertest", 1))
 		require.NoError(t, err)
+		require.Same(t, buf, other)
+		require.Equal(t, 2, buf.pincnt)
+		require.Equal(t, 1, bm.Available())
 	})
 
 	t.Run("unpinned", func(t *testing.T) {
-		buf.Unpin()
+		bm.Unpin(buf)
+		bm.Unpin(buf)
+		require.Equal(t, 2, bm.Available())
 
-		_, err := bm.Pin(storage.NewBlock("buffertest", 1))
+		other, err := bm.Pin(storage.NewBlock("buffertest", 1))
 		require.NoError(t, err)
+		require.Same(t, buf, other)
+		require.Equal(t, 1, bm.Available())
 	})
 
 	t.Run("buffer pool is full", func(t *testing.T) {
-		_, err := bm.Pin(storage.NewBlock("buffertest", 1))
-		require.NoError(t, err)
 		_, err = bm.Pin(storage.NewBlock("buffertest", 2))
 		require.NoError(t, err)
 		_, err = bm.Pin(storage.NewBlock("buffertest", 3))
 		require.ErrorAs(t, err, &ErrBufferFull)
+		require.Equal(t, 0, bm.Available())
 	})
-
-	// TODO: if block is already in the buffer pool, return the buffer
 }
 
 func TestBuffer(t *testing.T) {
-	bm := NewBufferManager(storage.NewFileManager(400), &log.LogManager{}, 3, WithFinalizeTime(100))
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	bm := NewBufferManager(fm, &log.LogManager{}, 3, WithFinalizeTime(100))
 	blk0 := storage.NewBlock("buffertest", 0)
 	blk1 := storage.NewBlock("buffertest", 1)
 	blk2 := storage.NewBlock("buffertest", 2)
 	blk3 := storage.NewBlock("buffertest", 3)
 
-	_, err := bm.Pin(blk0)
+	_, err = bm.Pin(blk0)
 	require.NoError(t, err)
 	buf1, err := bm.Pin(blk1)
 	require.NoError(t, err)
 	_, err = bm.Pin(blk2)
 	require.NoError(t, err)
+	require.Equal(t, 0, bm.Available())
 
 	bm.Unpin(buf1)
+	require.Equal(t, 1, bm.Available())
 
 	_, err = bm.Pin(blk1)
 	require.NoError(t, err)
+	require.Equal(t, 0, bm.Available())
 	buf2, err := bm.Pin(blk2)
 	require.NoError(t, err)
 
 	_, err = bm.Pin(blk3)
 	require.ErrorAs(t, err, &ErrBufferFull)
 
+	// blk2 is pinned twice
 	bm.Unpin(buf2)
+	require.Equal(t, 0, bm.Available())
+	bm.Unpin(buf2)
+	require.Equal(t, 1, bm.Available())
 
 	_, err = bm.Pin(blk3)
 	require.NoError(t, err)
 }
+
+func TestBufferManager_concurrent(t *testing.T) {
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	defer fm.Close()
+	lm, err := log.NewLogManager(fm, "test.log")
+	require.NoError(t, err)
+	bm := NewBufferManager(fm, lm, 8, WithReplacementPolicy(NewLRUPolicy()), WithScanResistance(), WithBackgroundWriter(1, 4))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				block := storage.NewBlock("buffertest", (g*7+i)%32)
+				buf, err := bm.Pin(block)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !buf.Block().Equals(block) {
+					errs <- fmt.Errorf("pinned %s for %s", buf.Block().ToString(), block.ToString())
+				}
+				if i%10 == 0 {
+					lsn, err := lm.Append([]byte{byte(g)})
+					if err != nil {
+						errs <- err
+						return
+					}
+					buf.SetModified(g, lsn)
+				}
+				_, _ = bm.GetBuf(block)
+				bm.Unpin(buf)
+				if i%50 == 0 {
+					bm.FlushAll(g)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 8, bm.Available())
+	require.NoError(t, bm.Close())
+}
+
+// waiting returns the number of pins waiting for a buffer
+func waiting(bm *BufferManager) int {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.waiters.Len()
+}
+
+func TestBufferManager_PinContext(t *testing.T) {
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	defer fm.Close()
+	bm := NewBufferManager(fm, &log.LogManager{}, 1)
+	buf, err := bm.Pin(storage.NewBlock("buffertest", 0))
+	require.NoError(t, err)
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			_, err := bm.PinContext(ctx, storage.NewBlock("buffertest", 1))
+			done <- err
+		}()
+		require.Eventually(t, func() bool { return waiting(bm) == 1 }, time.Second, time.Millisecond)
+		cancel()
+		require.ErrorIs(t, <-done, context.Canceled)
+		require.Equal(t, 0, waiting(bm))
+	})
+
+	t.Run("served in arrival order", func(t *testing.T) {
+		var mu sync.Mutex
+		order := []int{}
+		var wg sync.WaitGroup
+		errs := make([]error, 3)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				buf, err := bm.Pin(storage.NewBlock("buffertest", i+1))
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				bm.Unpin(buf)
+			}()
+			require.Eventually(t, func() bool { return waiting(bm) == i+1 }, time.Second, time.Millisecond)
+		}
+		bm.Unpin(buf)
+		wg.Wait()
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		require.Equal(t, []int{0, 1, 2}, order)
+		require.Equal(t, 1, bm.Available())
+	})
+}
+
+func TestBufferManager_eviction(t *testing.T) {
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	defer fm.Close()
+	lm, err := log.NewLogManager(fm, "test.log")
+	require.NoError(t, err)
+	bm := NewBufferManager(fm, lm, 1, WithFinalizeTime(100))
+	blk0 := storage.NewBlock("buffertest", 0)
+	blk1 := storage.NewBlock("buffertest", 1)
+
+	buf, err := bm.Pin(blk0)
+	require.NoError(t, err)
+	require.NoError(t, buf.Contents.SetInt32(80, 123))
+	lsn, err := lm.Append([]byte{1})
+	require.NoError(t, err)
+	buf.SetModified(1, lsn)
+	bm.Unpin(buf)
+
+	// the frame is replaced: blk0 is written after its log record and blk1 is read
+	buf, err = bm.Pin(blk1)
+	require.NoError(t, err)
+	require.Equal(t, blk1, buf.Block())
+	require.Equal(t, -1, buf.ModifyingTx())
+	require.GreaterOrEqual(t, lm.SavedLSN(), lsn)
+	n, err := buf.Contents.GetInt32(80)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), n)
+	_, err = bm.GetBuf(blk0)
+	require.ErrorIs(t, err, ErrBlockNotFound)
+	bm.Unpin(buf)
+
+	// blk0 is read back from the disk
+	buf, err = bm.Pin(blk0)
+	require.NoError(t, err)
+	n, err = buf.Contents.GetInt32(80)
+	require.NoError(t, err)
+	require.Equal(t, int32(123), n)
+	require.Equal(t, 0, bm.Available())
+	bm.Unpin(buf)
+	require.Equal(t, 1, bm.Available())
+}
+
+func TestBufferManager_defaultPolicy(t *testing.T) {
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	defer fm.Close()
+	bm := NewBufferManager(fm, &log.LogManager{}, 2)
+
+	// blk0 is used again after blk1, so blk1 is the least recently used
+	for _, num := range []int{0, 1, 0} {
+		buf, err := bm.Pin(storage.NewBlock("buffertest", num))
+		require.NoError(t, err)
+		bm.Unpin(buf)
+	}
+	buf, err := bm.Pin(storage.NewBlock("buffertest", 2))
+	require.NoError(t, err)
+	bm.Unpin(buf)
+	_, err = bm.GetBuf(storage.NewBlock("buffertest", 0))
+	require.NoError(t, err)
+	_, err = bm.GetBuf(storage.NewBlock("buffertest", 1))
+	require.ErrorIs(t, err, ErrBlockNotFound)
+}
+
+func TestBufferManager_Discard(t *testing.T) {
+	dir := t.TempDir()
+	fm, err := storage.NewFileManager(dir, 400)
+	require.NoError(t, err)
+	defer fm.Close()
+	bm := NewBufferManager(fm, &log.LogManager{}, 2)
+	pinned, err := bm.Pin(storage.NewBlock("temp1.tbl", 0))
+	require.NoError(t, err)
+	buf, err := bm.Pin(storage.NewBlock("temp1.tbl", 1))
+	require.NoError(t, err)
+	buf.SetModified(1, -1)
+	bm.Unpin(buf)
+
+	// the pinned block stays, the other one is dropped without being written
+	bm.Discard("temp1.tbl")
+	_, err = bm.GetBuf(storage.NewBlock("temp1.tbl", 0))
+	require.NoError(t, err)
+	_, err = bm.GetBuf(storage.NewBlock("temp1.tbl", 1))
+	require.ErrorIs(t, err, ErrBlockNotFound)
+	require.Equal(t, 1, bm.Available())
+	require.NoError(t, bm.FlushAll(1))
+	n, err := fm.Length("temp1.tbl")
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// the frame is reused
+	buf, err = bm.Pin(storage.NewBlock("temp2.tbl", 0))
+	require.NoError(t, err)
+	require.Equal(t, 0, bm.Available())
+	bm.Unpin(buf)
+	bm.Unpin(pinned)
+}
+
+// gatedFileManager blocks the reads of a file until the gate is closed
+type gatedFileManager struct {
+	storage.FileManager
+	filename string
+	gate     chan struct{}
+	reading  chan struct{}
+}
+
+func (fm *gatedFileManager) Read(block *storage.Block, page *storage.Page) error {
+	if block.Filename == fm.filename {
+		fm.reading <- struct{}{}
+		<-fm.gate
+	}
+	return fm.FileManager.Read(block, page)
+}
+
+func TestBufferManager_ioOutsideLock(t *testing.T) {
+	dfm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	defer dfm.Close()
+	fm := &gatedFileManager{FileManager: dfm, filename: "slow", gate: make(chan struct{}), reading: make(chan struct{}, 2)}
+	bm := NewBufferManager(fm, &log.LogManager{}, 3)
+	fast := storage.NewBlock("fast", 0)
+	slow := storage.NewBlock("slow", 0)
+	buf, err := bm.Pin(fast)
+	require.NoError(t, err)
+	bm.Unpin(buf)
+
+	type result struct {
+		buf *Buffer
+		err error
+	}
+	results := make(chan result, 2)
+	pin := func() {
+		buf, err := bm.Pin(slow)
+		results <- result{buf, err}
+	}
+	go pin()
+	<-fm.reading
+
+	// other pins go on while the block is read
+	buf, err = bm.Pin(fast)
+	require.NoError(t, err)
+	require.Equal(t, 1, bm.Available())
+	bm.Unpin(buf)
+	_, err = bm.GetBuf(slow)
+	require.ErrorIs(t, err, ErrBlockNotFound)
+
+	// a pin of the same block waits for the read instead of reading it again
+	go pin()
+	time.Sleep(20 * time.Millisecond)
+	require.Empty(t, results)
+	close(fm.gate)
+	r1, r2 := <-results, <-results
+	require.NoError(t, r1.err)
+	require.NoError(t, r2.err)
+	require.Same(t, r1.buf, r2.buf)
+	require.Equal(t, 2, r1.buf.pincnt)
+	require.Equal(t, slow, r1.buf.Block())
+	require.Equal(t, 2, bm.Available())
+	require.Empty(t, fm.reading)
+}
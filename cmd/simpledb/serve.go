@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"simpledb"
+	"simpledb/server"
+	"simpledb/sqldriver"
+	"syscall"
+)
+
+// serve runs a server over the database in a directory until it is interrupted
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":7070", "address to listen on")
+	dir := flags.String("dir", "data", "database directory")
+	blksize := flags.Int("b", sqldriver.DefaultBlockSize, "block size")
+	buffers := flags.Int("buffers", sqldriver.DefaultBuffers, "number of buffers")
+	writerInterval := flags.Int("writer-interval", simpledb.WriterIntervalMs, "milliseconds between two rounds of the background writer, 0 to disable it")
+	writerPages := flags.Int("writer-pages", simpledb.WriterMaxPages, "maximum number of buffers the background writer writes in a round")
+	flags.Parse(args)
+
+	db, err := simpledb.NewDB(*dir, *blksize, *buffers,
+		simpledb.WithBufferManagerOptions(simpledb.WithBackgroundWriter(*writerInterval, *writerPages)))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	srv := server.NewServer(db)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		srv.Close()
+	}()
+
+	slog.Info("simpledb serving", slog.String("addr", *addr), slog.String("dir", *dir), slog.Int("blocksize", *blksize), slog.Int("buffers", *buffers))
+	err = srv.ListenAndServe(*addr)
+	if errors.Is(err, server.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
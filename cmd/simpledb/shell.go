@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"simpledb"
+	"simpledb/query"
+	"simpledb/record"
+	"simpledb/server"
+	"simpledb/sqldriver"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const shellHelp = `statements end with a semicolon and may span several lines
+  BEGIN; COMMIT; ROLLBACK;   group statements in a transaction, otherwise each one commits on its own
+  \d [table]                 list the tables, or the fields of a table
+  \timing                    toggle printing the time taken by each statement
+  \?                         show this help
+  \q                         quit
+`
+
+var (
+	errTxInProgress = errors.New("a transaction is already in progress")
+	errNoTx         = errors.New("no transaction in progress")
+)
+
+// shell runs SQL statements read from the standard input against the database in a directory
+func shell(args []string) error {
+	flags := flag.NewFlagSet("shell", flag.ExitOnError)
+	dir := flags.String("dir", "data", "database directory")
+	blksize := flags.Int("b", sqldriver.DefaultBlockSize, "block size")
+	buffers := flags.Int("buffers", sqldriver.DefaultBuffers, "number of buffers")
+	flags.Parse(args)
+
+	db, err := simpledb.NewDB(*dir, *blksize, *buffers)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return newConsole(db, os.Stdout).run(os.Stdin)
+}
+
+// console reads statements and backslash commands and prints their results.
+// Outside of BEGIN and COMMIT or ROLLBACK, each statement commits on its own.
+type console struct {
+	session *server.Session
+	out     io.Writer
+	inTx    bool
+	timing  bool
+}
+
+func newConsole(db *simpledb.SimpleDB, out io.Writer) *console {
+	return &console{
+		session: server.NewSession(db),
+		out:     out,
+	}
+}
+
+// run executes the input until its end or \q, then rolls back an unfinished transaction.
+// A statement ends with a semicolon outside of a string; one left unterminated at the end is executed as well.
+func (c *console) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	stmt := &strings.Builder{}
+	quoted := false
+	c.prompt(false)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if stmt.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			if !c.command(strings.Fields(strings.TrimSpace(line))) {
+				return c.session.Close()
+			}
+			c.prompt(false)
+			continue
+		}
+		for _, r := range line {
+			switch {
+			case r == '\'':
+				quoted = !quoted
+			case r == ';' && !quoted:
+				c.execute(stmt.String())
+				stmt.Reset()
+				continue
+			}
+			stmt.WriteRune(r)
+		}
+		if strings.TrimSpace(stmt.String()) == "" {
+			stmt.Reset()
+		} else {
+			stmt.WriteByte('\n')
+		}
+		c.prompt(stmt.Len() > 0)
+	}
+	if stmt.Len() > 0 {
+		c.execute(stmt.String())
+	}
+	fmt.Fprintln(c.out)
+	return errors.Join(scanner.Err(), c.session.Close())
+}
+
+func (c *console) prompt(continued bool) {
+	switch {
+	case continued:
+		fmt.Fprint(c.out, "simpledb-> ")
+	case c.inTx:
+		fmt.Fprint(c.out, "simpledb*> ")
+	default:
+		fmt.Fprint(c.out, "simpledb=> ")
+	}
+}
+
+// command runs a backslash command and returns false on \q
+func (c *console) command(args []string) bool {
+	switch args[0] {
+	case `\q`:
+		return false
+	case `\?`:
+		fmt.Fprint(c.out, shellHelp)
+	case `\timing`:
+		c.timing = !c.timing
+		if c.timing {
+			fmt.Fprintln(c.out, "Timing is on.")
+		} else {
+			fmt.Fprintln(c.out, "Timing is off.")
+		}
+	case `\d`:
+		var err error
+		if len(args) > 1 {
+			err = c.describe(args[1])
+		} else {
+			err = c.tables()
+		}
+		if err != nil {
+			fmt.Fprintf(c.out, "error: %v\n", err)
+		}
+	default:
+		fmt.Fprintf(c.out, "unknown command %s, try \\?\n", args[0])
+	}
+	return true
+}
+
+// tables lists the tables of the catalog
+func (c *console) tables() error {
+	result, err := c.query("select tblname from tblcat order by tblname")
+	if err != nil {
+		return err
+	}
+	result.Columns[0].Name = "table"
+	c.print(result)
+	return nil
+}
+
+// describe lists the fields of a table
+func (c *console) describe(tblname string) error {
+	sql := "select fldname, type, length from fldcat where tblname = " + query.NewStringConstant(tblname).String()
+	fields, err := c.query(sql)
+	if err != nil {
+		return err
+	}
+	if len(fields.Rows) == 0 {
+		return fmt.Errorf("table not found: %s", tblname)
+	}
+	result := &server.Result{
+		Columns: []server.Column{{Name: "field", Type: record.FieldType_VARCHAR}, {Name: "type", Type: record.FieldType_VARCHAR}},
+	}
+	for _, row := range fields.Rows {
+		typ := "int"
+		if record.FieldType(row[1].(int64)) == record.FieldType_VARCHAR {
+			typ = fmt.Sprintf("varchar(%d)", row[2])
+		}
+		result.Rows = append(result.Rows, []any{row[0], typ})
+	}
+	c.print(result)
+	return nil
+}
+
+// execute runs a statement and prints its result or error
+func (c *console) execute(sql string) {
+	sql = strings.TrimSpace(sql)
+	if sql == "" {
+		return
+	}
+	start := time.Now()
+	err := c.statement(sql)
+	if err != nil {
+		fmt.Fprintf(c.out, "error: %v\n", err)
+	}
+	if c.timing {
+		fmt.Fprintf(c.out, "Time: %.3f ms\n", float64(time.Since(start).Microseconds())/1000)
+	}
+}
+
+func (c *console) statement(sql string) error {
+	keyword := strings.Fields(sql)[0]
+	switch strings.ToLower(keyword) {
+	case "begin":
+		if c.inTx {
+			return errTxInProgress
+		}
+		c.inTx = true
+		fmt.Fprintln(c.out, "BEGIN")
+		return nil
+	case "commit", "rollback":
+		if !c.inTx {
+			return errNoTx
+		}
+		c.inTx = false
+		if strings.EqualFold(keyword, "commit") {
+			err := c.session.Commit()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(c.out, "COMMIT")
+			return nil
+		}
+		err := c.session.Rollback()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, "ROLLBACK")
+		return nil
+	case "select":
+		result, err := c.query(sql)
+		if err != nil {
+			return err
+		}
+		c.print(result)
+		return nil
+	default:
+		n, err := c.session.Update(sql)
+		if err != nil {
+			return c.failed(err)
+		}
+		fmt.Fprintf(c.out, "%s affected\n", plural(n, "row"))
+		return c.autocommit()
+	}
+}
+
+func (c *console) query(sql string) (*server.Result, error) {
+	result, err := c.session.Query(sql)
+	if err != nil {
+		return nil, c.failed(err)
+	}
+	return result, c.autocommit()
+}
+
+// autocommit commits a statement run outside of a transaction
+func (c *console) autocommit() error {
+	if c.inTx {
+		return nil
+	}
+	return c.session.Commit()
+}
+
+// failed ends the transaction which the session rolled back along with the failed statement
+func (c *console) failed(err error) error {
+	if !c.inTx {
+		return err
+	}
+	c.inTx = false
+	return fmt.Errorf("%w (transaction rolled back)", err)
+}
+
+// print writes the result as a table whose integer columns are aligned right
+func (c *console) print(result *server.Result) {
+	widths := make([]int, len(result.Columns))
+	for i, col := range result.Columns {
+		widths[i] = utf8.RuneCountInString(col.Name)
+	}
+	cells := make([][]string, len(result.Rows))
+	for i, row := range result.Rows {
+		cells[i] = make([]string, len(row))
+		for j, val := range row {
+			cells[i][j] = fmt.Sprint(val)
+			widths[j] = max(widths[j], utf8.RuneCountInString(cells[i][j]))
+		}
+	}
+
+	line := func(vals []string, right func(int) bool) {
+		b := &strings.Builder{}
+		for i, val := range vals {
+			if i > 0 {
+				b.WriteString("|")
+			}
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(val))
+			if right(i) {
+				fmt.Fprintf(b, " %s%s ", pad, val)
+			} else {
+				fmt.Fprintf(b, " %s%s ", val, pad)
+			}
+		}
+		fmt.Fprintln(c.out, strings.TrimRight(b.String(), " "))
+	}
+
+	names := make([]string, len(result.Columns))
+	rules := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		names[i] = col.Name
+		rules[i] = strings.Repeat("-", widths[i]+2)
+	}
+	line(names, func(int) bool { return false })
+	fmt.Fprintln(c.out, strings.Join(rules, "+"))
+	for _, row := range cells {
+		line(row, func(i int) bool { return result.Columns[i].Type == record.FieldType_INTEGER })
+	}
+	fmt.Fprintf(c.out, "(%s)\n", plural(len(result.Rows), "row"))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
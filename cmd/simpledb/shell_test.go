@@ -0,0 +1,96 @@
+package main
+
+import (
+	"simpledb"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runConsole runs the input against a new database in a temporary directory
+func runConsole(t *testing.T, input string) string {
+	t.Helper()
+	db, err := simpledb.NewDB(t.TempDir(), 400, 8)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	out := &strings.Builder{}
+	require.NoError(t, newConsole(db, out).run(strings.NewReader(input)))
+	return out.String()
+}
+
+func TestConsole(t *testing.T) {
+	out := runConsole(t, `create table t (a int, b varchar(10));
+insert into t (a, b) values (7, 'seven');
+insert into t (a, b)
+  values (12, 'semi;colon');
+select b, a
+from t order by a;
+\d
+\d t
+\d u
+\q
+select a from t;
+`)
+	require.Contains(t, out, "1 row affected\n")
+	require.Contains(t, out, "simpledb=> simpledb-> 1 row affected\n")
+	require.Contains(t, out, ` b          | a
+------------+----
+ seven      |  7
+ semi;colon | 12
+(2 rows)
+`)
+	require.Contains(t, out, ` table
+---------
+ fldcat
+ idxcat
+ t
+ tblcat
+ viewcat
+(5 rows)
+`)
+	require.Contains(t, out, ` field | type
+-------+-------------
+ a     | int
+ b     | varchar(10)
+(2 rows)
+`)
+	require.Contains(t, out, "error: table not found: u\n")
+	// nothing runs after \q
+	require.NotContains(t, out, "(1 row)")
+}
+
+func TestConsole_transactions(t *testing.T) {
+	out := runConsole(t, `create table t (a int);
+begin;
+insert into t (a) values (1);
+rollback;
+begin;
+insert into t (a) values (2);
+begin;
+commit;
+commit;
+begin;
+insert into t (b) values (3);
+insert into t (a) values (4);
+select a from t;
+\timing
+select a from t`)
+	require.Contains(t, out, "simpledb*> ")
+	require.Contains(t, out, "ROLLBACK\n")
+	require.Contains(t, out, "error: "+errTxInProgress.Error())
+	require.Contains(t, out, "error: "+errNoTx.Error())
+	require.Contains(t, out, "(transaction rolled back)\n")
+	require.Contains(t, out, "Timing is on.\n")
+	require.Contains(t, out, "Time: ")
+	// the insert after the failed statement commits on its own
+	require.Equal(t, 2, strings.Count(out, ` a
+---
+ 2
+ 4
+(2 rows)
+`))
+}
@@ -1,26 +1,127 @@
-package main
+package simpledb
 
 import (
+	"errors"
 	"simpledb/log"
+	"simpledb/metadata"
+	"simpledb/plan"
 	"simpledb/storage"
+	"sync/atomic"
 )
 
 type SimpleDB struct {
 	BufferManager *BufferManager
 	fm            storage.FileManager
 	lm            *log.LogManager
+	cm            *ConcurrencyManager
+	mdm           *metadata.MetadataManager
+	planner       *plan.Planner
+	nextTxID      atomic.Int64
 }
 
-func NewDB(filename string, blocksize, bufsize int) (*SimpleDB, error) {
-	fm := storage.NewFileManager(blocksize)
-	lm, err := log.NewLogManager(fm, filename+".log")
+// LogFile is the name of the log in the database directory
+const LogFile = "simpledb.log"
+
+// DBOptions configures the components of the database
+type DBOptions func(o *dbOptions)
+
+type dbOptions struct {
+	fm []storage.FileManagerOptions
+	bm []BufferManagerOptions
+}
+
+func WithFileManagerOptions(opts ...storage.FileManagerOptions) DBOptions {
+	return func(o *dbOptions) {
+		o.fm = append(o.fm, opts...)
+	}
+}
+
+func WithBufferManagerOptions(opts ...BufferManagerOptions) DBOptions {
+	return func(o *dbOptions) {
+		o.bm = append(o.bm, opts...)
+	}
+}
+
+// NewDB opens the database in the directory, creating it if needed, and recovers it from the log.
+// The directory stays locked until the database is closed.
+func NewDB(dir string, blocksize, bufsize int, opts ...DBOptions) (*SimpleDB, error) {
+	o := &dbOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	fm, err := storage.NewFileManager(dir, blocksize, o.fm...)
+	if err != nil {
+		return nil, err
+	}
+	db, err := newDB(fm, bufsize, o.bm)
+	if err != nil {
+		fm.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newDB(fm storage.FileManager, bufsize int, bmOpts []BufferManagerOptions) (*SimpleDB, error) {
+	lm, err := log.NewLogManager(fm, LogFile)
+	if err != nil {
+		return nil, err
+	}
+	err = NewRecoveryManager(fm, lm).Recover()
 	if err != nil {
 		return nil, err
 	}
-	bm := NewBufferManager(fm, lm, bufsize)
-	return &SimpleDB{
+	bm := NewBufferManager(fm, lm, bufsize, bmOpts...)
+	db := &SimpleDB{
 		fm:            fm,
 		lm:            lm,
+		cm:            NewConcurrencyManager(),
 		BufferManager: bm,
-	}, nil
+	}
+
+	// the catalog is created along with the first table file
+	size, err := fm.Length("tblcat.tbl")
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	db.mdm, err = metadata.NewMetadataManager(size == 0, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	db.planner = plan.NewPlanner(plan.NewHeuristicQueryPlanner(db.mdm), plan.NewIndexUpdatePlanner(db.mdm))
+	return db, nil
+}
+
+// Close stops the buffer manager and releases the database directory. Committed changes
+// which are still in the buffers are redone from the log when the database is opened again.
+func (db *SimpleDB) Close() error {
+	return errors.Join(db.BufferManager.Close(), db.fm.Close())
+}
+
+// NewTransaction starts a new transaction
+func (db *SimpleDB) NewTransaction() (*Transaction, error) {
+	id := int(db.nextTxID.Add(1))
+	tx := NewTransaction(id, db.lm, db.cm, db.BufferManager)
+	err := tx.Start()
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (db *SimpleDB) MetadataManager() *metadata.MetadataManager {
+	return db.mdm
+}
+
+// Planner returns the entry point for executing SQL statements
+func (db *SimpleDB) Planner() *plan.Planner {
+	return db.planner
 }
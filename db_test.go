@@ -0,0 +1,302 @@
+package simpledb
+
+import (
+	"fmt"
+	"os"
+	"simpledb/metadata"
+	"simpledb/record"
+	"simpledb/storage"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDB_Catalog(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := NewDB(dir, 400, 8)
+	require.NoError(t, err)
+
+	tx, err := db.NewTransaction()
+	require.NoError(t, err)
+	mdm := db.MetadataManager()
+
+	schema := record.NewSchema()
+	schema.AddIntField("A")
+	schema.AddStringField("B", 9)
+	require.NoError(t, mdm.CreateTable("mytable", schema, tx))
+	require.NoError(t, mdm.CreateView("myview", "select B from mytable where A = 1", tx))
+	require.NoError(t, mdm.CreateIndex("myindex", metadata.IndexType_HASH, "mytable", "A", tx))
+
+	layout, err := mdm.GetLayout("mytable", tx)
+	require.NoError(t, err)
+	ts, err := record.NewTableScan(tx, "mytable", layout)
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("A", int32(i%5)))
+		require.NoError(t, ts.SetString("B", "rec"))
+	}
+	ts.Close()
+	require.NoError(t, tx.Commit())
+
+	// reopen the database and read the catalog back
+	require.NoError(t, db.Close())
+	db, err = NewDB(dir, 400, 8)
+	require.NoError(t, err)
+	tx, err = db.NewTransaction()
+	require.NoError(t, err)
+	mdm = db.MetadataManager()
+
+	names, err := mdm.TableNames(tx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"tblcat", "fldcat", "viewcat", "idxcat", "mytable"}, names)
+
+	layout, err = mdm.GetLayout("mytable", tx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B"}, layout.Schema().Fields())
+	require.Equal(t, record.FieldType_VARCHAR, layout.Schema().Type("B"))
+	require.Equal(t, 9, layout.Schema().Length("B"))
+	require.Equal(t, 4, layout.Offset("A"))
+	require.Equal(t, 8, layout.Offset("B"))
+	require.Equal(t, 21, layout.SlotSize())
+
+	_, err = mdm.GetLayout("nosuchtable", tx)
+	require.ErrorIs(t, err, metadata.ErrTableNotFound)
+
+	vdef, err := mdm.GetViewDef("myview", tx)
+	require.NoError(t, err)
+	require.Equal(t, "select B from mytable where A = 1", vdef)
+	_, err = mdm.GetViewDef("nosuchview", tx)
+	require.ErrorIs(t, err, metadata.ErrViewNotFound)
+
+	si, err := mdm.GetStatInfo("mytable", layout, tx)
+	require.NoError(t, err)
+	require.Equal(t, 50, si.RecordsOutput())
+	require.Equal(t, 3, si.BlocksAccessed())
+	require.Equal(t, 5, si.DistinctValues("A"))
+	require.Equal(t, 1, si.DistinctValues("B"))
+
+	indexes, err := mdm.GetIndexInfo("mytable", tx)
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	require.Equal(t, "myindex", indexes["A"].IndexName())
+	require.Equal(t, 10, indexes["A"].RecordsOutput())
+	require.NoError(t, tx.Commit())
+}
+
+func TestSimpleDB_Planner(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := NewDB(dir, 400, 8)
+	require.NoError(t, err)
+	planner := db.Planner()
+
+	tx, err := db.NewTransaction()
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("create table t (a int, b varchar(5))", tx)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = planner.ExecuteUpdate(fmt.Sprintf("insert into t (a, b) values (%d, 'r%d')", i, i), tx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	// an aborted delete leaves the table unchanged
+	tx, err = db.NewTransaction()
+	require.NoError(t, err)
+	n, err := planner.ExecuteUpdate("delete from t where b = 'r3'", tx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, tx.Rollback())
+
+	tx, err = db.NewTransaction()
+	require.NoError(t, err)
+	s, err := planner.ExecuteQuery("select a from t where b = 'r3'", tx)
+	require.NoError(t, err)
+	ok, err := s.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	a, err := s.GetInt("a")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), a)
+	ok, err = s.Next()
+	require.NoError(t, err)
+	require.False(t, ok)
+	s.Close()
+	require.NoError(t, tx.Commit())
+}
+
+func TestSimpleDB_OrderBy(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := NewDB(dir, 400, 8)
+	require.NoError(t, err)
+	planner := db.Planner()
+
+	tx, err := db.NewTransaction()
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("create table t (a int, b varchar(5))", tx)
+	require.NoError(t, err)
+	for i := 0; i < 300; i++ {
+		_, err = planner.ExecuteUpdate(fmt.Sprintf("insert into t (a, b) values (%d, 'r%d')", (i*37)%300, i%4), tx)
+		require.NoError(t, err)
+	}
+
+	// the runs are sized by the buffers left unpinned
+	s, err := planner.ExecuteQuery("select a from t order by a", tx)
+	require.NoError(t, err)
+	for i := 0; i < 300; i++ {
+		ok, err := s.Next()
+		require.NoError(t, err)
+		require.True(t, ok)
+		a, err := s.GetInt("a")
+		require.NoError(t, err)
+		require.Equal(t, int32(i), a)
+	}
+	ok, err := s.Next()
+	require.NoError(t, err)
+	require.False(t, ok)
+	s.Close()
+	require.Empty(t, tempFiles(t, dir))
+
+	s, err = planner.ExecuteQuery("select b, count(a) from t group by b", tx)
+	require.NoError(t, err)
+	groups := 0
+	for {
+		ok, err := s.Next()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		n, err := s.GetInt("countofa")
+		require.NoError(t, err)
+		require.Equal(t, int32(75), n)
+		groups++
+	}
+	s.Close()
+	require.Equal(t, 4, groups)
+	require.NoError(t, tx.Commit())
+	require.Equal(t, 8, db.BufferManager.Available())
+
+	// the rollback does not write the removed runs again
+	tx, err = db.NewTransaction()
+	require.NoError(t, err)
+	s, err = planner.ExecuteQuery("select a, b from t order by b, a", tx)
+	require.NoError(t, err)
+	s.Close()
+	require.NoError(t, tx.Rollback())
+	require.Empty(t, tempFiles(t, dir))
+}
+
+// tempFiles returns the temporary files in the directory
+func tempFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	files := []string{}
+	for _, entry := range entries {
+		if storage.IsTempFile(entry.Name()) {
+			files = append(files, entry.Name())
+		}
+	}
+	return files
+}
+
+func TestSimpleDB_Join(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := NewDB(dir, 400, 8)
+	require.NoError(t, err)
+	planner := db.Planner()
+
+	tx, err := db.NewTransaction()
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("create table r (a int, x varchar(10))", tx)
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("create table s (b int, y varchar(10))", tx)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = planner.ExecuteUpdate(fmt.Sprintf("insert into r (a, x) values (%d, 'x%d')", i, i), tx)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 400; i++ {
+		_, err = planner.ExecuteUpdate(fmt.Sprintf("insert into s (b, y) values (%d, 'y%d')", i%200, i), tx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	// s does not fit in the buffers, so the join goes through partitions or chunks
+	tx, err = db.NewTransaction()
+	require.NoError(t, err)
+	for _, sql := range []string{
+		"select x, y from r, s where a = b",
+		"select x, y from r, s where a = b and x <> y",
+	} {
+		s, err := planner.ExecuteQuery(sql, tx)
+		require.NoError(t, err)
+		n := 0
+		for {
+			ok, err := s.Next()
+			require.NoError(t, err)
+			if !ok {
+				break
+			}
+			n++
+		}
+		s.Close()
+		require.Equal(t, 200, n, sql)
+	}
+	require.NoError(t, tx.Commit())
+	require.Equal(t, 8, db.BufferManager.Available())
+}
+
+func TestSimpleDB_BTreeRollback(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := NewDB(dir, 400, 16)
+	require.NoError(t, err)
+	planner := db.Planner()
+
+	count := func(tx *Transaction, sql string) int {
+		s, err := planner.ExecuteQuery(sql, tx)
+		require.NoError(t, err)
+		defer s.Close()
+		n := 0
+		for {
+			ok, err := s.Next()
+			require.NoError(t, err)
+			if !ok {
+				return n
+			}
+			n++
+		}
+	}
+
+	tx, err := db.NewTransaction()
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("create table t (a int)", tx)
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("create index aidx on t (a) using btree", tx)
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err = planner.ExecuteUpdate(fmt.Sprintf("insert into t (a) values (%d)", i), tx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	// enough entries to split leaves and grow the directory, then undo them
+	tx, err = db.NewTransaction()
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		_, err = planner.ExecuteUpdate(fmt.Sprintf("insert into t (a) values (%d)", i%40), tx)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 115, count(tx, "select a from t where a between 10 and 30"))
+	require.NoError(t, tx.Rollback())
+
+	tx, err = db.NewTransaction()
+	require.NoError(t, err)
+	require.Equal(t, 10, count(tx, "select a from t where a >= 10"))
+	require.Equal(t, 1, count(tx, "select a from t where a = 5"))
+	require.NoError(t, tx.Commit())
+}
@@ -0,0 +1,171 @@
+package index
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"simpledb/storage"
+)
+
+// btreeDir is a directory node of a B-tree. Its flag is its level: the children of
+// a level 0 node are leaves, the children of a level n node are directory nodes of level n-1.
+type btreeDir struct {
+	tx     record.Transaction
+	layout *record.Layout
+	page   *btreePage
+}
+
+func newBTreeDir(tx record.Transaction, block *storage.Block, layout *record.Layout) (*btreeDir, error) {
+	page, err := newBTreePage(tx, block, layout)
+	if err != nil {
+		return nil, err
+	}
+	return &btreeDir{
+		tx:     tx,
+		layout: layout,
+		page:   page,
+	}, nil
+}
+
+func (bd *btreeDir) close() {
+	bd.page.close()
+}
+
+// search returns the number of the leaf block where entries having the key belong
+func (bd *btreeDir) search(key query.Constant) (int, error) {
+	childblk, err := bd.findChildBlock(key)
+	if err != nil {
+		return -1, err
+	}
+	for {
+		level, err := bd.page.flag()
+		if err != nil {
+			return -1, err
+		}
+		if level == 0 {
+			return childblk.Num, nil
+		}
+		bd.page.close()
+		bd.page, err = newBTreePage(bd.tx, childblk, bd.layout)
+		if err != nil {
+			return -1, err
+		}
+		childblk, err = bd.findChildBlock(key)
+		if err != nil {
+			return -1, err
+		}
+	}
+}
+
+// makeNewRoot moves the entries of this root node into a new block,
+// then makes the root point to that block and to the one of the entry
+func (bd *btreeDir) makeNewRoot(e *dirEntry) error {
+	firstval, err := bd.page.dataVal(0)
+	if err != nil {
+		return err
+	}
+	level, err := bd.page.flag()
+	if err != nil {
+		return err
+	}
+	newblk, err := bd.page.split(0, level, -1)
+	if err != nil {
+		return err
+	}
+	_, err = bd.insertEntry(&dirEntry{dataval: firstval, blknum: newblk.Num})
+	if err != nil {
+		return err
+	}
+	_, err = bd.insertEntry(e)
+	if err != nil {
+		return err
+	}
+	return bd.page.setFlag(level + 1)
+}
+
+// insert adds the entry of a new child below this node.
+// It returns the directory entry of the new node if this one had to be split.
+func (bd *btreeDir) insert(e *dirEntry) (*dirEntry, error) {
+	level, err := bd.page.flag()
+	if err != nil {
+		return nil, err
+	}
+	if level == 0 {
+		return bd.insertEntry(e)
+	}
+	childblk, err := bd.findChildBlock(e.dataval)
+	if err != nil {
+		return nil, err
+	}
+	child, err := newBTreeDir(bd.tx, childblk, bd.layout)
+	if err != nil {
+		return nil, err
+	}
+	myentry, err := child.insert(e)
+	child.close()
+	if err != nil || myentry == nil {
+		return nil, err
+	}
+	return bd.insertEntry(myentry)
+}
+
+func (bd *btreeDir) insertEntry(e *dirEntry) (*dirEntry, error) {
+	slot, err := bd.page.findSlotBefore(e.dataval)
+	if err != nil {
+		return nil, err
+	}
+	err = bd.page.insertDir(slot+1, e.dataval, e.blknum)
+	if err != nil {
+		return nil, err
+	}
+	full, err := bd.page.isFull()
+	if err != nil || !full {
+		return nil, err
+	}
+
+	level, err := bd.page.flag()
+	if err != nil {
+		return nil, err
+	}
+	n, err := bd.page.numRecs()
+	if err != nil {
+		return nil, err
+	}
+	splitpos := n / 2
+	splitval, err := bd.page.dataVal(splitpos)
+	if err != nil {
+		return nil, err
+	}
+	newblk, err := bd.page.split(splitpos, level, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &dirEntry{dataval: splitval, blknum: newblk.Num}, nil
+}
+
+// findChildBlock returns the child whose entries start at the greatest value not above the key
+func (bd *btreeDir) findChildBlock(key query.Constant) (*storage.Block, error) {
+	slot, err := bd.page.findSlotBefore(key)
+	if err != nil {
+		return nil, err
+	}
+	n, err := bd.page.numRecs()
+	if err != nil {
+		return nil, err
+	}
+	if slot+1 < n {
+		val, err := bd.page.dataVal(slot + 1)
+		if err != nil {
+			return nil, err
+		}
+		if val.Equals(key) {
+			slot++
+		}
+	}
+	// the first entry holds the smallest possible value
+	slot = max(slot, 0)
+	blknum, err := bd.page.childNum(slot)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewBlock(bd.page.block.Filename, blknum), nil
+}
@@ -0,0 +1,206 @@
+package index
+
+import (
+	"math"
+	"simpledb/query"
+	"simpledb/record"
+	"simpledb/storage"
+)
+
+// RangeIndex is an Index whose entries can be read in key order
+type RangeIndex interface {
+	Index
+	// Seek positions the index before the first entry whose value is not less than the key.
+	// Next then moves through the following entries up to the end of the index.
+	Seek(key query.Constant) error
+	// Rewind positions the index before its first entry.
+	// Next then moves through all the entries.
+	Rewind() error
+	// GetDataVal returns the value of the current entry
+	GetDataVal() (query.Constant, error)
+}
+
+// BTreeIndex is a B-tree stored in two files: <index>.dir holds the directory,
+// whose root is block 0, and <index>.leaf holds the sorted entries.
+// The leftmost leaf is always block 0 of the leaf file.
+type BTreeIndex struct {
+	tx         record.Transaction
+	dirLayout  *record.Layout
+	leafLayout *record.Layout
+	leafFile   string
+	rootBlock  *storage.Block
+	leaf       *btreeLeaf
+	// searchKey is the key the entries must equal, or nil when reading a range
+	searchKey *query.Constant
+}
+
+// NewBTreeIndex opens the index, creating its files if needed. The layout is the one of the leaf entries.
+func NewBTreeIndex(tx record.Transaction, idxname string, leafLayout *record.Layout) (*BTreeIndex, error) {
+	bi := &BTreeIndex{
+		tx:         tx,
+		leafLayout: leafLayout,
+		leafFile:   idxname + ".leaf",
+	}
+	size, err := tx.Size(bi.leafFile)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		block, err := tx.Append(bi.leafFile)
+		if err != nil {
+			return nil, err
+		}
+		err = bi.formatBlock(block, leafLayout, -1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	leafSchema := leafLayout.Schema()
+	dirSchema := record.NewSchema()
+	dirSchema.Add("block", leafSchema)
+	dirSchema.Add("dataval", leafSchema)
+	bi.dirLayout = record.NewLayout(dirSchema)
+	dirFile := idxname + ".dir"
+	bi.rootBlock = storage.NewBlock(dirFile, 0)
+	size, err = tx.Size(dirFile)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		// the root starts with a single entry pointing to the first leaf
+		_, err = tx.Append(dirFile)
+		if err != nil {
+			return nil, err
+		}
+		err = bi.formatBlock(bi.rootBlock, bi.dirLayout, 0)
+		if err != nil {
+			return nil, err
+		}
+		root, err := newBTreePage(tx, bi.rootBlock, bi.dirLayout)
+		if err != nil {
+			return nil, err
+		}
+		minval := query.NewStringConstant("")
+		if leafSchema.Type("dataval") == record.FieldType_INTEGER {
+			minval = query.NewIntConstant(math.MinInt32)
+		}
+		err = root.insertDir(0, minval, 0)
+		root.close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bi, nil
+}
+
+// BeforeFirst positions the index before the first entry having the search key
+func (bi *BTreeIndex) BeforeFirst(searchKey query.Constant) error {
+	err := bi.Seek(searchKey)
+	bi.searchKey = &searchKey
+	return err
+}
+
+func (bi *BTreeIndex) Seek(key query.Constant) error {
+	bi.Close()
+	bi.searchKey = nil
+	root, err := newBTreeDir(bi.tx, bi.rootBlock, bi.dirLayout)
+	if err != nil {
+		return err
+	}
+	blknum, err := root.search(key)
+	root.close()
+	if err != nil {
+		return err
+	}
+	bi.leaf, err = newBTreeLeaf(bi.tx, storage.NewBlock(bi.leafFile, blknum), bi.leafLayout, key)
+	return err
+}
+
+func (bi *BTreeIndex) Rewind() error {
+	bi.Close()
+	bi.searchKey = nil
+	var err error
+	bi.leaf, err = newBTreeLeaf(bi.tx, storage.NewBlock(bi.leafFile, 0), bi.leafLayout, query.NewIntConstant(math.MinInt32))
+	return err
+}
+
+// Next moves to the next entry. After BeforeFirst, it returns false once the entries
+// stop having the search key.
+func (bi *BTreeIndex) Next() (bool, error) {
+	ok, err := bi.leaf.next()
+	if err != nil || !ok || bi.searchKey == nil {
+		return ok, err
+	}
+	val, err := bi.leaf.dataVal()
+	if err != nil {
+		return false, err
+	}
+	return val.Equals(*bi.searchKey), nil
+}
+
+func (bi *BTreeIndex) GetDataVal() (query.Constant, error) {
+	return bi.leaf.dataVal()
+}
+
+func (bi *BTreeIndex) GetDataRID() (record.RID, error) {
+	return bi.leaf.dataRID()
+}
+
+func (bi *BTreeIndex) Insert(val query.Constant, rid record.RID) error {
+	err := bi.BeforeFirst(val)
+	if err != nil {
+		return err
+	}
+	e, err := bi.leaf.insert(rid)
+	bi.Close()
+	if err != nil || e == nil {
+		return err
+	}
+
+	root, err := newBTreeDir(bi.tx, bi.rootBlock, bi.dirLayout)
+	if err != nil {
+		return err
+	}
+	defer root.close()
+	e, err = root.insert(e)
+	if err != nil || e == nil {
+		return err
+	}
+	return root.makeNewRoot(e)
+}
+
+func (bi *BTreeIndex) Delete(val query.Constant, rid record.RID) error {
+	err := bi.BeforeFirst(val)
+	if err != nil {
+		return err
+	}
+	defer bi.Close()
+	_, err = bi.leaf.delete(rid)
+	return err
+}
+
+func (bi *BTreeIndex) Close() {
+	if bi.leaf != nil {
+		bi.leaf.close()
+		bi.leaf = nil
+	}
+}
+
+func (bi *BTreeIndex) formatBlock(block *storage.Block, layout *record.Layout, flag int) error {
+	page, err := newBTreePage(bi.tx, block, layout)
+	if err != nil {
+		return err
+	}
+	defer page.close()
+	return page.format(flag)
+}
+
+// BTreeSearchCost estimates the number of block accesses to find the entries of a value,
+// given the number of blocks of the leaves and the number of entries per block
+func BTreeSearchCost(numBlocks, rpb int) int {
+	if numBlocks <= 1 || rpb <= 1 {
+		return 1
+	}
+	return 1 + int(math.Log(float64(numBlocks))/math.Log(float64(rpb)))
+}
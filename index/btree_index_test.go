@@ -0,0 +1,155 @@
+package index
+
+import (
+	"fmt"
+	"math/rand"
+	"simpledb/query"
+	"simpledb/record"
+	"simpledb/record/recordtest"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	val query.Constant
+	rid record.RID
+}
+
+func compareEntries(a, b entry) int {
+	if c := a.val.Compare(b.val); c != 0 {
+		return c
+	}
+	if a.rid.BlockNum != b.rid.BlockNum {
+		return a.rid.BlockNum - b.rid.BlockNum
+	}
+	return a.rid.Slot - b.rid.Slot
+}
+
+// readAll returns the entries from the current position of the index
+func readAll(t *testing.T, idx RangeIndex) []entry {
+	t.Helper()
+	got := []entry{}
+	for {
+		ok, err := idx.Next()
+		require.NoError(t, err)
+		if !ok {
+			return got
+		}
+		val, err := idx.GetDataVal()
+		require.NoError(t, err)
+		rid, err := idx.GetDataRID()
+		require.NoError(t, err)
+		got = append(got, entry{val, rid})
+	}
+}
+
+// checkIndex compares the index with the entries it should hold
+func checkIndex(t *testing.T, idx *BTreeIndex, want []entry, keys []query.Constant) {
+	t.Helper()
+	want = slices.Clone(want)
+	slices.SortFunc(want, compareEntries)
+
+	// a full scan returns every entry in key order
+	require.NoError(t, idx.Rewind())
+	got := readAll(t, idx)
+	require.True(t, slices.IsSortedFunc(got, func(a, b entry) int { return a.val.Compare(b.val) }))
+	slices.SortFunc(got, compareEntries)
+	require.Equal(t, want, got)
+
+	for _, key := range keys {
+		var eq, from []entry
+		for _, e := range want {
+			if e.val.Equals(key) {
+				eq = append(eq, e)
+			}
+			if e.val.Compare(key) >= 0 {
+				from = append(from, e)
+			}
+		}
+
+		require.NoError(t, idx.BeforeFirst(key))
+		got := readAll(t, idx)
+		slices.SortFunc(got, compareEntries)
+		require.Equal(t, len(eq), len(got), "key %s", key)
+		require.ElementsMatch(t, eq, got, "key %s", key)
+
+		require.NoError(t, idx.Seek(key))
+		got = readAll(t, idx)
+		require.True(t, slices.IsSortedFunc(got, func(a, b entry) int { return a.val.Compare(b.val) }))
+		require.Equal(t, len(from), len(got), "key %s", key)
+	}
+	idx.Close()
+}
+
+func TestBTreeIndex(t *testing.T) {
+	// small blocks hold a few entries, so there are many splits, overflow blocks and directory levels
+	tx := recordtest.NewTransaction(100)
+	idx, err := NewBTreeIndex(tx, "idx", indexLayout(record.FieldType_INTEGER))
+	require.NoError(t, err)
+
+	rnd := rand.New(rand.NewSource(1))
+	entries := []entry{}
+	for i := 0; i < 600; i++ {
+		// key 7 is frequent enough to overflow several blocks
+		k := int32(rnd.Intn(60) - 10)
+		if i%4 == 0 {
+			k = 7
+		}
+		e := entry{query.NewIntConstant(k), record.NewRID(i/10, i%10)}
+		require.NoError(t, idx.Insert(e.val, e.rid))
+		entries = append(entries, e)
+	}
+	keys := []query.Constant{}
+	for k := int32(-12); k < 52; k++ {
+		keys = append(keys, query.NewIntConstant(k))
+	}
+	checkIndex(t, idx, entries, keys)
+
+	// delete two thirds of the entries, including most of the 7s
+	rnd.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+	for _, e := range entries[:400] {
+		require.NoError(t, idx.Delete(e.val, e.rid))
+	}
+	entries = entries[400:]
+	checkIndex(t, idx, entries, keys)
+
+	// the index can be reopened and grown again
+	idx, err = NewBTreeIndex(tx, "idx", indexLayout(record.FieldType_INTEGER))
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		e := entry{query.NewIntConstant(int32(i % 5)), record.NewRID(1000+i, 0)}
+		require.NoError(t, idx.Insert(e.val, e.rid))
+		entries = append(entries, e)
+	}
+	checkIndex(t, idx, entries, keys)
+	require.Empty(t, tx.Pinned())
+}
+
+func TestBTreeIndex_string(t *testing.T) {
+	tx := recordtest.NewTransaction(200)
+	idx, err := NewBTreeIndex(tx, "idx", indexLayout(record.FieldType_VARCHAR))
+	require.NoError(t, err)
+
+	entries := []entry{}
+	keys := []query.Constant{query.NewStringConstant("")}
+	for i := 0; i < 300; i++ {
+		e := entry{query.NewStringConstant(fmt.Sprintf("k%03d", (i*37)%101)), record.NewRID(i, 1)}
+		require.NoError(t, idx.Insert(e.val, e.rid))
+		entries = append(entries, e)
+		keys = append(keys, e.val)
+	}
+	checkIndex(t, idx, entries, keys)
+
+	// delete an entry which is not in the index
+	require.NoError(t, idx.Delete(query.NewStringConstant("k000"), record.NewRID(999, 9)))
+	checkIndex(t, idx, entries, keys[:10])
+	require.Empty(t, tx.Pinned())
+}
+
+func TestBTreeSearchCost(t *testing.T) {
+	require.Equal(t, 1, BTreeSearchCost(0, 10))
+	require.Equal(t, 2, BTreeSearchCost(10, 10))
+	require.Equal(t, 3, BTreeSearchCost(1000, 20))
+}
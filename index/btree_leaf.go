@@ -0,0 +1,410 @@
+package index
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"simpledb/storage"
+)
+
+// dirEntry is a directory entry pointing to the block whose entries start at the value
+type dirEntry struct {
+	dataval query.Constant
+	blknum  int
+}
+
+// btreeLeaf is a cursor over the leaf entries of a B-tree, in key order.
+//
+// Leaves are chained through their next sibling. When a leaf is full of entries having
+// one value, further entries with that value go into a chain of overflow blocks hanging
+// off the leaf's flag. The value of an overflow chain is always the first value of its
+// leaf, so the cursor visits the chain right after the leaf entries having that value.
+//
+// Block 0 is the leftmost leaf, so it is never an overflow block nor a next sibling:
+// 0 means none like -1 does, which keeps a page zeroed by a rollback a valid empty leaf.
+type btreeLeaf struct {
+	tx       record.Transaction
+	layout   *record.Layout
+	key      query.Constant
+	leaf     *btreePage // the current leaf, outside of any overflow chain
+	page     *btreePage // the page of the current entry: the leaf or one of its overflow blocks
+	slot     int
+	nextLeaf int
+	// resume is the leaf slot to continue from once the overflow chain has been visited, or -1
+	resume int
+	// overflowDone is true once the overflow chain of the current leaf needs no visit
+	overflowDone bool
+}
+
+// newBTreeLeaf opens the leaf and positions the cursor before the first entry not less than the key
+func newBTreeLeaf(tx record.Transaction, block *storage.Block, layout *record.Layout, key query.Constant) (*btreeLeaf, error) {
+	page, err := newBTreePage(tx, block, layout)
+	if err != nil {
+		return nil, err
+	}
+	bl := &btreeLeaf{
+		tx:     tx,
+		layout: layout,
+		key:    key,
+		leaf:   page,
+		page:   page,
+		resume: -1,
+	}
+	err = bl.enterLeaf()
+	if err == nil {
+		bl.slot, err = page.findSlotBefore(key)
+	}
+	if err == nil {
+		// the chain holds the first value, which the cursor is past if the key is greater
+		var first query.Constant
+		first, err = bl.firstVal()
+		bl.overflowDone = bl.overflowDone || first.Compare(key) < 0
+	}
+	if err != nil {
+		page.close()
+		return nil, err
+	}
+	return bl, nil
+}
+
+// close unpins the pages of the cursor
+func (bl *btreeLeaf) close() {
+	if bl.page != bl.leaf {
+		bl.page.close()
+	}
+	bl.leaf.close()
+}
+
+// next moves to the next entry in key order and returns false at the end of the index
+func (bl *btreeLeaf) next() (bool, error) {
+	for {
+		bl.slot++
+		n, err := bl.page.numRecs()
+		if err != nil {
+			return false, err
+		}
+
+		if bl.page != bl.leaf {
+			if bl.slot < n {
+				return true, nil
+			}
+			// go on with the chain, then back to the leaf
+			overflow, err := bl.page.flag()
+			if err != nil {
+				return false, err
+			}
+			bl.page.close()
+			bl.page = bl.leaf
+			if overflow <= 0 {
+				bl.slot = bl.resume - 1
+				bl.resume = -1
+				bl.overflowDone = true
+				continue
+			}
+			bl.page, err = newBTreePage(bl.tx, storage.NewBlock(bl.leaf.block.Filename, overflow), bl.layout)
+			if err != nil {
+				bl.page = bl.leaf
+				return false, err
+			}
+			bl.slot = -1
+			continue
+		}
+
+		if !bl.overflowDone && bl.slot > 0 {
+			passed, err := bl.passedFirstVal(n)
+			if err != nil {
+				return false, err
+			}
+			if passed {
+				overflow, err := bl.leaf.flag()
+				if err != nil {
+					return false, err
+				}
+				bl.page, err = newBTreePage(bl.tx, storage.NewBlock(bl.leaf.block.Filename, overflow), bl.layout)
+				if err != nil {
+					bl.page = bl.leaf
+					return false, err
+				}
+				bl.resume = bl.slot
+				bl.slot = -1
+				continue
+			}
+		}
+		if bl.slot < n {
+			return true, nil
+		}
+
+		// go on with the next sibling
+		if bl.nextLeaf <= 0 {
+			bl.slot = n
+			return false, nil
+		}
+		bl.leaf.close()
+		bl.leaf, err = newBTreePage(bl.tx, storage.NewBlock(bl.leaf.block.Filename, bl.nextLeaf), bl.layout)
+		if err != nil {
+			return false, err
+		}
+		bl.page = bl.leaf
+		bl.slot = -1
+		err = bl.enterLeaf()
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// dataVal returns the value of the current entry
+func (bl *btreeLeaf) dataVal() (query.Constant, error) {
+	return bl.page.dataVal(bl.slot)
+}
+
+// dataRID returns the RID of the current entry
+func (bl *btreeLeaf) dataRID() (record.RID, error) {
+	return bl.page.dataRID(bl.slot)
+}
+
+// insert adds an entry for the key of the cursor, which must not have moved since it was opened.
+// It returns the directory entry of the new leaf if the leaf had to be split.
+func (bl *btreeLeaf) insert(rid record.RID) (*dirEntry, error) {
+	page := bl.leaf
+	overflow, err := page.flag()
+	if err != nil {
+		return nil, err
+	}
+	if overflow > 0 {
+		first, err := page.dataVal(0)
+		if err != nil {
+			return nil, err
+		}
+		if first.Compare(bl.key) > 0 {
+			// the key comes before the overflow value: move everything into a new leaf
+			next, err := page.next()
+			if err != nil {
+				return nil, err
+			}
+			newblk, err := page.split(0, overflow, next)
+			if err != nil {
+				return nil, err
+			}
+			err = page.setNext(newblk.Num)
+			if err != nil {
+				return nil, err
+			}
+			err = page.setFlag(-1)
+			if err != nil {
+				return nil, err
+			}
+			err = page.insertLeaf(0, bl.key, rid)
+			if err != nil {
+				return nil, err
+			}
+			bl.slot = 0
+			return &dirEntry{dataval: first, blknum: newblk.Num}, nil
+		}
+	}
+
+	bl.slot++
+	err = page.insertLeaf(bl.slot, bl.key, rid)
+	if err != nil {
+		return nil, err
+	}
+	full, err := page.isFull()
+	if err != nil || !full {
+		return nil, err
+	}
+
+	// the leaf is full, split it
+	n, err := page.numRecs()
+	if err != nil {
+		return nil, err
+	}
+	first, err := page.dataVal(0)
+	if err != nil {
+		return nil, err
+	}
+	last, err := page.dataVal(n - 1)
+	if err != nil {
+		return nil, err
+	}
+	if first.Equals(last) {
+		// every entry has the same value: keep one and move the others to an overflow block
+		newblk, err := page.split(1, overflow, -1)
+		if err != nil {
+			return nil, err
+		}
+		return nil, page.setFlag(newblk.Num)
+	}
+
+	// split between two values, keeping the first value in this leaf
+	splitpos := n / 2
+	splitkey, err := page.dataVal(splitpos)
+	if err != nil {
+		return nil, err
+	}
+	if splitkey.Equals(first) {
+		for splitkey.Equals(first) {
+			splitpos++
+			splitkey, err = page.dataVal(splitpos)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for {
+			prev, err := page.dataVal(splitpos - 1)
+			if err != nil {
+				return nil, err
+			}
+			if !prev.Equals(splitkey) {
+				break
+			}
+			splitpos--
+		}
+	}
+	next, err := page.next()
+	if err != nil {
+		return nil, err
+	}
+	newblk, err := page.split(splitpos, -1, next)
+	if err != nil {
+		return nil, err
+	}
+	err = page.setNext(newblk.Num)
+	if err != nil {
+		return nil, err
+	}
+	return &dirEntry{dataval: splitkey, blknum: newblk.Num}, nil
+}
+
+// delete removes the entry having the key of the cursor and the RID.
+// It returns false if there is no such entry.
+func (bl *btreeLeaf) delete(rid record.RID) (bool, error) {
+	for {
+		ok, err := bl.next()
+		if err != nil || !ok {
+			return false, err
+		}
+		val, err := bl.dataVal()
+		if err != nil {
+			return false, err
+		}
+		if !val.Equals(bl.key) {
+			return false, nil
+		}
+		got, err := bl.dataRID()
+		if err != nil {
+			return false, err
+		}
+		if got == rid {
+			return true, bl.deleteCurrent()
+		}
+	}
+}
+
+// deleteCurrent removes the current entry. The leaf must keep an entry having the value of
+// its overflow chain, so the last one is replaced by an entry taken from the chain.
+func (bl *btreeLeaf) deleteCurrent() error {
+	if bl.page != bl.leaf {
+		return bl.page.delete(bl.slot)
+	}
+	overflow, err := bl.leaf.flag()
+	if err != nil {
+		return err
+	}
+	if overflow > 0 && bl.slot == 0 {
+		n, err := bl.leaf.numRecs()
+		if err != nil {
+			return err
+		}
+		onlyOne := n == 1
+		if !onlyOne {
+			first, err := bl.leaf.dataVal(0)
+			if err != nil {
+				return err
+			}
+			second, err := bl.leaf.dataVal(1)
+			if err != nil {
+				return err
+			}
+			onlyOne = !first.Equals(second)
+		}
+		if onlyOne {
+			pulled, err := bl.pullFromOverflow(overflow)
+			if err != nil || pulled {
+				return err
+			}
+			err = bl.leaf.setFlag(-1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return bl.leaf.delete(bl.slot)
+}
+
+// pullFromOverflow moves an entry of the overflow chain into the current leaf slot.
+// It returns false if the chain is empty.
+func (bl *btreeLeaf) pullFromOverflow(overflow int) (bool, error) {
+	for overflow > 0 {
+		page, err := newBTreePage(bl.tx, storage.NewBlock(bl.leaf.block.Filename, overflow), bl.layout)
+		if err != nil {
+			return false, err
+		}
+		n, err := page.numRecs()
+		if err == nil && n > 0 {
+			var rid record.RID
+			rid, err = page.dataRID(n - 1)
+			if err == nil {
+				err = page.delete(n - 1)
+			}
+			if err == nil {
+				err = bl.leaf.setLeaf(bl.slot, bl.key, rid)
+			}
+			page.close()
+			return err == nil, err
+		}
+		if err == nil {
+			overflow, err = page.flag()
+		}
+		page.close()
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// enterLeaf reads the header of the current leaf
+func (bl *btreeLeaf) enterLeaf() error {
+	var err error
+	bl.nextLeaf, err = bl.leaf.next()
+	if err != nil {
+		return err
+	}
+	overflow, err := bl.leaf.flag()
+	bl.overflowDone = overflow <= 0
+	return err
+}
+
+// passedFirstVal returns true if the cursor just moved past the leaf entries having the first value
+func (bl *btreeLeaf) passedFirstVal(n int) (bool, error) {
+	if bl.slot >= n {
+		return true, nil
+	}
+	first, err := bl.leaf.dataVal(0)
+	if err != nil {
+		return false, err
+	}
+	val, err := bl.leaf.dataVal(bl.slot)
+	if err != nil {
+		return false, err
+	}
+	return !val.Equals(first), nil
+}
+
+func (bl *btreeLeaf) firstVal() (query.Constant, error) {
+	n, err := bl.leaf.numRecs()
+	if err != nil || n == 0 {
+		return bl.key, err
+	}
+	return bl.leaf.dataVal(0)
+}
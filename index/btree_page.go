@@ -0,0 +1,313 @@
+package index
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"simpledb/storage"
+)
+
+// Header of a B-tree page
+const (
+	btreeFlagOffset    = 0 // the level of a directory page, the overflow block of a leaf page
+	btreeNumRecsOffset = 4
+	btreeNextOffset    = 8 // the next sibling of a leaf page
+	btreeHeaderSize    = 12
+)
+
+// btreePage stores the sorted entries of a B-tree node in the slots of a block.
+// Every change goes through the transaction, so it is logged and recovered like table data.
+type btreePage struct {
+	tx     record.Transaction
+	block  *storage.Block
+	layout *record.Layout
+}
+
+// newBTreePage pins the block
+func newBTreePage(tx record.Transaction, block *storage.Block, layout *record.Layout) (*btreePage, error) {
+	err := tx.Pin(block)
+	if err != nil {
+		return nil, err
+	}
+	return &btreePage{
+		tx:     tx,
+		block:  block,
+		layout: layout,
+	}, nil
+}
+
+// close unpins the block
+func (bp *btreePage) close() {
+	bp.tx.Unpin(bp.block)
+}
+
+// findSlotBefore returns the slot of the last entry whose value is less than the key, or -1
+func (bp *btreePage) findSlotBefore(key query.Constant) (int, error) {
+	n, err := bp.numRecs()
+	if err != nil {
+		return -1, err
+	}
+	slot := 0
+	for ; slot < n; slot++ {
+		val, err := bp.dataVal(slot)
+		if err != nil {
+			return -1, err
+		}
+		if val.Compare(key) >= 0 {
+			break
+		}
+	}
+	return slot - 1, nil
+}
+
+// isFull returns true if there is no room for another entry
+func (bp *btreePage) isFull() (bool, error) {
+	n, err := bp.numRecs()
+	if err != nil {
+		return false, err
+	}
+	return bp.slotPos(n+1) >= bp.tx.BlockSize(), nil
+}
+
+// split moves the entries from splitpos on into a new block and returns it.
+// The new page gets the flag and the next sibling.
+func (bp *btreePage) split(splitpos, flag, next int) (*storage.Block, error) {
+	block, err := bp.appendNew(flag)
+	if err != nil {
+		return nil, err
+	}
+	newpage, err := newBTreePage(bp.tx, block, bp.layout)
+	if err != nil {
+		return nil, err
+	}
+	defer newpage.close()
+	err = bp.transferRecs(splitpos, newpage)
+	if err != nil {
+		return nil, err
+	}
+	err = newpage.setNext(next)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (bp *btreePage) dataVal(slot int) (query.Constant, error) {
+	return bp.getVal(slot, "dataval")
+}
+
+func (bp *btreePage) flag() (int, error) {
+	n, err := bp.tx.GetInt32(bp.block, btreeFlagOffset)
+	return int(n), err
+}
+
+func (bp *btreePage) setFlag(flag int) error {
+	return bp.tx.SetInt32(bp.block, btreeFlagOffset, int32(flag))
+}
+
+func (bp *btreePage) next() (int, error) {
+	n, err := bp.tx.GetInt32(bp.block, btreeNextOffset)
+	return int(n), err
+}
+
+func (bp *btreePage) setNext(next int) error {
+	return bp.tx.SetInt32(bp.block, btreeNextOffset, int32(next))
+}
+
+func (bp *btreePage) numRecs() (int, error) {
+	n, err := bp.tx.GetInt32(bp.block, btreeNumRecsOffset)
+	return int(n), err
+}
+
+// appendNew appends a formatted block to the file of the page
+func (bp *btreePage) appendNew(flag int) (*storage.Block, error) {
+	block, err := bp.tx.Append(bp.block.Filename)
+	if err != nil {
+		return nil, err
+	}
+	page, err := newBTreePage(bp.tx, block, bp.layout)
+	if err != nil {
+		return nil, err
+	}
+	defer page.close()
+	err = page.format(flag)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// format empties the page
+func (bp *btreePage) format(flag int) error {
+	err := bp.setFlag(flag)
+	if err != nil {
+		return err
+	}
+	err = bp.setNumRecs(0)
+	if err != nil {
+		return err
+	}
+	return bp.setNext(-1)
+}
+
+// childNum returns the child block of a directory entry
+func (bp *btreePage) childNum(slot int) (int, error) {
+	n, err := bp.getInt(slot, "block")
+	return int(n), err
+}
+
+// insertDir inserts a directory entry at the slot
+func (bp *btreePage) insertDir(slot int, val query.Constant, blknum int) error {
+	err := bp.insert(slot)
+	if err != nil {
+		return err
+	}
+	err = bp.setVal(slot, "dataval", val)
+	if err != nil {
+		return err
+	}
+	return bp.setInt(slot, "block", int32(blknum))
+}
+
+// dataRID returns the RID of a leaf entry
+func (bp *btreePage) dataRID(slot int) (record.RID, error) {
+	block, err := bp.getInt(slot, "block")
+	if err != nil {
+		return record.RID{}, err
+	}
+	id, err := bp.getInt(slot, "id")
+	if err != nil {
+		return record.RID{}, err
+	}
+	return record.NewRID(int(block), int(id)), nil
+}
+
+// insertLeaf inserts a leaf entry at the slot
+func (bp *btreePage) insertLeaf(slot int, val query.Constant, rid record.RID) error {
+	err := bp.insert(slot)
+	if err != nil {
+		return err
+	}
+	return bp.setLeaf(slot, val, rid)
+}
+
+func (bp *btreePage) setLeaf(slot int, val query.Constant, rid record.RID) error {
+	err := bp.setVal(slot, "dataval", val)
+	if err != nil {
+		return err
+	}
+	err = bp.setInt(slot, "block", int32(rid.BlockNum))
+	if err != nil {
+		return err
+	}
+	return bp.setInt(slot, "id", int32(rid.Slot))
+}
+
+// delete removes the entry at the slot, shifting the following entries left
+func (bp *btreePage) delete(slot int) error {
+	n, err := bp.numRecs()
+	if err != nil {
+		return err
+	}
+	for i := slot + 1; i < n; i++ {
+		err = bp.copyRecord(i, i-1)
+		if err != nil {
+			return err
+		}
+	}
+	return bp.setNumRecs(n - 1)
+}
+
+// insert makes room at the slot, shifting the following entries right
+func (bp *btreePage) insert(slot int) error {
+	n, err := bp.numRecs()
+	if err != nil {
+		return err
+	}
+	for i := n; i > slot; i-- {
+		err = bp.copyRecord(i-1, i)
+		if err != nil {
+			return err
+		}
+	}
+	return bp.setNumRecs(n + 1)
+}
+
+func (bp *btreePage) setNumRecs(n int) error {
+	return bp.tx.SetInt32(bp.block, btreeNumRecsOffset, int32(n))
+}
+
+func (bp *btreePage) copyRecord(from, to int) error {
+	for _, field := range bp.layout.Schema().Fields() {
+		val, err := bp.getVal(from, field)
+		if err != nil {
+			return err
+		}
+		err = bp.setVal(to, field, val)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transferRecs moves the entries from the slot on to the end of dest
+func (bp *btreePage) transferRecs(slot int, dest *btreePage) error {
+	n, err := bp.numRecs()
+	if err != nil {
+		return err
+	}
+	destslot, err := dest.numRecs()
+	if err != nil {
+		return err
+	}
+	for i := slot; i < n; i++ {
+		err = dest.insert(destslot)
+		if err != nil {
+			return err
+		}
+		for _, field := range bp.layout.Schema().Fields() {
+			val, err := bp.getVal(i, field)
+			if err != nil {
+				return err
+			}
+			err = dest.setVal(destslot, field, val)
+			if err != nil {
+				return err
+			}
+		}
+		destslot++
+	}
+	return bp.setNumRecs(slot)
+}
+
+func (bp *btreePage) getInt(slot int, field string) (int32, error) {
+	return bp.tx.GetInt32(bp.block, bp.fieldPos(slot, field))
+}
+
+func (bp *btreePage) setInt(slot int, field string, val int32) error {
+	return bp.tx.SetInt32(bp.block, bp.fieldPos(slot, field), val)
+}
+
+func (bp *btreePage) getVal(slot int, field string) (query.Constant, error) {
+	if bp.layout.Schema().Type(field) == record.FieldType_INTEGER {
+		n, err := bp.getInt(slot, field)
+		return query.NewIntConstant(n), err
+	}
+	s, err := bp.tx.GetString(bp.block, bp.fieldPos(slot, field))
+	return query.NewStringConstant(s), err
+}
+
+func (bp *btreePage) setVal(slot int, field string, val query.Constant) error {
+	if bp.layout.Schema().Type(field) == record.FieldType_INTEGER {
+		return bp.setInt(slot, field, val.AsInt())
+	}
+	return bp.tx.SetString(bp.block, bp.fieldPos(slot, field), val.AsString())
+}
+
+func (bp *btreePage) fieldPos(slot int, field string) int {
+	return bp.slotPos(slot) + bp.layout.Offset(field)
+}
+
+func (bp *btreePage) slotPos(slot int) int {
+	return btreeHeaderSize + slot*bp.layout.SlotSize()
+}
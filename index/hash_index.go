@@ -0,0 +1,126 @@
+package index
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"strconv"
+)
+
+// NumBuckets is the number of buckets of a hash index
+const NumBuckets = 100
+
+// HashIndex is a static hash index. Entries are hashed on their value into NumBuckets buckets,
+// each stored as a table <index><bucket> of (dataval, block, id) records.
+type HashIndex struct {
+	tx        record.Transaction
+	idxname   string
+	layout    *record.Layout
+	searchKey query.Constant
+	ts        *query.TableScan
+}
+
+// NewHashIndex opens the index. The layout is the one of the index records.
+func NewHashIndex(tx record.Transaction, idxname string, layout *record.Layout) *HashIndex {
+	return &HashIndex{
+		tx:      tx,
+		idxname: idxname,
+		layout:  layout,
+	}
+}
+
+// BeforeFirst opens the bucket of the search key
+func (hi *HashIndex) BeforeFirst(searchKey query.Constant) error {
+	hi.Close()
+	hi.searchKey = searchKey
+	bucket := searchKey.HashCode() % NumBuckets
+	if bucket < 0 {
+		bucket += NumBuckets
+	}
+	ts, err := query.NewTableScan(hi.tx, hi.idxname+strconv.Itoa(bucket), hi.layout)
+	if err != nil {
+		return err
+	}
+	hi.ts = ts
+	return nil
+}
+
+func (hi *HashIndex) Next() (bool, error) {
+	for {
+		ok, err := hi.ts.Next()
+		if err != nil || !ok {
+			return false, err
+		}
+		val, err := hi.ts.GetVal("dataval")
+		if err != nil {
+			return false, err
+		}
+		if val.Equals(hi.searchKey) {
+			return true, nil
+		}
+	}
+}
+
+func (hi *HashIndex) GetDataRID() (record.RID, error) {
+	block, err := hi.ts.GetInt("block")
+	if err != nil {
+		return record.RID{}, err
+	}
+	id, err := hi.ts.GetInt("id")
+	if err != nil {
+		return record.RID{}, err
+	}
+	return record.NewRID(int(block), int(id)), nil
+}
+
+func (hi *HashIndex) Insert(val query.Constant, rid record.RID) error {
+	err := hi.BeforeFirst(val)
+	if err != nil {
+		return err
+	}
+	err = hi.ts.Insert()
+	if err != nil {
+		return err
+	}
+	err = hi.ts.SetInt("block", int32(rid.BlockNum))
+	if err != nil {
+		return err
+	}
+	err = hi.ts.SetInt("id", int32(rid.Slot))
+	if err != nil {
+		return err
+	}
+	return hi.ts.SetVal("dataval", val)
+}
+
+func (hi *HashIndex) Delete(val query.Constant, rid record.RID) error {
+	err := hi.BeforeFirst(val)
+	if err != nil {
+		return err
+	}
+	for {
+		ok, err := hi.Next()
+		if err != nil || !ok {
+			return err
+		}
+		got, err := hi.GetDataRID()
+		if err != nil {
+			return err
+		}
+		if got == rid {
+			return hi.ts.Delete()
+		}
+	}
+}
+
+func (hi *HashIndex) Close() {
+	if hi.ts != nil {
+		hi.ts.Close()
+		hi.ts = nil
+	}
+}
+
+// HashSearchCost estimates the number of block accesses to find the entries of a value,
+// given the number of blocks of the index and the number of entries per block
+func HashSearchCost(numBlocks, rpb int) int {
+	return numBlocks / NumBuckets
+}
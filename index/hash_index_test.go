@@ -0,0 +1,72 @@
+package index
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func indexLayout(typ record.FieldType) *record.Layout {
+	schema := record.NewSchema()
+	schema.AddIntField("block")
+	schema.AddIntField("id")
+	schema.AddField("dataval", typ, 10)
+	return record.NewLayout(schema)
+}
+
+func search(t *testing.T, idx Index, key query.Constant) []record.RID {
+	t.Helper()
+	require.NoError(t, idx.BeforeFirst(key))
+	got := []record.RID{}
+	for {
+		ok, err := idx.Next()
+		require.NoError(t, err)
+		if !ok {
+			return got
+		}
+		rid, err := idx.GetDataRID()
+		require.NoError(t, err)
+		got = append(got, rid)
+	}
+}
+
+func TestHashIndex(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	idx := NewHashIndex(tx, "idx", indexLayout(record.FieldType_INTEGER))
+
+	// 7 and 107 share a bucket, -3 hashes to a negative code
+	keys := []int32{7, 107, -3, 7, 7}
+	for i, k := range keys {
+		require.NoError(t, idx.Insert(query.NewIntConstant(k), record.NewRID(i, i)))
+	}
+	require.Equal(t, []record.RID{record.NewRID(0, 0), record.NewRID(3, 3), record.NewRID(4, 4)}, search(t, idx, query.NewIntConstant(7)))
+	require.Equal(t, []record.RID{record.NewRID(1, 1)}, search(t, idx, query.NewIntConstant(107)))
+	require.Equal(t, []record.RID{record.NewRID(2, 2)}, search(t, idx, query.NewIntConstant(-3)))
+	require.Empty(t, search(t, idx, query.NewIntConstant(8)))
+
+	require.NoError(t, idx.Delete(query.NewIntConstant(7), record.NewRID(3, 3)))
+	require.NoError(t, idx.Delete(query.NewIntConstant(7), record.NewRID(9, 9))) // not indexed
+	require.Equal(t, []record.RID{record.NewRID(0, 0), record.NewRID(4, 4)}, search(t, idx, query.NewIntConstant(7)))
+
+	idx.Close()
+	require.Empty(t, tx.Pinned())
+}
+
+func TestHashIndex_string(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	idx := NewHashIndex(tx, "idx", indexLayout(record.FieldType_VARCHAR))
+
+	for i := 0; i < 30; i++ {
+		name := []string{"amy", "bob", "carl"}[i%3]
+		require.NoError(t, idx.Insert(query.NewStringConstant(name), record.NewRID(i/4, i%4)))
+	}
+	require.Len(t, search(t, idx, query.NewStringConstant("bob")), 10)
+	require.Len(t, search(t, idx, query.NewStringConstant("carl")), 10)
+	require.Empty(t, search(t, idx, query.NewStringConstant("dave")))
+
+	idx.Close()
+	require.Empty(t, tx.Pinned())
+}
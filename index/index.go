@@ -0,0 +1,21 @@
+package index
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// Index maps the values of a field to the RIDs of the records having them
+type Index interface {
+	// BeforeFirst positions the index before the first entry having the search key
+	BeforeFirst(searchKey query.Constant) error
+	// Next moves to the next entry having the search key and returns false when there is none
+	Next() (bool, error)
+	// GetDataRID returns the RID stored in the current entry
+	GetDataRID() (record.RID, error)
+	// Insert adds an entry for the value and the RID
+	Insert(val query.Constant, rid record.RID) error
+	// Delete removes the entry for the value and the RID
+	Delete(val query.Constant, rid record.RID) error
+	Close()
+}
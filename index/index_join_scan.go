@@ -0,0 +1,110 @@
+package index
+
+import (
+	"simpledb/query"
+)
+
+// IndexJoinScan joins each record of lhs with the records of the table rhs
+// whose indexed field equals the join field of lhs
+type IndexJoinScan struct {
+	lhs       query.Scan
+	idx       Index
+	joinfield string
+	rhs       *query.TableScan
+	hasLHS    bool
+}
+
+// NewIndexJoinScan returns a scan positioned before its first record
+func NewIndexJoinScan(lhs query.Scan, idx Index, joinfield string, rhs *query.TableScan) (*IndexJoinScan, error) {
+	s := &IndexJoinScan{
+		lhs:       lhs,
+		idx:       idx,
+		joinfield: joinfield,
+		rhs:       rhs,
+	}
+	err := s.BeforeFirst()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *IndexJoinScan) BeforeFirst() error {
+	err := s.lhs.BeforeFirst()
+	if err != nil {
+		return err
+	}
+	s.hasLHS, err = s.lhs.Next()
+	if err != nil {
+		return err
+	}
+	return s.resetIndex()
+}
+
+func (s *IndexJoinScan) Next() (bool, error) {
+	for s.hasLHS {
+		ok, err := s.idx.Next()
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			rid, err := s.idx.GetDataRID()
+			if err != nil {
+				return false, err
+			}
+			return true, s.rhs.MoveToRID(rid)
+		}
+		s.hasLHS, err = s.lhs.Next()
+		if err != nil {
+			return false, err
+		}
+		err = s.resetIndex()
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (s *IndexJoinScan) GetInt(field string) (int32, error) {
+	if s.rhs.HasField(field) {
+		return s.rhs.GetInt(field)
+	}
+	return s.lhs.GetInt(field)
+}
+
+func (s *IndexJoinScan) GetString(field string) (string, error) {
+	if s.rhs.HasField(field) {
+		return s.rhs.GetString(field)
+	}
+	return s.lhs.GetString(field)
+}
+
+func (s *IndexJoinScan) GetVal(field string) (query.Constant, error) {
+	if s.rhs.HasField(field) {
+		return s.rhs.GetVal(field)
+	}
+	return s.lhs.GetVal(field)
+}
+
+func (s *IndexJoinScan) HasField(field string) bool {
+	return s.rhs.HasField(field) || s.lhs.HasField(field)
+}
+
+func (s *IndexJoinScan) Close() {
+	s.lhs.Close()
+	s.idx.Close()
+	s.rhs.Close()
+}
+
+// resetIndex positions the index on the join value of the current lhs record
+func (s *IndexJoinScan) resetIndex() error {
+	if !s.hasLHS {
+		return nil
+	}
+	val, err := s.lhs.GetVal(s.joinfield)
+	if err != nil {
+		return err
+	}
+	return s.idx.BeforeFirst(val)
+}
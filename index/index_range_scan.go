@@ -0,0 +1,79 @@
+package index
+
+import (
+	"simpledb/query"
+)
+
+// IndexRangeScan outputs the records of a table whose field lies between two values,
+// found through a B-tree index on the field. A nil bound leaves that side open.
+// Both bounds are inclusive.
+type IndexRangeScan struct {
+	ts  *query.TableScan
+	idx RangeIndex
+	lo  *query.Constant
+	hi  *query.Constant
+}
+
+// NewIndexRangeScan returns a scan positioned before the first matching record
+func NewIndexRangeScan(ts *query.TableScan, idx RangeIndex, lo, hi *query.Constant) (*IndexRangeScan, error) {
+	s := &IndexRangeScan{
+		ts:  ts,
+		idx: idx,
+		lo:  lo,
+		hi:  hi,
+	}
+	err := s.BeforeFirst()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *IndexRangeScan) BeforeFirst() error {
+	if s.lo == nil {
+		return s.idx.Rewind()
+	}
+	return s.idx.Seek(*s.lo)
+}
+
+func (s *IndexRangeScan) Next() (bool, error) {
+	ok, err := s.idx.Next()
+	if err != nil || !ok {
+		return false, err
+	}
+	if s.hi != nil {
+		val, err := s.idx.GetDataVal()
+		if err != nil {
+			return false, err
+		}
+		if val.Compare(*s.hi) > 0 {
+			return false, nil
+		}
+	}
+	rid, err := s.idx.GetDataRID()
+	if err != nil {
+		return false, err
+	}
+	return true, s.ts.MoveToRID(rid)
+}
+
+func (s *IndexRangeScan) GetInt(field string) (int32, error) {
+	return s.ts.GetInt(field)
+}
+
+func (s *IndexRangeScan) GetString(field string) (string, error) {
+	return s.ts.GetString(field)
+}
+
+func (s *IndexRangeScan) GetVal(field string) (query.Constant, error) {
+	return s.ts.GetVal(field)
+}
+
+func (s *IndexRangeScan) HasField(field string) bool {
+	return s.ts.HasField(field)
+}
+
+func (s *IndexRangeScan) Close() {
+	s.idx.Close()
+	s.ts.Close()
+}
@@ -0,0 +1,63 @@
+package index
+
+import (
+	"simpledb/query"
+)
+
+// IndexSelectScan outputs the records of a table having a value, found through an index on the field
+type IndexSelectScan struct {
+	ts  *query.TableScan
+	idx Index
+	val query.Constant
+}
+
+// NewIndexSelectScan returns a scan positioned before the first matching record
+func NewIndexSelectScan(ts *query.TableScan, idx Index, val query.Constant) (*IndexSelectScan, error) {
+	s := &IndexSelectScan{
+		ts:  ts,
+		idx: idx,
+		val: val,
+	}
+	err := s.BeforeFirst()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *IndexSelectScan) BeforeFirst() error {
+	return s.idx.BeforeFirst(s.val)
+}
+
+func (s *IndexSelectScan) Next() (bool, error) {
+	ok, err := s.idx.Next()
+	if err != nil || !ok {
+		return false, err
+	}
+	rid, err := s.idx.GetDataRID()
+	if err != nil {
+		return false, err
+	}
+	return true, s.ts.MoveToRID(rid)
+}
+
+func (s *IndexSelectScan) GetInt(field string) (int32, error) {
+	return s.ts.GetInt(field)
+}
+
+func (s *IndexSelectScan) GetString(field string) (string, error) {
+	return s.ts.GetString(field)
+}
+
+func (s *IndexSelectScan) GetVal(field string) (query.Constant, error) {
+	return s.ts.GetVal(field)
+}
+
+func (s *IndexSelectScan) HasField(field string) bool {
+	return s.ts.HasField(field)
+}
+
+func (s *IndexSelectScan) Close() {
+	s.idx.Close()
+	s.ts.Close()
+}
@@ -4,8 +4,12 @@ import (
 	"errors"
 	"simpledb/log/record"
 	"simpledb/storage"
+	"slices"
+	"sync"
 )
 
+var ErrRecordTooLarge = errors.New("log record does not fit in a block")
+
 type Logger interface {
 	Flush(lsn int) error
 	Append(record []byte) (int, error)
@@ -21,7 +25,9 @@ type TxLogger interface {
 	SetString(txid int, block *storage.Block, offset int, old, new string) (int, error)
 }
 
+// LogManager is safe for concurrent use
 type LogManager struct {
+	mu         sync.Mutex
 	fileMng    storage.FileManager
 	fileName   string
 	page       *storage.Page
@@ -75,21 +81,46 @@ func (lm *LogManager) appendNewBlock() (*storage.Block, error) {
 	return block, nil
 }
 
+// Flush makes the record with the LSN durable, along with every older record.
+// It writes the current log page unless the record is already saved, and syncs the log
+// without holding the lock, so that concurrent flushes can share a sync.
 func (lm *LogManager) Flush(lsn int) error {
+	lm.mu.Lock()
+	if lsn <= lm.savedLSN {
+		lm.mu.Unlock()
+		return nil
+	}
 	err := lm.fileMng.Write(lm.currentBlk, lm.page)
+	// every record up to target is written once the sync returns
+	target := lm.CurrentLSN
+	lm.mu.Unlock()
 	if err != nil {
 		return err
 	}
-	if lsn > lm.CurrentLSN {
-		lm.savedLSN = lm.CurrentLSN
-	} else {
-		lm.savedLSN = lsn
+
+	err = lm.fileMng.Sync(lm.fileName)
+	if err != nil {
+		return err
 	}
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	lm.savedLSN = max(lm.savedLSN, target)
 	return nil
 }
 
-// Append appends a log record to the end of the log file
+// SavedLSN returns the LSN of the newest record known to be on disk
+func (lm *LogManager) SavedLSN() int {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return lm.savedLSN
+}
+
+// Append appends a log record to the end of the log file and returns its LSN.
+// LSNs start from 1 each time the log is opened, since the records written
+// before are already on disk.
 func (lm *LogManager) Append(record []byte) (int, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
 	var boundary int32
 	var index int
 	var err error
@@ -98,21 +129,24 @@ func (lm *LogManager) Append(record []byte) (int, error) {
 		return 0, err
 	}
 	index = int(boundary) - len(record) - 4
-	if index == 0 {
-		// TODO: blocksizeとrecordのサイズが同じ場合の処理
-		return 0, errors.New("blocksize and record size are same")
-	} else if index < 0 {
-		err = lm.Flush(lm.CurrentLSN)
+	if index < 4 {
+		// the record does not fit between the boundary header and the
+		// previous record, so move on to a fresh block. The full block is
+		// synced by the next flush, which syncs the whole file.
+		err = lm.fileMng.Write(lm.currentBlk, lm.page)
 		if err != nil {
 			return 0, err
 		}
-		lm.appendNewBlock()
-		err = lm.page.SetInt32(0, int32(lm.fileMng.Blocksize()))
+		block, err := lm.appendNewBlock()
 		if err != nil {
 			return 0, err
 		}
+		lm.currentBlk = block
 		boundary = int32(lm.fileMng.Blocksize())
 		index = int(boundary) - len(record) - 4
+		if index < 4 {
+			return 0, ErrRecordTooLarge
+		}
 	}
 	err = lm.page.SetBytes(index, record)
 	if err != nil {
@@ -122,10 +156,19 @@ func (lm *LogManager) Append(record []byte) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	return len(record) + 4, nil
+	lm.CurrentLSN++
+	return lm.CurrentLSN, nil
 }
 
+// Iterator flushes the current log page and returns an iterator
+// which walks the records from the newest to the oldest
 func (lm *LogManager) Iterator() (*LogIterator, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	err := lm.fileMng.Write(lm.currentBlk, lm.page)
+	if err != nil {
+		return nil, err
+	}
 	return NewLogIterator(lm.fileMng, lm.currentBlk)
 }
 
@@ -145,10 +188,13 @@ func (lm *LogManager) Start(txid int) error {
 	}
 
 	_, err = lm.Append(p.Buf)
-	return nil
+	return err
 }
 
 func (lm *LogManager) Commit(txid int) error {
+	// <COMMIT, txid>
+	// the record is flushed immediately so that the outcome of the
+	// transaction survives a crash
 	p := storage.NewPage(8)
 	err := p.SetInt32(0, record.Instruction_COMMIT)
 	if err != nil {
@@ -160,11 +206,17 @@ func (lm *LogManager) Commit(txid int) error {
 		return err
 	}
 
-	_, err = lm.Append(p.Buf)
-	return nil
+	lsn, err := lm.Append(p.Buf)
+	if err != nil {
+		return err
+	}
+	return lm.Flush(lsn)
 }
 
 func (lm *LogManager) Rollback(txid int) error {
+	// <ROLLBACK, txid>
+	// the record is flushed immediately so that the outcome of the
+	// transaction survives a crash
 	p := storage.NewPage(8)
 	err := p.SetInt32(0, record.Instruction_ROLLBACK)
 	if err != nil {
@@ -176,15 +228,35 @@ func (lm *LogManager) Rollback(txid int) error {
 		return err
 	}
 
-	_, err = lm.Append(p.Buf)
-	return nil
+	lsn, err := lm.Append(p.Buf)
+	if err != nil {
+		return err
+	}
+	return lm.Flush(lsn)
+}
+
+// Checkpoint appends a quiescent checkpoint record and flushes the log.
+// Recovery never needs to look at records older than a checkpoint.
+func (lm *LogManager) Checkpoint() error {
+	// <CHECKPOINT>
+	p := storage.NewPage(4)
+	err := p.SetInt32(0, record.Instruction_CHECKPOINT)
+	if err != nil {
+		return err
+	}
+
+	lsn, err := lm.Append(p.Buf)
+	if err != nil {
+		return err
+	}
+	return lm.Flush(lsn)
 }
 
 func (lm *LogManager) SetInt32(txid int, block *storage.Block, offset int, old, new int32) (int, error) {
 	// <SETINT32, txid, filename, blknum, offset, oldvalue, newvalue>
-	size := 24 + len(block.Filename)
+	size := 28 + len(block.Filename)
 	p := storage.NewPage(size)
-	cur := offset
+	cur := 0
 	err := p.SetInt32(cur, record.Instruction_SETINT32)
 	if err != nil {
 		return 0, err
@@ -203,6 +275,12 @@ func (lm *LogManager) SetInt32(txid int, block *storage.Block, offset int, old,
 	}
 
 	cur += 4 + len(block.Filename)
+	err = p.SetInt32(cur, int32(block.Num))
+	if err != nil {
+		return 0, err
+	}
+
+	cur += 4
 	err = p.SetInt32(cur, int32(offset))
 	if err != nil {
 		return 0, err
@@ -219,15 +297,14 @@ func (lm *LogManager) SetInt32(txid int, block *storage.Block, offset int, old,
 	if err != nil {
 		return 0, err
 	}
-	_, err = lm.Append(p.Buf)
-	return size, err
+	return lm.Append(p.Buf)
 }
 
 func (lm *LogManager) SetString(txid int, block *storage.Block, offset int, old, new string) (int, error) {
 	// <SETSTRING, txid, filename, blknum, offset, oldvalue, newvalue>
-	size := 24 + len(block.Filename) + len(old) + len(new)
+	size := 28 + len(block.Filename) + len(old) + len(new)
 	p := storage.NewPage(size)
-	cur := offset
+	cur := 0
 	err := p.SetInt32(cur, record.Instruction_SETSTRING)
 	if err != nil {
 		return 0, err
@@ -246,6 +323,12 @@ func (lm *LogManager) SetString(txid int, block *storage.Block, offset int, old,
 	}
 
 	cur += 4 + len(block.Filename)
+	err = p.SetInt32(cur, int32(block.Num))
+	if err != nil {
+		return 0, err
+	}
+
+	cur += 4
 	err = p.SetInt32(cur, int32(offset))
 	if err != nil {
 		return 0, err
@@ -262,8 +345,7 @@ func (lm *LogManager) SetString(txid int, block *storage.Block, offset int, old,
 	if err != nil {
 		return 0, err
 	}
-	_, err = lm.Append(p.Buf)
-	return size, err
+	return lm.Append(p.Buf)
 }
 
 type LogIterator struct {
@@ -314,5 +396,6 @@ func (i *LogIterator) Next() ([]byte, error) {
 	}
 
 	i.currentPos += len(result) + 4
-	return result, nil
+	// the page is reused for the previous block, so hand out a copy
+	return slices.Clone(result), nil
 }
@@ -2,6 +2,8 @@ package log
 
 import (
 	"simpledb/storage"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -28,7 +30,7 @@ func TestLogManger_Append(t *testing.T) {
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
 		0x00, 0x00, 0x00, 0x00, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f,
 	}, mng.page.Buf)
-	require.Equal(t, 4+len("Hello"), lsn)
+	require.Equal(t, 1, lsn)
 
 	lsn, err = mng.Append([]byte("World"))
 	require.NoError(t, err)
@@ -37,7 +39,7 @@ func TestLogManger_Append(t *testing.T) {
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x57, 0x6f, 0x72, 0x6c,
 		0x64, 0x00, 0x00, 0x00, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f,
 	}, mng.page.Buf)
-	require.Equal(t, 4+len("Hello"), lsn)
+	require.Equal(t, 2, lsn)
 
 	// out of bounds
 	lsn, err = mng.Append([]byte("Hello, World"))
@@ -47,7 +49,103 @@ func TestLogManger_Append(t *testing.T) {
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x48, 0x65,
 		0x6c, 0x6c, 0x6f, 0x2c, 0x20, 0x57, 0x6f, 0x72, 0x6c, 0x64,
 	}, mng.page.Buf)
-	require.Equal(t, 4+len("Hello, World"), lsn)
+	require.Equal(t, 3, lsn)
+}
+
+// syncRecorder records the files synced through it
+type syncRecorder struct {
+	*storage.NopFileManager
+	writes int
+	synced []string
+}
+
+func (r *syncRecorder) Write(block *storage.Block, page *storage.Page) error {
+	r.writes++
+	return nil
+}
+
+func (r *syncRecorder) Sync(filename string) error {
+	r.synced = append(r.synced, filename)
+	return nil
+}
+
+func TestLogManager_Flush(t *testing.T) {
+	fm := &syncRecorder{NopFileManager: storage.NewNopFileManager(30, []byte{})}
+	mng, err := NewLogManager(fm, "test.log")
+	require.NoError(t, err)
+	fm.writes = 0
+
+	lsn1, err := mng.Append([]byte("Hello"))
+	require.NoError(t, err)
+	lsn2, err := mng.Append([]byte("World"))
+	require.NoError(t, err)
+	require.Equal(t, 0, mng.SavedLSN())
+
+	// flushing a record saves the records appended before it as well
+	require.NoError(t, mng.Flush(lsn1))
+	require.Equal(t, lsn2, mng.SavedLSN())
+	require.Equal(t, 1, fm.writes)
+	require.Equal(t, []string{"test.log"}, fm.synced)
+
+	// a saved record needs no write
+	require.NoError(t, mng.Flush(lsn2))
+	require.Equal(t, 1, fm.writes)
+
+	// moving to a new block writes the full one, which the next flush syncs
+	lsn3, err := mng.Append([]byte("Hello, World"))
+	require.NoError(t, err)
+	require.Equal(t, lsn2, mng.SavedLSN())
+	require.Equal(t, 3, fm.writes)
+	require.Equal(t, []string{"test.log"}, fm.synced)
+	require.NoError(t, mng.Flush(lsn3))
+	require.Equal(t, lsn3, mng.SavedLSN())
+	require.Equal(t, []string{"test.log", "test.log"}, fm.synced)
+}
+
+// overlapRecorder records how many syncs are in progress at once
+type overlapRecorder struct {
+	storage.FileManager
+	syncing atomic.Int32
+	overlap atomic.Int32
+}
+
+func (r *overlapRecorder) Sync(filename string) error {
+	n := r.syncing.Add(1)
+	defer r.syncing.Add(-1)
+	for {
+		m := r.overlap.Load()
+		if n <= m || r.overlap.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	return r.FileManager.Sync(filename)
+}
+
+func TestLogManager_groupCommit(t *testing.T) {
+	dfm, err := storage.NewFileManager(t.TempDir(), 400,
+		storage.WithSyncPolicy(storage.SyncPolicy_GROUP), storage.WithGroupSyncInterval(50))
+	require.NoError(t, err)
+	defer dfm.Close()
+	fm := &overlapRecorder{FileManager: dfm}
+	mng, err := NewLogManager(fm, "test.log")
+	require.NoError(t, err)
+
+	// concurrent commits wait for the same round instead of one round each
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = mng.Commit(i)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Greater(t, fm.overlap.Load(), int32(1))
+	require.Equal(t, mng.CurrentLSN, mng.SavedLSN())
 }
 
 func TestLogManager_Start(t *testing.T) {
@@ -87,43 +185,45 @@ func TestLogManager_Rollback(t *testing.T) {
 }
 
 func TestLogManager_SetInt32(t *testing.T) {
-	mng, err := NewLogManager(storage.NewNopFileManager(36, []byte{}), "test.db")
+	mng, err := NewLogManager(storage.NewNopFileManager(40, []byte{}), "test.db")
 	require.NoError(t, err)
 
-	block := storage.NewBlock("test", 0)
+	block := storage.NewBlock("test", 2)
 
-	lsn, err := mng.SetInt32(1, block, 0, 10, 20)
+	lsn, err := mng.SetInt32(1, block, 8, 10, 20)
 	require.NoError(t, err)
-	require.Equal(t, 28, lsn)
+	require.Equal(t, 1, lsn)
 
 	require.Equal(t, []byte{
 		0x00, 0x00, 0x00, 0x04,
-		0x00, 0x00, 0x00, 0x1c,
+		0x00, 0x00, 0x00, 0x20,
 		0x00, 0x00, 0x00, 0x07,
 		0x00, 0x00, 0x00, 0x01,
 		0x00, 0x00, 0x00, 0x04, 0x74, 0x65, 0x73, 0x74,
-		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x08,
 		0x00, 0x00, 0x00, 0x0a,
 		0x00, 0x00, 0x00, 0x14,
 	}, mng.page.Buf)
 }
 
 func TestLogManager_SetString(t *testing.T) {
-	mng, err := NewLogManager(storage.NewNopFileManager(44, []byte{}), "test.db")
+	mng, err := NewLogManager(storage.NewNopFileManager(48, []byte{}), "test.db")
 	require.NoError(t, err)
 
 	block := storage.NewBlock("test", 0)
 
 	lsn, err := mng.SetString(1, block, 0, "hoge", "fuga")
 	require.NoError(t, err)
-	require.Equal(t, 36, lsn)
+	require.Equal(t, 1, lsn)
 	require.Equal(t, []byte{
 		0x00, 0x00, 0x00, 0x04,
-		0x00, 0x00, 0x00, 0x24,
+		0x00, 0x00, 0x00, 0x28,
 		0x00, 0x00, 0x00, 0x06,
 		0x00, 0x00, 0x00, 0x01,
 		0x00, 0x00, 0x00, 0x04, 0x74, 0x65, 0x73, 0x74,
 		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
 		0x00, 0x00, 0x00, 0x04, 0x68, 0x6f, 0x67, 0x65,
 		0x00, 0x00, 0x00, 0x04, 0x66, 0x75, 0x67, 0x61,
 	}, mng.page.Buf)
@@ -159,7 +259,7 @@ func TestLogIterator_Next(t *testing.T) {
 				// Start
 				0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
 			},
-			bsize: 28,
+			bsize: 32,
 			block: storage.NewBlock("test", 0),
 			want: [][]byte{
 				{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01}, // commit
@@ -175,7 +275,7 @@ func TestLogIterator_Next(t *testing.T) {
 				// Start
 				0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
 			},
-			bsize: 28,
+			bsize: 32,
 			block: storage.NewBlock("test", 1),
 			want: [][]byte{
 				{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01}, // commit
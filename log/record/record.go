@@ -104,7 +104,7 @@ func (r *SetStringRecord) Read(data []byte) {
 	ovaluelen := binary.BigEndian.Uint32(data[20+filelen : 24+filelen])
 	r.OldValue = string(data[24+filelen : 24+filelen+ovaluelen])
 	nvaluelen := binary.BigEndian.Uint32(data[24+filelen+ovaluelen : 28+filelen+ovaluelen])
-	r.NewValue = string(data[28+filelen+ovaluelen : 24+filelen+ovaluelen+nvaluelen])
+	r.NewValue = string(data[28+filelen+ovaluelen : 28+filelen+ovaluelen+nvaluelen])
 }
 
 func (r *SetStringRecord) Block() *storage.Block {
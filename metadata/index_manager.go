@@ -0,0 +1,264 @@
+package metadata
+
+import (
+	"errors"
+	"simpledb/index"
+	"simpledb/record"
+)
+
+// Index types, as named after USING in CREATE INDEX
+const (
+	IndexType_HASH  = "hash"
+	IndexType_BTREE = "btree"
+)
+
+var (
+	ErrUnknownIndexType = errors.New("unknown index type")
+	ErrIndexExists      = errors.New("index already exists")
+	ErrFieldIndexed     = errors.New("field already has an index")
+)
+
+// IndexInfo describes an index on a field of a table
+type IndexInfo struct {
+	idxname   string
+	idxtype   string
+	fldname   string
+	tblSchema *record.Schema
+	tx        record.Transaction
+	idxLayout *record.Layout
+	si        *StatInfo
+}
+
+func NewIndexInfo(idxname, idxtype, fldname string, tblSchema *record.Schema, tx record.Transaction, si *StatInfo) *IndexInfo {
+	return &IndexInfo{
+		idxname:   idxname,
+		idxtype:   idxtype,
+		fldname:   fldname,
+		tblSchema: tblSchema,
+		tx:        tx,
+		idxLayout: createIndexLayout(fldname, tblSchema),
+		si:        si,
+	}
+}
+
+// Open opens the index within the transaction the IndexInfo was read with
+func (ii *IndexInfo) Open() (index.Index, error) {
+	if ii.idxtype == IndexType_BTREE {
+		return index.NewBTreeIndex(ii.tx, ii.idxname, ii.idxLayout)
+	}
+	return index.NewHashIndex(ii.tx, ii.idxname, ii.idxLayout), nil
+}
+
+func (ii *IndexInfo) IndexName() string {
+	return ii.idxname
+}
+
+func (ii *IndexInfo) IndexType() string {
+	return ii.idxtype
+}
+
+func (ii *IndexInfo) FieldName() string {
+	return ii.fldname
+}
+
+// Layout returns the layout of the index records: the indexed value and the RID of the data record
+func (ii *IndexInfo) Layout() *record.Layout {
+	return ii.idxLayout
+}
+
+// BlocksAccessed estimates the number of block accesses to search the index for one value
+func (ii *IndexInfo) BlocksAccessed() int {
+	rpb := ii.tx.BlockSize() / ii.idxLayout.SlotSize()
+	numBlocks := ii.si.RecordsOutput() / rpb
+	if ii.idxtype == IndexType_BTREE {
+		return index.BTreeSearchCost(numBlocks, rpb)
+	}
+	return index.HashSearchCost(numBlocks, rpb)
+}
+
+// RecordsOutput estimates the number of data records having one value of the indexed field
+func (ii *IndexInfo) RecordsOutput() int {
+	return ii.si.RecordsOutput() / ii.si.DistinctValues(ii.fldname)
+}
+
+// DistinctValues estimates the number of distinct values of the field among the matching records
+func (ii *IndexInfo) DistinctValues(fldname string) int {
+	if fldname == ii.fldname {
+		return 1
+	}
+	return min(ii.si.DistinctValues(fldname), ii.RecordsOutput())
+}
+
+func createIndexLayout(fldname string, tblSchema *record.Schema) *record.Layout {
+	schema := record.NewSchema()
+	schema.AddIntField("block")
+	schema.AddIntField("id")
+	switch tblSchema.Type(fldname) {
+	case record.FieldType_INTEGER:
+		schema.AddIntField("dataval")
+	case record.FieldType_VARCHAR:
+		schema.AddStringField("dataval", tblSchema.Length(fldname))
+	}
+	return record.NewLayout(schema)
+}
+
+// IndexManager stores index definitions in the catalog table idxcat
+type IndexManager struct {
+	layout *record.Layout
+	tm     *TableManager
+	sm     *StatManager
+}
+
+// NewIndexManager creates the catalog table if the database is new
+func NewIndexManager(isNew bool, tm *TableManager, sm *StatManager, tx record.Transaction) (*IndexManager, error) {
+	if isNew {
+		schema := record.NewSchema()
+		schema.AddStringField("indexname", MaxName)
+		schema.AddStringField("indextype", MaxName)
+		schema.AddStringField("tablename", MaxName)
+		schema.AddStringField("fieldname", MaxName)
+		err := tm.CreateTable("idxcat", schema, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	layout, err := tm.GetLayout("idxcat", tx)
+	if err != nil {
+		return nil, err
+	}
+	return &IndexManager{
+		layout: layout,
+		tm:     tm,
+		sm:     sm,
+	}, nil
+}
+
+// CreateIndex registers an index of the type on the field of the table.
+// An empty type stands for a hash index. The files of an index are named after it,
+// so index names are unique across the tables, and a field has at most one index.
+func (im *IndexManager) CreateIndex(idxname, idxtype, tblname, fldname string, tx record.Transaction) error {
+	if len(idxname) > MaxName {
+		return ErrNameTooLong
+	}
+	if idxtype == "" {
+		idxtype = IndexType_HASH
+	}
+	if idxtype != IndexType_HASH && idxtype != IndexType_BTREE {
+		return ErrUnknownIndexType
+	}
+	err := im.checkNew(idxname, tblname, fldname, tx)
+	if err != nil {
+		return err
+	}
+
+	ts, err := record.NewTableScan(tx, "idxcat", im.layout)
+	if err != nil {
+		return err
+	}
+	defer ts.Close()
+
+	err = ts.Insert()
+	if err != nil {
+		return err
+	}
+	err = ts.SetString("indexname", idxname)
+	if err != nil {
+		return err
+	}
+	err = ts.SetString("indextype", idxtype)
+	if err != nil {
+		return err
+	}
+	err = ts.SetString("tablename", tblname)
+	if err != nil {
+		return err
+	}
+	return ts.SetString("fieldname", fldname)
+}
+
+// checkNew returns an error if an index has the name or indexes the field of the table
+func (im *IndexManager) checkNew(idxname, tblname, fldname string, tx record.Transaction) error {
+	ts, err := record.NewTableScan(tx, "idxcat", im.layout)
+	if err != nil {
+		return err
+	}
+	defer ts.Close()
+
+	for {
+		ok, err := ts.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		name, err := ts.GetString("indexname")
+		if err != nil {
+			return err
+		}
+		if name == idxname {
+			return ErrIndexExists
+		}
+		name, err = ts.GetString("tablename")
+		if err != nil {
+			return err
+		}
+		field, err := ts.GetString("fieldname")
+		if err != nil {
+			return err
+		}
+		if name == tblname && field == fldname {
+			return ErrFieldIndexed
+		}
+	}
+}
+
+// GetIndexInfo returns the indexes of the table keyed by the indexed field, which has a single index
+func (im *IndexManager) GetIndexInfo(tblname string, tx record.Transaction) (map[string]*IndexInfo, error) {
+	ts, err := record.NewTableScan(tx, "idxcat", im.layout)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.Close()
+
+	result := make(map[string]*IndexInfo)
+	for {
+		ok, err := ts.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return result, nil
+		}
+		name, err := ts.GetString("tablename")
+		if err != nil {
+			return nil, err
+		}
+		if name != tblname {
+			continue
+		}
+		idxname, err := ts.GetString("indexname")
+		if err != nil {
+			return nil, err
+		}
+		idxtype, err := ts.GetString("indextype")
+		if err != nil {
+			return nil, err
+		}
+		fldname, err := ts.GetString("fieldname")
+		if err != nil {
+			return nil, err
+		}
+
+		layout, err := im.tm.GetLayout(tblname, tx)
+		if err != nil {
+			return nil, err
+		}
+		si, err := im.sm.GetStatInfo(tblname, layout, tx)
+		if err != nil {
+			return nil, err
+		}
+		result[fldname] = NewIndexInfo(idxname, idxtype, fldname, layout.Schema(), tx, si)
+	}
+}
@@ -0,0 +1,80 @@
+package metadata
+
+import (
+	"errors"
+	"simpledb/record"
+)
+
+// MetadataManager is the single entry point to the catalog
+type MetadataManager struct {
+	tm *TableManager
+	vm *ViewManager
+	sm *StatManager
+	im *IndexManager
+}
+
+// NewMetadataManager opens the catalog, creating the catalog tables if the database is new
+func NewMetadataManager(isNew bool, tx record.Transaction) (*MetadataManager, error) {
+	tm, err := NewTableManager(isNew, tx)
+	if err != nil {
+		return nil, err
+	}
+	vm, err := NewViewManager(isNew, tm, tx)
+	if err != nil {
+		return nil, err
+	}
+	sm, err := NewStatManager(tm, tx)
+	if err != nil {
+		return nil, err
+	}
+	im, err := NewIndexManager(isNew, tm, sm, tx)
+	if err != nil {
+		return nil, err
+	}
+	return &MetadataManager{
+		tm: tm,
+		vm: vm,
+		sm: sm,
+		im: im,
+	}, nil
+}
+
+// CreateTable registers the table, unless a table or a view already has the name
+func (mm *MetadataManager) CreateTable(tblname string, schema *record.Schema, tx record.Transaction) error {
+	_, err := mm.vm.GetViewDef(tblname, tx)
+	if err == nil {
+		return ErrTableExists
+	}
+	if !errors.Is(err, ErrViewNotFound) {
+		return err
+	}
+	return mm.tm.CreateTable(tblname, schema, tx)
+}
+
+func (mm *MetadataManager) GetLayout(tblname string, tx record.Transaction) (*record.Layout, error) {
+	return mm.tm.GetLayout(tblname, tx)
+}
+
+func (mm *MetadataManager) TableNames(tx record.Transaction) ([]string, error) {
+	return mm.tm.TableNames(tx)
+}
+
+func (mm *MetadataManager) CreateView(vname, vdef string, tx record.Transaction) error {
+	return mm.vm.CreateView(vname, vdef, tx)
+}
+
+func (mm *MetadataManager) GetViewDef(vname string, tx record.Transaction) (string, error) {
+	return mm.vm.GetViewDef(vname, tx)
+}
+
+func (mm *MetadataManager) CreateIndex(idxname, idxtype, tblname, fldname string, tx record.Transaction) error {
+	return mm.im.CreateIndex(idxname, idxtype, tblname, fldname, tx)
+}
+
+func (mm *MetadataManager) GetIndexInfo(tblname string, tx record.Transaction) (map[string]*IndexInfo, error) {
+	return mm.im.GetIndexInfo(tblname, tx)
+}
+
+func (mm *MetadataManager) GetStatInfo(tblname string, layout *record.Layout, tx record.Transaction) (*StatInfo, error) {
+	return mm.sm.GetStatInfo(tblname, layout, tx)
+}
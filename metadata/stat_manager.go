@@ -0,0 +1,164 @@
+package metadata
+
+import (
+	"simpledb/record"
+	"sync"
+)
+
+const (
+	// RefreshInterval is the number of GetStatInfo calls after which every statistic is recalculated
+	RefreshInterval = 100
+)
+
+// StatInfo holds the approximate statistics of a table
+type StatInfo struct {
+	numBlocks int
+	numRecs   int
+	distinct  map[string]int
+}
+
+func NewStatInfo(numBlocks, numRecs int, distinct map[string]int) *StatInfo {
+	return &StatInfo{
+		numBlocks: numBlocks,
+		numRecs:   numRecs,
+		distinct:  distinct,
+	}
+}
+
+// BlocksAccessed returns the number of blocks of the table
+func (si *StatInfo) BlocksAccessed() int {
+	return si.numBlocks
+}
+
+// RecordsOutput returns the number of records in the table
+func (si *StatInfo) RecordsOutput() int {
+	return si.numRecs
+}
+
+// DistinctValues returns the number of distinct values of the field.
+// It is never less than 1 so that it can be used as a divisor.
+func (si *StatInfo) DistinctValues(field string) int {
+	n, found := si.distinct[field]
+	if !found {
+		// a guess for fields the statistics know nothing about
+		return 1 + si.numRecs/3
+	}
+	return max(n, 1)
+}
+
+// StatManager keeps the statistics of every table in memory.
+// They are not stored in the database but recalculated at startup and periodically afterwards.
+type StatManager struct {
+	tm       *TableManager
+	stats    map[string]*StatInfo
+	numcalls int
+	mu       sync.Mutex
+}
+
+func NewStatManager(tm *TableManager, tx record.Transaction) (*StatManager, error) {
+	sm := &StatManager{
+		tm: tm,
+	}
+	err := sm.refreshStatistics(tx)
+	if err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// GetStatInfo returns the statistics of the table, calculating them if they are missing or stale
+func (sm *StatManager) GetStatInfo(tblname string, layout *record.Layout, tx record.Transaction) (*StatInfo, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.numcalls++
+	if sm.numcalls > RefreshInterval {
+		err := sm.refreshStatistics(tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	si, found := sm.stats[tblname]
+	if !found {
+		var err error
+		si, err = sm.calcTableStats(tblname, layout, tx)
+		if err != nil {
+			return nil, err
+		}
+		sm.stats[tblname] = si
+	}
+	return si, nil
+}
+
+func (sm *StatManager) refreshStatistics(tx record.Transaction) error {
+	sm.stats = make(map[string]*StatInfo)
+	sm.numcalls = 0
+
+	tblnames, err := sm.tm.TableNames(tx)
+	if err != nil {
+		return err
+	}
+	for _, tblname := range tblnames {
+		layout, err := sm.tm.GetLayout(tblname, tx)
+		if err != nil {
+			return err
+		}
+		si, err := sm.calcTableStats(tblname, layout, tx)
+		if err != nil {
+			return err
+		}
+		sm.stats[tblname] = si
+	}
+	return nil
+}
+
+func (sm *StatManager) calcTableStats(tblname string, layout *record.Layout, tx record.Transaction) (*StatInfo, error) {
+	numBlocks, err := tx.Size(tblname + ".tbl")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := layout.Schema().Fields()
+	values := make(map[string]map[any]struct{}, len(fields))
+	for _, field := range fields {
+		values[field] = make(map[any]struct{})
+	}
+
+	ts, err := record.NewTableScan(tx, tblname, layout)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.Close()
+
+	numRecs := 0
+	for {
+		ok, err := ts.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		numRecs++
+		for _, field := range fields {
+			var val any
+			switch layout.Schema().Type(field) {
+			case record.FieldType_INTEGER:
+				val, err = ts.GetInt(field)
+			case record.FieldType_VARCHAR:
+				val, err = ts.GetString(field)
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[field][val] = struct{}{}
+		}
+	}
+
+	distinct := make(map[string]int, len(fields))
+	for field, vals := range values {
+		distinct[field] = len(vals)
+	}
+	return NewStatInfo(numBlocks, numRecs, distinct), nil
+}
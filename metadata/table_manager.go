@@ -0,0 +1,233 @@
+package metadata
+
+import (
+	"errors"
+	"simpledb/record"
+	"slices"
+)
+
+const (
+	// MaxName is the maximum length of table, field, view and index names
+	MaxName = 16
+)
+
+var (
+	ErrTableNotFound = errors.New("table not found")
+	ErrTableExists   = errors.New("a table or view with the name already exists")
+	ErrNameTooLong   = errors.New("name too long")
+)
+
+// TableManager stores the schema of every table in the catalog tables tblcat and fldcat
+type TableManager struct {
+	tcatLayout *record.Layout
+	fcatLayout *record.Layout
+}
+
+// NewTableManager creates the catalog tables if the database is new
+func NewTableManager(isNew bool, tx record.Transaction) (*TableManager, error) {
+	tcatSchema := record.NewSchema()
+	tcatSchema.AddStringField("tblname", MaxName)
+	tcatSchema.AddIntField("slotsize")
+
+	fcatSchema := record.NewSchema()
+	fcatSchema.AddStringField("tblname", MaxName)
+	fcatSchema.AddStringField("fldname", MaxName)
+	fcatSchema.AddIntField("type")
+	fcatSchema.AddIntField("length")
+	fcatSchema.AddIntField("offset")
+
+	tm := &TableManager{
+		tcatLayout: record.NewLayout(tcatSchema),
+		fcatLayout: record.NewLayout(fcatSchema),
+	}
+
+	if isNew {
+		err := tm.CreateTable("tblcat", tcatSchema, tx)
+		if err != nil {
+			return nil, err
+		}
+		err = tm.CreateTable("fldcat", fcatSchema, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tm, nil
+}
+
+// CreateTable registers the table and its fields in the catalog.
+// It returns ErrTableExists if the catalog already holds a table of the name.
+func (tm *TableManager) CreateTable(tblname string, schema *record.Schema, tx record.Transaction) error {
+	if len(tblname) > MaxName {
+		return ErrNameTooLong
+	}
+	for _, fldname := range schema.Fields() {
+		if len(fldname) > MaxName {
+			return ErrNameTooLong
+		}
+	}
+	names, err := tm.TableNames(tx)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(names, tblname) {
+		return ErrTableExists
+	}
+	layout := record.NewLayout(schema)
+
+	tcat, err := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	if err != nil {
+		return err
+	}
+	defer tcat.Close()
+
+	err = tcat.Insert()
+	if err != nil {
+		return err
+	}
+	err = tcat.SetString("tblname", tblname)
+	if err != nil {
+		return err
+	}
+	err = tcat.SetInt("slotsize", int32(layout.SlotSize()))
+	if err != nil {
+		return err
+	}
+
+	fcat, err := record.NewTableScan(tx, "fldcat", tm.fcatLayout)
+	if err != nil {
+		return err
+	}
+	defer fcat.Close()
+
+	for _, fldname := range schema.Fields() {
+		err = fcat.Insert()
+		if err != nil {
+			return err
+		}
+		err = fcat.SetString("tblname", tblname)
+		if err != nil {
+			return err
+		}
+		err = fcat.SetString("fldname", fldname)
+		if err != nil {
+			return err
+		}
+		err = fcat.SetInt("type", int32(schema.Type(fldname)))
+		if err != nil {
+			return err
+		}
+		err = fcat.SetInt("length", int32(schema.Length(fldname)))
+		if err != nil {
+			return err
+		}
+		err = fcat.SetInt("offset", int32(layout.Offset(fldname)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLayout reads the layout of the table from the catalog
+func (tm *TableManager) GetLayout(tblname string, tx record.Transaction) (*record.Layout, error) {
+	slotsize := -1
+	tcat, err := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer tcat.Close()
+
+	for {
+		ok, err := tcat.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		name, err := tcat.GetString("tblname")
+		if err != nil {
+			return nil, err
+		}
+		if name == tblname {
+			size, err := tcat.GetInt("slotsize")
+			if err != nil {
+				return nil, err
+			}
+			slotsize = int(size)
+			break
+		}
+	}
+	if slotsize < 0 {
+		return nil, ErrTableNotFound
+	}
+
+	schema := record.NewSchema()
+	offsets := make(map[string]int)
+	fcat, err := record.NewTableScan(tx, "fldcat", tm.fcatLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer fcat.Close()
+
+	for {
+		ok, err := fcat.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		name, err := fcat.GetString("tblname")
+		if err != nil {
+			return nil, err
+		}
+		if name != tblname {
+			continue
+		}
+		fldname, err := fcat.GetString("fldname")
+		if err != nil {
+			return nil, err
+		}
+		typ, err := fcat.GetInt("type")
+		if err != nil {
+			return nil, err
+		}
+		length, err := fcat.GetInt("length")
+		if err != nil {
+			return nil, err
+		}
+		offset, err := fcat.GetInt("offset")
+		if err != nil {
+			return nil, err
+		}
+		schema.AddField(fldname, record.FieldType(typ), int(length))
+		offsets[fldname] = int(offset)
+	}
+	return record.NewLayoutFromMetadata(schema, offsets, slotsize), nil
+}
+
+// TableNames returns the names of every table in the catalog, including the catalog tables themselves
+func (tm *TableManager) TableNames(tx record.Transaction) ([]string, error) {
+	tcat, err := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	if err != nil {
+		return nil, err
+	}
+	defer tcat.Close()
+
+	names := []string{}
+	for {
+		ok, err := tcat.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return names, nil
+		}
+		name, err := tcat.GetString("tblname")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+}
@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"simpledb/record"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableManager(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	tm, err := NewTableManager(true, tx)
+	require.NoError(t, err)
+
+	schema := record.NewSchema()
+	schema.AddIntField("A")
+	schema.AddStringField("B", 9)
+	require.NoError(t, tm.CreateTable("MyTable", schema, tx))
+
+	layout, err := tm.GetLayout("MyTable", tx)
+	require.NoError(t, err)
+	require.Equal(t, record.NewLayout(schema), layout)
+
+	layout, err = tm.GetLayout("fldcat", tx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tblname", "fldname", "type", "length", "offset"}, layout.Schema().Fields())
+
+	_, err = tm.GetLayout("NoSuchTable", tx)
+	require.ErrorIs(t, err, ErrTableNotFound)
+
+	// a second definition of the table is rejected and leaves the first one in place
+	other := record.NewSchema()
+	other.AddStringField("C", 3)
+	err = tm.CreateTable("MyTable", other, tx)
+	require.ErrorIs(t, err, ErrTableExists)
+	layout, err = tm.GetLayout("MyTable", tx)
+	require.NoError(t, err)
+	require.Equal(t, record.NewLayout(schema), layout)
+
+	err = tm.CreateTable("ThisNameIsFarTooLong", schema, tx)
+	require.ErrorIs(t, err, ErrNameTooLong)
+
+	names, err := tm.TableNames(tx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tblcat", "fldcat", "MyTable"}, names)
+	require.Empty(t, tx.Pinned())
+}
@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"errors"
+	"simpledb/record"
+	"slices"
+)
+
+const (
+	// MaxViewDef is the maximum length of the query defining a view
+	MaxViewDef = 100
+)
+
+var (
+	ErrViewNotFound   = errors.New("view not found")
+	ErrViewDefTooLong = errors.New("view definition too long")
+)
+
+// ViewManager stores view definitions in the catalog table viewcat
+type ViewManager struct {
+	tm *TableManager
+}
+
+// NewViewManager creates the catalog table if the database is new
+func NewViewManager(isNew bool, tm *TableManager, tx record.Transaction) (*ViewManager, error) {
+	vm := &ViewManager{tm: tm}
+	if isNew {
+		schema := record.NewSchema()
+		schema.AddStringField("viewname", MaxName)
+		schema.AddStringField("viewdef", MaxViewDef)
+		err := tm.CreateTable("viewcat", schema, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vm, nil
+}
+
+// CreateView registers the definition of the view.
+// It returns ErrTableExists if a table or a view already has the name.
+func (vm *ViewManager) CreateView(vname, vdef string, tx record.Transaction) error {
+	if len(vname) > MaxName {
+		return ErrNameTooLong
+	}
+	if len(vdef) > MaxViewDef {
+		return ErrViewDefTooLong
+	}
+	names, err := vm.tm.TableNames(tx)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(names, vname) {
+		return ErrTableExists
+	}
+	_, err = vm.GetViewDef(vname, tx)
+	if err == nil {
+		return ErrTableExists
+	}
+	if !errors.Is(err, ErrViewNotFound) {
+		return err
+	}
+
+	layout, err := vm.tm.GetLayout("viewcat", tx)
+	if err != nil {
+		return err
+	}
+	ts, err := record.NewTableScan(tx, "viewcat", layout)
+	if err != nil {
+		return err
+	}
+	defer ts.Close()
+
+	err = ts.Insert()
+	if err != nil {
+		return err
+	}
+	err = ts.SetString("viewname", vname)
+	if err != nil {
+		return err
+	}
+	return ts.SetString("viewdef", vdef)
+}
+
+// GetViewDef returns the query defining the view
+func (vm *ViewManager) GetViewDef(vname string, tx record.Transaction) (string, error) {
+	layout, err := vm.tm.GetLayout("viewcat", tx)
+	if err != nil {
+		return "", err
+	}
+	ts, err := record.NewTableScan(tx, "viewcat", layout)
+	if err != nil {
+		return "", err
+	}
+	defer ts.Close()
+
+	for {
+		ok, err := ts.Next()
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrViewNotFound
+		}
+		name, err := ts.GetString("viewname")
+		if err != nil {
+			return "", err
+		}
+		if name == vname {
+			return ts.GetString("viewdef")
+		}
+	}
+}
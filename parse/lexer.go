@@ -0,0 +1,311 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"simpledb/query"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type TokenType int
+
+const (
+	TokenType_EOF TokenType = iota
+	TokenType_DELIM
+	TokenType_INT
+	TokenType_STRING
+	TokenType_KEYWORD
+	TokenType_ID
+)
+
+var keywords = map[string]struct{}{
+	"select": {}, "from": {}, "where": {}, "and": {},
+	"insert": {}, "into": {}, "values": {}, "delete": {}, "update": {}, "set": {},
+	"create": {}, "table": {}, "int": {}, "varchar": {}, "view": {}, "as": {}, "index": {}, "on": {},
+	"between": {}, "using": {}, "group": {}, "order": {}, "by": {},
+}
+
+var operators = map[string]query.Operator{
+	"=": query.Operator_EQ, "<>": query.Operator_NE, "!=": query.Operator_NE,
+	"<": query.Operator_LT, "<=": query.Operator_LE, ">": query.Operator_GT, ">=": query.Operator_GE,
+}
+
+var (
+	ErrSyntax = errors.New("syntax error")
+)
+
+// Position is a location in the SQL text. Line and Column start at 1.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// SyntaxError reports what went wrong and where
+type SyntaxError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %s: %s", e.Pos, e.Msg)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return ErrSyntax
+}
+
+type Token struct {
+	Type TokenType
+	// Text is the delimiter, the lowercased keyword or identifier, or the contents of a string
+	Text string
+	Int  int32
+	Pos  Position
+}
+
+func (t Token) String() string {
+	switch t.Type {
+	case TokenType_EOF:
+		return "end of input"
+	case TokenType_STRING:
+		return "'" + t.Text + "'"
+	default:
+		return fmt.Sprintf("%q", t.Text)
+	}
+}
+
+// Lexer splits SQL text into tokens.
+// Keywords and identifiers are case-insensitive and are returned in lower case.
+type Lexer struct {
+	input string
+	pos   Position
+	tok   Token
+	err   error
+}
+
+func NewLexer(s string) *Lexer {
+	l := &Lexer{
+		input: s,
+		pos:   Position{Offset: 0, Line: 1, Column: 1},
+	}
+	l.next()
+	return l
+}
+
+// Token returns the current token
+func (l *Lexer) Token() Token {
+	return l.tok
+}
+
+func (l *Lexer) MatchDelim(d byte) bool {
+	return l.err == nil && l.tok.Type == TokenType_DELIM && l.tok.Text == string(d)
+}
+
+// MatchOperator returns true if the current token is a comparison operator
+func (l *Lexer) MatchOperator() bool {
+	if l.err != nil || l.tok.Type != TokenType_DELIM {
+		return false
+	}
+	_, found := operators[l.tok.Text]
+	return found
+}
+
+func (l *Lexer) MatchInt() bool {
+	return l.err == nil && l.tok.Type == TokenType_INT
+}
+
+func (l *Lexer) MatchString() bool {
+	return l.err == nil && l.tok.Type == TokenType_STRING
+}
+
+func (l *Lexer) MatchKeyword(w string) bool {
+	return l.err == nil && l.tok.Type == TokenType_KEYWORD && l.tok.Text == w
+}
+
+func (l *Lexer) MatchId() bool {
+	return l.err == nil && l.tok.Type == TokenType_ID
+}
+
+// MatchCall returns true if the current token is an identifier followed by an opening parenthesis,
+// such as the name of an aggregate function, which stays usable as a field or table name
+func (l *Lexer) MatchCall() bool {
+	if !l.MatchId() {
+		return false
+	}
+	rest := strings.TrimLeftFunc(l.input[l.pos.Offset:], unicode.IsSpace)
+	return strings.HasPrefix(rest, "(")
+}
+
+func (l *Lexer) MatchEOF() bool {
+	return l.err == nil && l.tok.Type == TokenType_EOF
+}
+
+func (l *Lexer) EatDelim(d byte) error {
+	if !l.MatchDelim(d) {
+		return l.unexpected(fmt.Sprintf("%q", string(d)))
+	}
+	l.next()
+	return nil
+}
+
+func (l *Lexer) EatOperator() (query.Operator, error) {
+	if !l.MatchOperator() {
+		return 0, l.unexpected("comparison operator")
+	}
+	op := operators[l.tok.Text]
+	l.next()
+	return op, nil
+}
+
+func (l *Lexer) EatInt() (int32, error) {
+	if !l.MatchInt() {
+		return 0, l.unexpected("integer")
+	}
+	n := l.tok.Int
+	l.next()
+	return n, nil
+}
+
+func (l *Lexer) EatString() (string, error) {
+	if !l.MatchString() {
+		return "", l.unexpected("string")
+	}
+	s := l.tok.Text
+	l.next()
+	return s, nil
+}
+
+func (l *Lexer) EatKeyword(w string) error {
+	if !l.MatchKeyword(w) {
+		return l.unexpected(fmt.Sprintf("%q", w))
+	}
+	l.next()
+	return nil
+}
+
+func (l *Lexer) EatId() (string, error) {
+	if !l.MatchId() {
+		return "", l.unexpected("identifier")
+	}
+	s := l.tok.Text
+	l.next()
+	return s, nil
+}
+
+// unexpected returns the error for a token which is not the expected one
+func (l *Lexer) unexpected(expected string) error {
+	if l.err != nil {
+		return l.err
+	}
+	return &SyntaxError{
+		Pos: l.tok.Pos,
+		Msg: fmt.Sprintf("expected %s, found %s", expected, l.tok),
+	}
+}
+
+// next scans the next token. A lexical error sticks until the end of parsing.
+func (l *Lexer) next() {
+	if l.err != nil {
+		return
+	}
+	l.skipSpaces()
+	start := l.pos
+	if l.pos.Offset >= len(l.input) {
+		l.tok = Token{Type: TokenType_EOF, Pos: start}
+		return
+	}
+
+	c := l.input[l.pos.Offset]
+	switch {
+	case isLetter(c):
+		for l.pos.Offset < len(l.input) && (isLetter(l.input[l.pos.Offset]) || isDigit(l.input[l.pos.Offset])) {
+			l.advance()
+		}
+		word := strings.ToLower(l.input[start.Offset:l.pos.Offset])
+		typ := TokenType_ID
+		if _, found := keywords[word]; found {
+			typ = TokenType_KEYWORD
+		}
+		l.tok = Token{Type: typ, Text: word, Pos: start}
+	case isDigit(c) || (c == '-' && l.pos.Offset+1 < len(l.input) && isDigit(l.input[l.pos.Offset+1])):
+		l.advance()
+		for l.pos.Offset < len(l.input) && isDigit(l.input[l.pos.Offset]) {
+			l.advance()
+		}
+		text := l.input[start.Offset:l.pos.Offset]
+		n, err := strconv.ParseInt(text, 10, 32)
+		if err != nil {
+			l.err = &SyntaxError{Pos: start, Msg: fmt.Sprintf("integer %s out of range", text)}
+			return
+		}
+		l.tok = Token{Type: TokenType_INT, Text: text, Int: int32(n), Pos: start}
+	case c == '\'':
+		l.advance()
+		var sb strings.Builder
+		for {
+			if l.pos.Offset >= len(l.input) {
+				l.err = &SyntaxError{Pos: start, Msg: "unterminated string"}
+				return
+			}
+			ch := l.input[l.pos.Offset]
+			l.advance()
+			if ch == '\'' {
+				// a doubled quote stands for a quote inside the string
+				if l.pos.Offset < len(l.input) && l.input[l.pos.Offset] == '\'' {
+					l.advance()
+				} else {
+					break
+				}
+			}
+			sb.WriteByte(ch)
+		}
+		l.tok = Token{Type: TokenType_STRING, Text: sb.String(), Pos: start}
+	case strings.IndexByte("(),=;", c) >= 0:
+		l.advance()
+		l.tok = Token{Type: TokenType_DELIM, Text: string(c), Pos: start}
+	case c == '<' || c == '>' || c == '!':
+		l.advance()
+		if l.pos.Offset < len(l.input) {
+			if _, found := operators[l.input[start.Offset:l.pos.Offset+1]]; found {
+				l.advance()
+			}
+		}
+		text := l.input[start.Offset:l.pos.Offset]
+		if _, found := operators[text]; !found {
+			l.err = &SyntaxError{Pos: start, Msg: fmt.Sprintf("unexpected character %q", rune(c))}
+			return
+		}
+		l.tok = Token{Type: TokenType_DELIM, Text: text, Pos: start}
+	default:
+		l.err = &SyntaxError{Pos: start, Msg: fmt.Sprintf("unexpected character %q", rune(c))}
+	}
+}
+
+func (l *Lexer) skipSpaces() {
+	for l.pos.Offset < len(l.input) && unicode.IsSpace(rune(l.input[l.pos.Offset])) {
+		l.advance()
+	}
+}
+
+func (l *Lexer) advance() {
+	if l.input[l.pos.Offset] == '\n' {
+		l.pos.Line++
+		l.pos.Column = 0
+	}
+	l.pos.Offset++
+	l.pos.Column++
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,606 @@
+package parse
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// Parser is a recursive-descent parser for the SQL subset understood by simpledb
+type Parser struct {
+	lex *Lexer
+}
+
+func NewParser(s string) *Parser {
+	return &Parser{
+		lex: NewLexer(s),
+	}
+}
+
+// Parse parses a single statement, optionally terminated by a semicolon
+func Parse(sql string) (Statement, error) {
+	return NewParser(sql).Statement()
+}
+
+// Statement parses a query or an update command which must span the whole input
+func (p *Parser) Statement() (Statement, error) {
+	var stmt Statement
+	var err error
+	if p.lex.MatchKeyword("select") {
+		stmt, err = p.Query()
+	} else {
+		stmt, err = p.UpdateCmd()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.lex.MatchDelim(';') {
+		p.lex.EatDelim(';')
+	}
+	if !p.lex.MatchEOF() {
+		return nil, p.lex.unexpected("end of input")
+	}
+	return stmt, nil
+}
+
+// <Field> := IdTok
+func (p *Parser) Field() (string, error) {
+	return p.lex.EatId()
+}
+
+// <Constant> := StrTok | IntTok
+func (p *Parser) Constant() (query.Constant, error) {
+	if p.lex.MatchString() {
+		s, err := p.lex.EatString()
+		return query.NewStringConstant(s), err
+	}
+	if p.lex.MatchInt() {
+		n, err := p.lex.EatInt()
+		return query.NewIntConstant(n), err
+	}
+	return query.Constant{}, p.lex.unexpected("constant")
+}
+
+// <Expression> := <Field> | <Constant>
+func (p *Parser) Expression() (*query.Expression, error) {
+	if p.lex.MatchId() {
+		fldname, err := p.Field()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewFieldExpression(fldname), nil
+	}
+	val, err := p.Constant()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewConstantExpression(val), nil
+}
+
+// <Term> := <Expression> <Operator> <Expression>
+// <Operator> := = | <> | != | < | <= | > | >=
+func (p *Parser) Term() (*query.Term, error) {
+	lhs, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	op, err := p.lex.EatOperator()
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewComparisonTerm(lhs, op, rhs), nil
+}
+
+// <Condition> := <Term> | <Expression> BETWEEN <Expression> AND <Expression>
+// A BETWEEN condition yields the terms lhs >= lo and lhs <= hi.
+func (p *Parser) condition() (*query.Predicate, error) {
+	lhs, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lex.MatchKeyword("between") {
+		op, err := p.lex.EatOperator()
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewPredicate(query.NewComparisonTerm(lhs, op, rhs)), nil
+	}
+
+	p.lex.EatKeyword("between")
+	lo, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("and")
+	if err != nil {
+		return nil, err
+	}
+	hi, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewPredicate(
+		query.NewComparisonTerm(lhs, query.Operator_GE, lo),
+		query.NewComparisonTerm(lhs, query.Operator_LE, hi),
+	), nil
+}
+
+// <Predicate> := <Condition> [ AND <Predicate> ]
+func (p *Parser) Predicate() (*query.Predicate, error) {
+	pred, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	if p.lex.MatchKeyword("and") {
+		p.lex.EatKeyword("and")
+		rest, err := p.Predicate()
+		if err != nil {
+			return nil, err
+		}
+		pred.ConjoinWith(rest)
+	}
+	return pred, nil
+}
+
+// <Query> := SELECT <SelectList> FROM <TableList> [ WHERE <Predicate> ]
+//
+//	[ GROUP BY <FieldList> ] [ ORDER BY <FieldList> ]
+func (p *Parser) Query() (*QueryData, error) {
+	err := p.lex.EatKeyword("select")
+	if err != nil {
+		return nil, err
+	}
+	fields, aggfns, err := p.selectList()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("from")
+	if err != nil {
+		return nil, err
+	}
+	tables, err := p.tableList()
+	if err != nil {
+		return nil, err
+	}
+	pred, err := p.optionalWhere()
+	if err != nil {
+		return nil, err
+	}
+	groupfields, err := p.optionalFieldList("group")
+	if err != nil {
+		return nil, err
+	}
+	orderfields, err := p.optionalFieldList("order")
+	if err != nil {
+		return nil, err
+	}
+	return &QueryData{
+		Fields:      fields,
+		Tables:      tables,
+		Pred:        pred,
+		GroupFields: groupfields,
+		Aggregates:  aggfns,
+		OrderFields: orderfields,
+	}, nil
+}
+
+// <SelectList> := <SelectItem> [ , <SelectList> ]
+// <SelectItem> := <Field> | <Aggregate> ( <Field> )
+// <Aggregate> := COUNT | SUM | MIN | MAX | AVG
+// The aggregate names are not keywords: an identifier followed by ( is an aggregate call.
+func (p *Parser) selectList() ([]string, []*query.AggregationFn, error) {
+	fields := []string{}
+	var aggfns []*query.AggregationFn
+	for {
+		tok := p.lex.Token()
+		if agg, ok := query.LookupAggregate(tok.Text); ok && p.lex.MatchCall() {
+			p.lex.EatId()
+			err := p.lex.EatDelim('(')
+			if err != nil {
+				return nil, nil, err
+			}
+			fldname, err := p.Field()
+			if err != nil {
+				return nil, nil, err
+			}
+			err = p.lex.EatDelim(')')
+			if err != nil {
+				return nil, nil, err
+			}
+			fn := query.NewAggregationFn(agg, fldname)
+			fields = append(fields, fn.FieldName())
+			aggfns = append(aggfns, fn)
+		} else {
+			fldname, err := p.Field()
+			if err != nil {
+				return nil, nil, err
+			}
+			fields = append(fields, fldname)
+		}
+		if !p.lex.MatchDelim(',') {
+			return fields, aggfns, nil
+		}
+		p.lex.EatDelim(',')
+	}
+}
+
+// [ <Keyword> BY <FieldList> ]
+func (p *Parser) optionalFieldList(keyword string) ([]string, error) {
+	if !p.lex.MatchKeyword(keyword) {
+		return nil, nil
+	}
+	p.lex.EatKeyword(keyword)
+	err := p.lex.EatKeyword("by")
+	if err != nil {
+		return nil, err
+	}
+	return p.fieldList()
+}
+
+// <TableList> := IdTok [ , <TableList> ]
+func (p *Parser) tableList() ([]string, error) {
+	tables := []string{}
+	for {
+		tblname, err := p.lex.EatId()
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, tblname)
+		if !p.lex.MatchDelim(',') {
+			return tables, nil
+		}
+		p.lex.EatDelim(',')
+	}
+}
+
+// [ WHERE <Predicate> ]
+func (p *Parser) optionalWhere() (*query.Predicate, error) {
+	if !p.lex.MatchKeyword("where") {
+		return query.NewPredicate(), nil
+	}
+	p.lex.EatKeyword("where")
+	return p.Predicate()
+}
+
+// <UpdateCmd> := <Insert> | <Delete> | <Modify> | <Create>
+func (p *Parser) UpdateCmd() (Statement, error) {
+	switch {
+	case p.lex.MatchKeyword("insert"):
+		return p.Insert()
+	case p.lex.MatchKeyword("delete"):
+		return p.Delete()
+	case p.lex.MatchKeyword("update"):
+		return p.Modify()
+	case p.lex.MatchKeyword("create"):
+		return p.create()
+	default:
+		return nil, p.lex.unexpected("statement")
+	}
+}
+
+// <Create> := <CreateTable> | <CreateView> | <CreateIndex>
+func (p *Parser) create() (Statement, error) {
+	err := p.lex.EatKeyword("create")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.lex.MatchKeyword("table"):
+		return p.CreateTable()
+	case p.lex.MatchKeyword("view"):
+		return p.CreateView()
+	case p.lex.MatchKeyword("index"):
+		return p.CreateIndex()
+	default:
+		return nil, p.lex.unexpected(`"table", "view" or "index"`)
+	}
+}
+
+// <Delete> := DELETE FROM IdTok [ WHERE <Predicate> ]
+func (p *Parser) Delete() (*DeleteData, error) {
+	err := p.lex.EatKeyword("delete")
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("from")
+	if err != nil {
+		return nil, err
+	}
+	tblname, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	pred, err := p.optionalWhere()
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteData{
+		TableName: tblname,
+		Pred:      pred,
+	}, nil
+}
+
+// <Insert> := INSERT INTO IdTok ( <FieldList> ) VALUES ( <ConstList> )
+func (p *Parser) Insert() (*InsertData, error) {
+	err := p.lex.EatKeyword("insert")
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("into")
+	if err != nil {
+		return nil, err
+	}
+	tblname, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim('(')
+	if err != nil {
+		return nil, err
+	}
+	fields, err := p.fieldList()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim(')')
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("values")
+	if err != nil {
+		return nil, err
+	}
+	valuesPos := p.lex.Token().Pos
+	err = p.lex.EatDelim('(')
+	if err != nil {
+		return nil, err
+	}
+	values, err := p.constList()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim(')')
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != len(values) {
+		return nil, &SyntaxError{Pos: valuesPos, Msg: "number of values does not match number of fields"}
+	}
+	return &InsertData{
+		TableName: tblname,
+		Fields:    fields,
+		Values:    values,
+	}, nil
+}
+
+// <FieldList> := <Field> [ , <FieldList> ]
+func (p *Parser) fieldList() ([]string, error) {
+	fields := []string{}
+	for {
+		fldname, err := p.Field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fldname)
+		if !p.lex.MatchDelim(',') {
+			return fields, nil
+		}
+		p.lex.EatDelim(',')
+	}
+}
+
+// <ConstList> := <Constant> [ , <ConstList> ]
+func (p *Parser) constList() ([]query.Constant, error) {
+	values := []query.Constant{}
+	for {
+		val, err := p.Constant()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+		if !p.lex.MatchDelim(',') {
+			return values, nil
+		}
+		p.lex.EatDelim(',')
+	}
+}
+
+// <Modify> := UPDATE IdTok SET <Field> = <Expression> [ WHERE <Predicate> ]
+func (p *Parser) Modify() (*ModifyData, error) {
+	err := p.lex.EatKeyword("update")
+	if err != nil {
+		return nil, err
+	}
+	tblname, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("set")
+	if err != nil {
+		return nil, err
+	}
+	fldname, err := p.Field()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim('=')
+	if err != nil {
+		return nil, err
+	}
+	newval, err := p.Expression()
+	if err != nil {
+		return nil, err
+	}
+	pred, err := p.optionalWhere()
+	if err != nil {
+		return nil, err
+	}
+	return &ModifyData{
+		TableName: tblname,
+		FieldName: fldname,
+		NewValue:  newval,
+		Pred:      pred,
+	}, nil
+}
+
+// <CreateTable> := CREATE TABLE IdTok ( <FieldDefs> )
+func (p *Parser) CreateTable() (*CreateTableData, error) {
+	err := p.lex.EatKeyword("table")
+	if err != nil {
+		return nil, err
+	}
+	tblname, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim('(')
+	if err != nil {
+		return nil, err
+	}
+	schema, err := p.fieldDefs()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim(')')
+	if err != nil {
+		return nil, err
+	}
+	return &CreateTableData{
+		TableName: tblname,
+		Schema:    schema,
+	}, nil
+}
+
+// <FieldDefs> := <FieldDef> [ , <FieldDefs> ]
+func (p *Parser) fieldDefs() (*record.Schema, error) {
+	schema := record.NewSchema()
+	for {
+		err := p.fieldDef(schema)
+		if err != nil {
+			return nil, err
+		}
+		if !p.lex.MatchDelim(',') {
+			return schema, nil
+		}
+		p.lex.EatDelim(',')
+	}
+}
+
+// <FieldDef> := IdTok <TypeDef>
+// <TypeDef> := INT | VARCHAR ( IntTok )
+func (p *Parser) fieldDef(schema *record.Schema) error {
+	pos := p.lex.Token().Pos
+	fldname, err := p.Field()
+	if err != nil {
+		return err
+	}
+	if schema.HasField(fldname) {
+		return &SyntaxError{Pos: pos, Msg: "duplicate field " + fldname}
+	}
+
+	switch {
+	case p.lex.MatchKeyword("int"):
+		p.lex.EatKeyword("int")
+		schema.AddIntField(fldname)
+	case p.lex.MatchKeyword("varchar"):
+		p.lex.EatKeyword("varchar")
+		err = p.lex.EatDelim('(')
+		if err != nil {
+			return err
+		}
+		lenPos := p.lex.Token().Pos
+		length, err := p.lex.EatInt()
+		if err != nil {
+			return err
+		}
+		if length <= 0 {
+			return &SyntaxError{Pos: lenPos, Msg: "varchar length must be positive"}
+		}
+		err = p.lex.EatDelim(')')
+		if err != nil {
+			return err
+		}
+		schema.AddStringField(fldname, int(length))
+	default:
+		return p.lex.unexpected(`"int" or "varchar"`)
+	}
+	return nil
+}
+
+// <CreateView> := CREATE VIEW IdTok AS <Query>
+func (p *Parser) CreateView() (*CreateViewData, error) {
+	err := p.lex.EatKeyword("view")
+	if err != nil {
+		return nil, err
+	}
+	viewname, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("as")
+	if err != nil {
+		return nil, err
+	}
+	qd, err := p.Query()
+	if err != nil {
+		return nil, err
+	}
+	return &CreateViewData{
+		ViewName: viewname,
+		Query:    qd,
+	}, nil
+}
+
+// <CreateIndex> := CREATE INDEX IdTok ON IdTok ( <Field> ) [ USING IdTok ]
+func (p *Parser) CreateIndex() (*CreateIndexData, error) {
+	err := p.lex.EatKeyword("index")
+	if err != nil {
+		return nil, err
+	}
+	idxname, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatKeyword("on")
+	if err != nil {
+		return nil, err
+	}
+	tblname, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim('(')
+	if err != nil {
+		return nil, err
+	}
+	fldname, err := p.Field()
+	if err != nil {
+		return nil, err
+	}
+	err = p.lex.EatDelim(')')
+	if err != nil {
+		return nil, err
+	}
+	idxtype := ""
+	if p.lex.MatchKeyword("using") {
+		p.lex.EatKeyword("using")
+		idxtype, err = p.lex.EatId()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &CreateIndexData{
+		IndexName: idxname,
+		TableName: tblname,
+		FieldName: fldname,
+		IndexType: idxtype,
+	}, nil
+}
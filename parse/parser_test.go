@@ -0,0 +1,310 @@
+package parse
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	schema := record.NewSchema()
+	schema.AddIntField("sid")
+	schema.AddStringField("sname", 10)
+	countSchema := record.NewSchema()
+	countSchema.AddIntField("count")
+
+	testcases := []struct {
+		name   string
+		sql    string
+		expect Statement
+	}{
+		{
+			name: "select",
+			sql:  "SELECT SName, GradYear FROM Student, Dept WHERE MajorId = DId AND SName = 'joe'",
+			expect: &QueryData{
+				Fields: []string{"sname", "gradyear"},
+				Tables: []string{"student", "dept"},
+				Pred: query.NewPredicate(
+					query.NewTerm(query.NewFieldExpression("majorid"), query.NewFieldExpression("did")),
+					query.NewTerm(query.NewFieldExpression("sname"), query.NewConstantExpression(query.NewStringConstant("joe"))),
+				),
+			},
+		},
+		{
+			name: "select without where",
+			sql:  "select a from t;",
+			expect: &QueryData{
+				Fields: []string{"a"},
+				Tables: []string{"t"},
+				Pred:   query.NewPredicate(),
+			},
+		},
+		{
+			name: "comparisons",
+			sql:  "select a from t where a >= 1 and b<>'x' and 2 < c and d != e",
+			expect: &QueryData{
+				Fields: []string{"a"},
+				Tables: []string{"t"},
+				Pred: query.NewPredicate(
+					query.NewComparisonTerm(query.NewFieldExpression("a"), query.Operator_GE, query.NewConstantExpression(query.NewIntConstant(1))),
+					query.NewComparisonTerm(query.NewFieldExpression("b"), query.Operator_NE, query.NewConstantExpression(query.NewStringConstant("x"))),
+					query.NewComparisonTerm(query.NewConstantExpression(query.NewIntConstant(2)), query.Operator_LT, query.NewFieldExpression("c")),
+					query.NewComparisonTerm(query.NewFieldExpression("d"), query.Operator_NE, query.NewFieldExpression("e")),
+				),
+			},
+		},
+		{
+			name: "between",
+			sql:  "select a from t where a between -5 and 5 and b = 1",
+			expect: &QueryData{
+				Fields: []string{"a"},
+				Tables: []string{"t"},
+				Pred: query.NewPredicate(
+					query.NewComparisonTerm(query.NewFieldExpression("a"), query.Operator_GE, query.NewConstantExpression(query.NewIntConstant(-5))),
+					query.NewComparisonTerm(query.NewFieldExpression("a"), query.Operator_LE, query.NewConstantExpression(query.NewIntConstant(5))),
+					query.NewTerm(query.NewFieldExpression("b"), query.NewConstantExpression(query.NewIntConstant(1))),
+				),
+			},
+		},
+		{
+			name: "group by",
+			sql:  "select dept, count(id), max(salary) from emp group by dept order by dept",
+			expect: &QueryData{
+				Fields:      []string{"dept", "countofid", "maxofsalary"},
+				Tables:      []string{"emp"},
+				Pred:        query.NewPredicate(),
+				GroupFields: []string{"dept"},
+				Aggregates: []*query.AggregationFn{
+					query.NewAggregationFn(query.Aggregate_COUNT, "id"),
+					query.NewAggregationFn(query.Aggregate_MAX, "salary"),
+				},
+				OrderFields: []string{"dept"},
+			},
+		},
+		{
+			name: "aggregate names as fields",
+			sql:  "select max, count (max) from sum",
+			expect: &QueryData{
+				Fields:     []string{"max", "countofmax"},
+				Tables:     []string{"sum"},
+				Pred:       query.NewPredicate(),
+				Aggregates: []*query.AggregationFn{query.NewAggregationFn(query.Aggregate_COUNT, "max")},
+			},
+		},
+		{
+			name: "order by",
+			sql:  "select a, b from t where a > 1 order by b, a",
+			expect: &QueryData{
+				Fields: []string{"a", "b"},
+				Tables: []string{"t"},
+				Pred: query.NewPredicate(
+					query.NewComparisonTerm(query.NewFieldExpression("a"), query.Operator_GT, query.NewConstantExpression(query.NewIntConstant(1))),
+				),
+				OrderFields: []string{"b", "a"},
+			},
+		},
+		{
+			name: "insert",
+			sql:  "insert into student (sid, sname) values (-1, 'O''Brien')",
+			expect: &InsertData{
+				TableName: "student",
+				Fields:    []string{"sid", "sname"},
+				Values:    []query.Constant{query.NewIntConstant(-1), query.NewStringConstant("O'Brien")},
+			},
+		},
+		{
+			name: "delete",
+			sql:  "delete from student where sid = 3",
+			expect: &DeleteData{
+				TableName: "student",
+				Pred: query.NewPredicate(
+					query.NewTerm(query.NewFieldExpression("sid"), query.NewConstantExpression(query.NewIntConstant(3))),
+				),
+			},
+		},
+		{
+			name: "update",
+			sql:  "update student set sname = 'amy' where sid = 3",
+			expect: &ModifyData{
+				TableName: "student",
+				FieldName: "sname",
+				NewValue:  query.NewConstantExpression(query.NewStringConstant("amy")),
+				Pred: query.NewPredicate(
+					query.NewTerm(query.NewFieldExpression("sid"), query.NewConstantExpression(query.NewIntConstant(3))),
+				),
+			},
+		},
+		{
+			name: "create table",
+			sql:  "create table student (sid int, sname varchar(10))",
+			expect: &CreateTableData{
+				TableName: "student",
+				Schema:    schema,
+			},
+		},
+		{
+			name: "create table with aggregate names",
+			sql:  "create table t (count int)",
+			expect: &CreateTableData{
+				TableName: "t",
+				Schema:    countSchema,
+			},
+		},
+		{
+			name: "create view",
+			sql:  "create view names as select sname from student where sid = 1",
+			expect: &CreateViewData{
+				ViewName: "names",
+				Query: &QueryData{
+					Fields: []string{"sname"},
+					Tables: []string{"student"},
+					Pred: query.NewPredicate(
+						query.NewTerm(query.NewFieldExpression("sid"), query.NewConstantExpression(query.NewIntConstant(1))),
+					),
+				},
+			},
+		},
+		{
+			name: "create index",
+			sql:  "create index sidx on student (sid)",
+			expect: &CreateIndexData{
+				IndexName: "sidx",
+				TableName: "student",
+				FieldName: "sid",
+			},
+		},
+		{
+			name: "create index using",
+			sql:  "create index sidx on student (sid) using BTree",
+			expect: &CreateIndexData{
+				IndexName: "sidx",
+				TableName: "student",
+				FieldName: "sid",
+				IndexType: "btree",
+			},
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := Parse(tt.sql)
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, stmt)
+		})
+	}
+}
+
+func TestParse_error(t *testing.T) {
+	testcases := []struct {
+		name   string
+		sql    string
+		pos    Position
+		errmsg string
+	}{
+		{
+			name:   "misspelled keyword",
+			sql:    "select a form t",
+			pos:    Position{Offset: 9, Line: 1, Column: 10},
+			errmsg: `syntax error at line 1, column 10: expected "from", found "form"`,
+		},
+		{
+			name:   "second line",
+			sql:    "select a\nfrom t where a =",
+			pos:    Position{Offset: 25, Line: 2, Column: 17},
+			errmsg: "syntax error at line 2, column 17: expected constant, found end of input",
+		},
+		{
+			name:   "trailing tokens",
+			sql:    "delete from t x",
+			pos:    Position{Offset: 14, Line: 1, Column: 15},
+			errmsg: `syntax error at line 1, column 15: expected end of input, found "x"`,
+		},
+		{
+			name:   "unterminated string",
+			sql:    "insert into t (a) values ('abc)",
+			pos:    Position{Offset: 26, Line: 1, Column: 27},
+			errmsg: "syntax error at line 1, column 27: unterminated string",
+		},
+		{
+			name:   "unexpected character",
+			sql:    "select a from t where a ! 1",
+			pos:    Position{Offset: 24, Line: 1, Column: 25},
+			errmsg: `syntax error at line 1, column 25: unexpected character '!'`,
+		},
+		{
+			name:   "values mismatch",
+			sql:    "insert into t (a, b) values (1)",
+			pos:    Position{Offset: 28, Line: 1, Column: 29},
+			errmsg: "syntax error at line 1, column 29: number of values does not match number of fields",
+		},
+		{
+			name:   "unknown type",
+			sql:    "create table t (a float)",
+			pos:    Position{Offset: 18, Line: 1, Column: 19},
+			errmsg: `syntax error at line 1, column 19: expected "int" or "varchar", found "float"`,
+		},
+		{
+			name:   "missing operator",
+			sql:    "select a from t where a 1",
+			pos:    Position{Offset: 24, Line: 1, Column: 25},
+			errmsg: "syntax error at line 1, column 25: expected comparison operator, found \"1\"",
+		},
+		{
+			name:   "incomplete between",
+			sql:    "select a from t where a between 1",
+			pos:    Position{Offset: 33, Line: 1, Column: 34},
+			errmsg: `syntax error at line 1, column 34: expected "and", found end of input`,
+		},
+		{
+			name:   "order without by",
+			sql:    "select a from t order a",
+			pos:    Position{Offset: 22, Line: 1, Column: 23},
+			errmsg: `syntax error at line 1, column 23: expected "by", found "a"`,
+		},
+		{
+			name:   "aggregate without field",
+			sql:    "select sum() from t",
+			pos:    Position{Offset: 11, Line: 1, Column: 12},
+			errmsg: `syntax error at line 1, column 12: expected identifier, found ")"`,
+		},
+		{
+			name:   "integer overflow",
+			sql:    "delete from t where a = 99999999999",
+			pos:    Position{Offset: 24, Line: 1, Column: 25},
+			errmsg: "syntax error at line 1, column 25: integer 99999999999 out of range",
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.sql)
+			require.ErrorIs(t, err, ErrSyntax)
+			var serr *SyntaxError
+			require.ErrorAs(t, err, &serr)
+			require.Equal(t, tt.pos, serr.Pos)
+			require.EqualError(t, err, tt.errmsg)
+		})
+	}
+}
+
+func TestQueryData_String(t *testing.T) {
+	stmt, err := Parse("select a, b from t, u where a = 'x' and b <= c")
+	require.NoError(t, err)
+	qd := stmt.(*QueryData)
+	require.Equal(t, "select a, b from t, u where a = 'x' and b <= c", qd.String())
+
+	// the text round-trips
+	again, err := Parse(qd.String())
+	require.NoError(t, err)
+	require.Equal(t, qd, again)
+
+	stmt, err = Parse("select d, avg(a), min(b) from t group by d order by avgofa")
+	require.NoError(t, err)
+	qd = stmt.(*QueryData)
+	require.Equal(t, "select d, avg(a), min(b) from t group by d order by avgofa", qd.String())
+	again, err = Parse(qd.String())
+	require.NoError(t, err)
+	require.Equal(t, qd, again)
+}
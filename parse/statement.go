@@ -0,0 +1,102 @@
+package parse
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"strings"
+)
+
+// Statement is one of the *Data types produced by the parser
+type Statement interface {
+	statement()
+}
+
+// QueryData is a SELECT statement
+type QueryData struct {
+	// Fields are the output fields. An aggregate is named after its function, such as "countofa".
+	Fields      []string
+	Tables      []string
+	Pred        *query.Predicate
+	GroupFields []string
+	Aggregates  []*query.AggregationFn
+	OrderFields []string
+}
+
+// String returns the query as SQL text, as stored in view definitions
+func (d *QueryData) String() string {
+	items := make([]string, len(d.Fields))
+	for i, field := range d.Fields {
+		items[i] = field
+		for _, fn := range d.Aggregates {
+			if fn.FieldName() == field {
+				items[i] = fn.String()
+			}
+		}
+	}
+	s := "select " + strings.Join(items, ", ") + " from " + strings.Join(d.Tables, ", ")
+	if !d.Pred.IsEmpty() {
+		s += " where " + d.Pred.String()
+	}
+	if len(d.GroupFields) > 0 {
+		s += " group by " + strings.Join(d.GroupFields, ", ")
+	}
+	if len(d.OrderFields) > 0 {
+		s += " order by " + strings.Join(d.OrderFields, ", ")
+	}
+	return s
+}
+
+// InsertData is an INSERT statement
+type InsertData struct {
+	TableName string
+	Fields    []string
+	Values    []query.Constant
+}
+
+// DeleteData is a DELETE statement
+type DeleteData struct {
+	TableName string
+	Pred      *query.Predicate
+}
+
+// ModifyData is an UPDATE statement setting one field
+type ModifyData struct {
+	TableName string
+	FieldName string
+	NewValue  *query.Expression
+	Pred      *query.Predicate
+}
+
+// CreateTableData is a CREATE TABLE statement
+type CreateTableData struct {
+	TableName string
+	Schema    *record.Schema
+}
+
+// CreateViewData is a CREATE VIEW statement
+type CreateViewData struct {
+	ViewName string
+	Query    *QueryData
+}
+
+// ViewDef returns the definition of the view to be stored in the catalog
+func (d *CreateViewData) ViewDef() string {
+	return d.Query.String()
+}
+
+// CreateIndexData is a CREATE INDEX statement
+type CreateIndexData struct {
+	IndexName string
+	TableName string
+	FieldName string
+	// IndexType is the name given after USING, or empty for the default index type
+	IndexType string
+}
+
+func (*QueryData) statement()       {}
+func (*InsertData) statement()      {}
+func (*DeleteData) statement()      {}
+func (*ModifyData) statement()      {}
+func (*CreateTableData) statement() {}
+func (*CreateViewData) statement()  {}
+func (*CreateIndexData) statement() {}
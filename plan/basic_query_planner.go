@@ -0,0 +1,86 @@
+package plan
+
+import (
+	"errors"
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/record"
+)
+
+// BasicQueryPlanner builds the product of the tables in the order they are listed,
+// then applies the predicate and the projection
+type BasicQueryPlanner struct {
+	mdm *metadata.MetadataManager
+}
+
+func NewBasicQueryPlanner(mdm *metadata.MetadataManager) *BasicQueryPlanner {
+	return &BasicQueryPlanner{
+		mdm: mdm,
+	}
+}
+
+func (qp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx record.Transaction) (Plan, error) {
+	var p Plan
+	for _, tblname := range data.Tables {
+		tp, err := tableOrView(qp, qp.mdm, tblname, tx)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			p = tp
+		} else {
+			p = NewProductPlan(p, tp)
+		}
+	}
+
+	err := checkPredicate(p.Schema(), data.Pred)
+	if err != nil {
+		return nil, err
+	}
+	p = NewSelectPlan(p, data.Pred)
+	return groupSortProject(p, data, tx)
+}
+
+// groupSortProject adds the grouping, the ordering and the projection of the query
+// on top of the plan which joins its tables
+func groupSortProject(p Plan, data *parse.QueryData, tx record.Transaction) (Plan, error) {
+	if len(data.GroupFields) > 0 || len(data.Aggregates) > 0 {
+		err := checkGrouping(p.Schema(), data)
+		if err != nil {
+			return nil, err
+		}
+		p = NewGroupByPlan(tx, p, data.GroupFields, data.Aggregates)
+	}
+	err := checkFields(p.Schema(), data.OrderFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.OrderFields) > 0 {
+		p = NewSortPlan(tx, p, data.OrderFields)
+	}
+	err = checkFields(p.Schema(), data.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return NewProjectPlan(p, data.Fields), nil
+}
+
+// tableOrView plans a view by planning its definition with qp, and a table with a TablePlan
+func tableOrView(qp QueryPlanner, mdm *metadata.MetadataManager, name string, tx record.Transaction) (Plan, error) {
+	vdef, err := mdm.GetViewDef(name, tx)
+	if errors.Is(err, metadata.ErrViewNotFound) {
+		return NewTablePlan(tx, name, mdm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := parse.Parse(vdef)
+	if err != nil {
+		return nil, err
+	}
+	view, ok := stmt.(*parse.QueryData)
+	if !ok {
+		return nil, ErrNotQuery
+	}
+	return qp.CreatePlan(view, tx)
+}
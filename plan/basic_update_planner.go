@@ -0,0 +1,169 @@
+package plan
+
+import (
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// BasicUpdatePlanner executes updates by scanning the whole table
+type BasicUpdatePlanner struct {
+	mdm *metadata.MetadataManager
+}
+
+func NewBasicUpdatePlanner(mdm *metadata.MetadataManager) *BasicUpdatePlanner {
+	return &BasicUpdatePlanner{
+		mdm: mdm,
+	}
+}
+
+func (up *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx record.Transaction) (int, error) {
+	p, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	err = checkInsert(p.Schema(), data)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := p.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	us := s.(query.UpdateScan)
+	err = us.Insert()
+	if err != nil {
+		return 0, err
+	}
+	for i, field := range data.Fields {
+		err = us.SetVal(field, data.Values[i])
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 1, nil
+}
+
+func (up *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx record.Transaction) (int, error) {
+	tp, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	err = checkPredicate(tp.Schema(), data.Pred)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := NewSelectPlan(tp, data.Pred).Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	us := s.(query.UpdateScan)
+	count := 0
+	for {
+		ok, err := us.Next()
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			return count, nil
+		}
+		err = us.Delete()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func (up *BasicUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx record.Transaction) (int, error) {
+	tp, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	schema := tp.Schema()
+	err = checkModify(schema, data)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := NewSelectPlan(tp, data.Pred).Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	us := s.(query.UpdateScan)
+	count := 0
+	for {
+		ok, err := us.Next()
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			return count, nil
+		}
+		val, err := data.NewValue.Evaluate(us)
+		if err != nil {
+			return count, err
+		}
+		err = checkValue(schema, data.FieldName, val)
+		if err != nil {
+			return count, err
+		}
+		err = us.SetVal(data.FieldName, val)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func (up *BasicUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, tx record.Transaction) (int, error) {
+	return 0, up.mdm.CreateTable(data.TableName, data.Schema, tx)
+}
+
+func (up *BasicUpdatePlanner) ExecuteCreateView(data *parse.CreateViewData, tx record.Transaction) (int, error) {
+	return 0, up.mdm.CreateView(data.ViewName, data.ViewDef(), tx)
+}
+
+func (up *BasicUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx record.Transaction) (int, error) {
+	tp, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	err = checkFields(tp.Schema(), []string{data.FieldName})
+	if err != nil {
+		return 0, err
+	}
+	return 0, up.mdm.CreateIndex(data.IndexName, data.IndexType, data.TableName, data.FieldName, tx)
+}
+
+func checkInsert(schema *record.Schema, data *parse.InsertData) error {
+	for i, field := range data.Fields {
+		err := checkValue(schema, field, data.Values[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkModify(schema *record.Schema, data *parse.ModifyData) error {
+	err := checkFields(schema, []string{data.FieldName})
+	if err != nil {
+		return err
+	}
+	if data.NewValue.IsFieldName() {
+		err = checkFields(schema, []string{data.NewValue.AsFieldName()})
+	} else {
+		err = checkValue(schema, data.FieldName, data.NewValue.AsConstant())
+	}
+	if err != nil {
+		return err
+	}
+	return checkPredicate(schema, data.Pred)
+}
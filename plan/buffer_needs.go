@@ -0,0 +1,43 @@
+package plan
+
+import "math"
+
+// reservedBuffers is the number of available buffers an operator leaves to the rest of the system
+// when it sizes itself by the buffers which are available
+const reservedBuffers = 2
+
+// usableBuffers returns the number of buffers an operator may claim out of the available ones.
+// It is at least 1.
+func usableBuffers(available int) int {
+	return max(available-reservedBuffers, 1)
+}
+
+// BestRoot returns the largest root of size which fits in the usable buffers:
+// the number of inputs to process at a time so that size inputs are processed
+// in as few, evenly sized passes as possible
+func BestRoot(available, size int) int {
+	avail := usableBuffers(available)
+	if avail == 1 {
+		return 1
+	}
+	k := math.MaxInt
+	for i := 1.0; k > avail; i++ {
+		k = int(math.Ceil(math.Pow(float64(size), 1/i)))
+	}
+	return k
+}
+
+// BestFactor returns the largest factor of size, rounded up, which fits in the usable buffers:
+// the number of blocks to process at a time so that size blocks are processed
+// in as few, evenly sized chunks as possible
+func BestFactor(available, size int) int {
+	avail := usableBuffers(available)
+	if avail == 1 {
+		return 1
+	}
+	if size <= avail {
+		return max(size, 1)
+	}
+	// the fewest chunks which fit, then the size of each
+	return ceilDiv(size, ceilDiv(size, avail))
+}
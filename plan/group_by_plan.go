@@ -0,0 +1,79 @@
+package plan
+
+import (
+	"math"
+	"simpledb/query"
+	"simpledb/record"
+	"slices"
+)
+
+// GroupByPlan groups the records of the underlying plan on the group fields and computes
+// the aggregation functions of each group. Without group fields, all records form one group.
+type GroupByPlan struct {
+	p           Plan
+	groupfields []string
+	aggfns      []*query.AggregationFn
+	schema      *record.Schema
+}
+
+func NewGroupByPlan(tx record.Transaction, p Plan, groupfields []string, aggfns []*query.AggregationFn) *GroupByPlan {
+	schema := record.NewSchema()
+	for _, field := range groupfields {
+		schema.Add(field, p.Schema())
+	}
+	for _, fn := range aggfns {
+		switch fn.Aggregate() {
+		case query.Aggregate_MIN, query.Aggregate_MAX:
+			src := p.Schema()
+			schema.AddField(fn.FieldName(), src.Type(fn.SourceField()), src.Length(fn.SourceField()))
+		default:
+			schema.AddIntField(fn.FieldName())
+		}
+	}
+	if len(groupfields) > 0 {
+		p = NewSortPlan(tx, p, groupfields)
+	}
+	return &GroupByPlan{
+		p:           p,
+		groupfields: groupfields,
+		aggfns:      aggfns,
+		schema:      schema,
+	}
+}
+
+func (gp *GroupByPlan) Open() (query.Scan, error) {
+	s, err := gp.p.Open()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewGroupByScan(s, gp.groupfields, gp.aggfns)
+}
+
+func (gp *GroupByPlan) BlocksAccessed() int {
+	return gp.p.BlocksAccessed()
+}
+
+// RecordsOutput estimates the number of groups as the number of combinations of the group values
+func (gp *GroupByPlan) RecordsOutput() int {
+	groups := 1
+	for _, field := range gp.groupfields {
+		dv := gp.p.DistinctValues(field)
+		if groups > math.MaxInt/dv {
+			return gp.p.RecordsOutput()
+		}
+		groups *= dv
+	}
+	return min(groups, max(gp.p.RecordsOutput(), 1))
+}
+
+// DistinctValues assumes that every group has its own aggregate values
+func (gp *GroupByPlan) DistinctValues(field string) int {
+	if slices.Contains(gp.groupfields, field) {
+		return gp.p.DistinctValues(field)
+	}
+	return gp.RecordsOutput()
+}
+
+func (gp *GroupByPlan) Schema() *record.Schema {
+	return gp.schema
+}
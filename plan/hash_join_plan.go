@@ -0,0 +1,186 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// HashJoinPlan joins lhs and rhs on lhsfield = rhsfield by building a hash table of rhs in memory.
+// When rhs takes more blocks than the usable buffers, both inputs are first partitioned
+// into temporary tables by the hash of the join field (a grace hash join), so that each
+// partition of rhs is loaded on its own. A partition of rhs which still takes more blocks
+// than the usable buffers, such as one holding many records with the same value,
+// is loaded a chunk of usable buffers at a time.
+// The buffer budget is taken from the buffers available when the plan is created,
+// and the partitions are removed once the scan is closed.
+type HashJoinPlan struct {
+	tx        record.Transaction
+	available int
+	lhs       Plan
+	rhs       Plan
+	lhsfield  string
+	rhsfield  string
+	schema    *record.Schema
+}
+
+func NewHashJoinPlan(tx record.Transaction, lhs, rhs Plan, lhsfield, rhsfield string) *HashJoinPlan {
+	schema := record.NewSchema()
+	schema.AddAll(lhs.Schema())
+	schema.AddAll(rhs.Schema())
+	return &HashJoinPlan{
+		tx:        tx,
+		available: tx.AvailableBuffs(),
+		lhs:       lhs,
+		rhs:       rhs,
+		lhsfield:  lhsfield,
+		rhsfield:  rhsfield,
+		schema:    schema,
+	}
+}
+
+func (hp *HashJoinPlan) Open() (query.Scan, error) {
+	var parts []query.HashPartition
+	var temps []*query.TempTable
+	numparts := hp.partitions(hp.available)
+	if numparts == 1 {
+		parts = []query.HashPartition{func() (query.Scan, query.Scan, error) {
+			return openBoth(hp.lhs.Open, hp.rhs.Open)
+		}}
+	} else {
+		lhsparts, err := partition(hp.tx, hp.lhs, hp.lhsfield, numparts)
+		if err != nil {
+			return nil, err
+		}
+		rhsparts, err := partition(hp.tx, hp.rhs, hp.rhsfield, numparts)
+		if err != nil {
+			removeTemps(lhsparts)
+			return nil, err
+		}
+		temps = append(lhsparts, rhsparts...)
+		parts = make([]query.HashPartition, numparts)
+		for i := range parts {
+			parts[i] = func() (query.Scan, query.Scan, error) {
+				return openBoth(lhsparts[i].Open, rhsparts[i].Open)
+			}
+		}
+	}
+	// the hash table holds as many rhs records as fit in the usable buffers
+	rpb := max(hp.tx.BlockSize()/record.NewLayout(hp.rhs.Schema()).SlotSize(), 1)
+	chunksize := mulCost(usableBuffers(hp.available), rpb)
+	s, err := query.NewHashJoinScan(parts, hp.lhs.Schema(), hp.rhs.Schema(), hp.lhsfield, hp.rhsfield, chunksize)
+	if err != nil {
+		removeTemps(temps)
+		return nil, err
+	}
+	return &tempsScan{Scan: s, temps: temps}, nil
+}
+
+// partitions returns the number of partitions needed for each partition of rhs to fit in the usable buffers.
+// Partitioning writes to every partition at once, which bounds their number as well.
+func (hp *HashJoinPlan) partitions(available int) int {
+	usable := usableBuffers(available)
+	size := NewMaterializePlan(hp.tx, hp.rhs).BlocksAccessed()
+	if size <= usable {
+		return 1
+	}
+	return min(ceilDiv(size, usable), max(usable-1, 2))
+}
+
+// partition copies the output of the plan into n temporary tables by the hash of the field
+func partition(tx record.Transaction, p Plan, field string, n int) ([]*query.TempTable, error) {
+	src, err := p.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	parts := make([]*query.TempTable, n)
+	for i := range parts {
+		parts[i] = query.NewTempTable(tx, p.Schema())
+	}
+	err = copyPartitions(src, parts, field)
+	if err != nil {
+		removeTemps(parts)
+		return nil, err
+	}
+	return parts, nil
+}
+
+// copyPartitions copies each record of src into the partition its field hashes to
+func copyPartitions(src query.Scan, parts []*query.TempTable, field string) error {
+	n := len(parts)
+	dests := make([]*query.TableScan, 0, n)
+	defer func() {
+		for _, dest := range dests {
+			dest.Close()
+		}
+	}()
+	for _, part := range parts {
+		dest, err := part.Open()
+		if err != nil {
+			return err
+		}
+		dests = append(dests, dest)
+	}
+
+	fields := parts[0].Layout().Schema().Fields()
+	for {
+		ok, err := src.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		val, err := src.GetVal(field)
+		if err != nil {
+			return err
+		}
+		bucket := (val.HashCode()%n + n) % n
+		err = query.CopyRecord(src, dests[bucket], fields)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// openBoth opens the inputs of a partition, closing lhs if rhs fails to open
+func openBoth[S1, S2 query.Scan](lhs func() (S1, error), rhs func() (S2, error)) (query.Scan, query.Scan, error) {
+	s1, err := lhs()
+	if err != nil {
+		return nil, nil, err
+	}
+	s2, err := rhs()
+	if err != nil {
+		s1.Close()
+		return nil, nil, err
+	}
+	return s1, s2, nil
+}
+
+// BlocksAccessed counts one pass over both inputs, plus writing and reading back
+// their partitions when rhs does not fit in the usable buffers
+func (hp *HashJoinPlan) BlocksAccessed() int {
+	cost := addCost(hp.lhs.BlocksAccessed(), hp.rhs.BlocksAccessed())
+	if hp.partitions(hp.available) == 1 {
+		return cost
+	}
+	lhsSize := NewMaterializePlan(hp.tx, hp.lhs).BlocksAccessed()
+	rhsSize := NewMaterializePlan(hp.tx, hp.rhs).BlocksAccessed()
+	return addCost(cost, mulCost(2, addCost(lhsSize, rhsSize)))
+}
+
+func (hp *HashJoinPlan) RecordsOutput() int {
+	return joinRecordsOutput(hp.lhs, hp.rhs, hp.lhsfield, hp.rhsfield)
+}
+
+func (hp *HashJoinPlan) DistinctValues(field string) int {
+	if hp.lhs.Schema().HasField(field) {
+		return hp.lhs.DistinctValues(field)
+	}
+	return hp.rhs.DistinctValues(field)
+}
+
+func (hp *HashJoinPlan) Schema() *record.Schema {
+	return hp.schema
+}
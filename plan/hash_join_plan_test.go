@@ -0,0 +1,212 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record/recordtest"
+	"simpledb/storage"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBestFactor(t *testing.T) {
+	testcases := []struct {
+		available int
+		size      int
+		expect    int
+	}{
+		{available: 3, size: 100, expect: 1},
+		{available: 12, size: 8, expect: 8},
+		{available: 12, size: 100, expect: 10},
+		{available: 12, size: 21, expect: 7},
+		{available: 8, size: 0, expect: 1},
+		{available: 12, size: math.MaxInt, expect: 10},
+	}
+	for _, tt := range testcases {
+		t.Run(fmt.Sprintf("%d/%d", tt.available, tt.size), func(t *testing.T) {
+			require.Equal(t, tt.expect, BestFactor(tt.available, tt.size))
+		})
+	}
+}
+
+// newJoinTables creates r (a, x) and s (b, y) where every value of a matches 3 values of b
+func newJoinTables(t *testing.T, tx *recordtest.Transaction) (Plan, Plan) {
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table r (a int, x varchar(8))")
+	execute(t, planner, tx, "create table s (b int, y varchar(8))")
+	for i := 0; i < 60; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into r (a, x) values (%d, 'x%d')", i, i))
+	}
+	for i := 0; i < 150; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into s (b, y) values (%d, 'y%d')", i%50, i))
+	}
+	// refresh the statistics
+	mdm, err := metadata.NewMetadataManager(false, tx)
+	require.NoError(t, err)
+	r, err := NewTablePlan(tx, "r", mdm)
+	require.NoError(t, err)
+	s, err := NewTablePlan(tx, "s", mdm)
+	require.NoError(t, err)
+	return r, s
+}
+
+func collectPlan(t *testing.T, p Plan) []string {
+	t.Helper()
+	s, err := p.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	got := []string{}
+	for {
+		ok, err := s.Next()
+		require.NoError(t, err)
+		if !ok {
+			return got
+		}
+		row := ""
+		for _, field := range p.Schema().Fields() {
+			val, err := s.GetVal(field)
+			require.NoError(t, err)
+			row += val.String() + " "
+		}
+		got = append(got, row)
+	}
+}
+
+func TestHashJoinPlan(t *testing.T) {
+	// 150 records of s take 15 blocks
+	for _, tt := range []struct {
+		available int
+		parts     int
+	}{
+		{available: 64, parts: 1},
+		{available: 6, parts: 3},
+		{available: 3, parts: 2},
+	} {
+		t.Run(fmt.Sprintf("available %d", tt.available), func(t *testing.T) {
+			tx := recordtest.NewTransaction(200)
+			r, s := newJoinTables(t, tx)
+			tx.SetAvailableBuffs(tt.available)
+
+			pred := query.NewPredicate(query.NewTerm(query.NewFieldExpression("a"), query.NewFieldExpression("b")))
+			expect := collectPlan(t, NewSelectPlan(NewProductPlan(r, s), pred))
+			require.Len(t, expect, 150)
+
+			hp := NewHashJoinPlan(tx, r, s, "a", "b")
+			require.Equal(t, tt.parts, hp.partitions(tx.AvailableBuffs()))
+			require.ElementsMatch(t, expect, collectPlan(t, hp))
+			require.Equal(t, 150, hp.RecordsOutput())
+
+			// the scan can be rewound
+			scan, err := hp.Open()
+			require.NoError(t, err)
+			n := 0
+			for range 2 {
+				require.NoError(t, scan.BeforeFirst())
+				for {
+					ok, err := scan.Next()
+					require.NoError(t, err)
+					if !ok {
+						break
+					}
+					n++
+				}
+			}
+			scan.Close()
+			require.Equal(t, 300, n)
+			require.Empty(t, tx.Pinned())
+			require.False(t, slices.ContainsFunc(tx.Files(), storage.IsTempFile))
+		})
+	}
+}
+
+func TestHashJoinPlan_skewed(t *testing.T) {
+	tx := recordtest.NewTransaction(200)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table r (a int, x varchar(8))")
+	execute(t, planner, tx, "create table s (b int, y varchar(8))")
+	for i := 0; i < 3; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into r (a, x) values (%d, 'x%d')", i, i))
+	}
+	// every record of s has the same value, so partitioning leaves it in a single partition
+	for i := 0; i < 150; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into s (b, y) values (1, 'y%d')", i))
+	}
+	mdm, err := metadata.NewMetadataManager(false, tx)
+	require.NoError(t, err)
+	r, err := NewTablePlan(tx, "r", mdm)
+	require.NoError(t, err)
+	s, err := NewTablePlan(tx, "s", mdm)
+	require.NoError(t, err)
+
+	// the buffers are counted when the plan is created
+	tx.SetAvailableBuffs(3)
+	hp := NewHashJoinPlan(tx, r, s, "a", "b")
+	cost := hp.BlocksAccessed()
+	tx.SetAvailableBuffs(64)
+	require.Equal(t, cost, hp.BlocksAccessed())
+	require.Equal(t, 2, hp.partitions(hp.available))
+
+	// the oversized partition is joined a chunk at a time
+	pred := query.NewPredicate(query.NewTerm(query.NewFieldExpression("a"), query.NewFieldExpression("b")))
+	expect := collectPlan(t, NewSelectPlan(NewProductPlan(r, s), pred))
+	require.Len(t, expect, 150)
+	require.ElementsMatch(t, expect, collectPlan(t, hp))
+	require.Empty(t, tx.Pinned())
+	require.False(t, slices.ContainsFunc(tx.Files(), storage.IsTempFile))
+}
+
+func TestHashJoinPlan_estimates(t *testing.T) {
+	tx := recordtest.NewTransaction(200)
+	r, s := newJoinTables(t, tx)
+
+	tx.SetAvailableBuffs(64)
+	require.Equal(t, r.BlocksAccessed()+s.BlocksAccessed(), NewHashJoinPlan(tx, r, s, "a", "b").BlocksAccessed())
+
+	// partitioning writes and reads back both tables
+	tx.SetAvailableBuffs(6)
+	require.Equal(t, 3*(r.BlocksAccessed()+s.BlocksAccessed()), NewHashJoinPlan(tx, r, s, "a", "b").BlocksAccessed())
+
+	// the costs of an input whose estimates saturate saturate as well
+	huge := r
+	for range 4 {
+		huge = NewProductPlan(huge, huge)
+	}
+	require.Equal(t, math.MaxInt, huge.BlocksAccessed())
+	require.Equal(t, math.MaxInt, NewHashJoinPlan(tx, huge, s, "a", "b").BlocksAccessed())
+	require.Equal(t, math.MaxInt, NewHashJoinPlan(tx, s, huge, "b", "a").BlocksAccessed())
+	require.Equal(t, math.MaxInt, NewMultibufferProductPlan(tx, s, huge).BlocksAccessed())
+	require.Equal(t, math.MaxInt, NewMultibufferProductPlan(tx, huge, s).BlocksAccessed())
+}
+
+func TestMultibufferProductPlan(t *testing.T) {
+	for _, available := range []int{3, 5, 64} {
+		t.Run(fmt.Sprintf("available %d", available), func(t *testing.T) {
+			tx := recordtest.NewTransaction(200)
+			r, s := newJoinTables(t, tx)
+			tx.SetAvailableBuffs(available)
+
+			mp := NewMultibufferProductPlan(tx, r, s)
+			expect := collectPlan(t, NewProductPlan(r, s))
+			got := collectPlan(t, mp)
+			slices.Sort(expect)
+			slices.Sort(got)
+			require.Equal(t, expect, got)
+			require.Empty(t, tx.Pinned())
+			require.False(t, slices.ContainsFunc(tx.Files(), storage.IsTempFile))
+		})
+	}
+
+	// r is scanned once per chunk of s rather than s once per record of r
+	tx := recordtest.NewTransaction(200)
+	r, s := newJoinTables(t, tx)
+	tx.SetAvailableBuffs(7)
+	mp := NewMultibufferProductPlan(tx, r, s)
+	require.Equal(t, s.BlocksAccessed()+3*r.BlocksAccessed(), mp.BlocksAccessed())
+	require.Less(t, mp.BlocksAccessed(), NewProductPlan(r, s).BlocksAccessed())
+	require.Equal(t, mp.Schema().Fields(), []string{"a", "x", "b", "y"})
+}
@@ -0,0 +1,217 @@
+package plan
+
+import (
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// HeuristicQueryPlanner chooses the join order greedily from the catalog statistics.
+// It starts with the table whose selection outputs the fewest records, then repeatedly
+// joins the table giving the smallest output. Tables which can't be joined by a term of
+// the predicate are only multiplied in when nothing else is left.
+// Selection terms are applied to each table before it is joined, through an index
+// when one covers a term of the form "field = constant" or a B-tree index covers a
+// range of the field. Each join uses whichever of an index join, a hash join, a merge
+// join or a multibuffer product accesses the fewest blocks; the costs of all but the
+// first depend on the buffers available when the query is planned.
+type HeuristicQueryPlanner struct {
+	mdm *metadata.MetadataManager
+}
+
+func NewHeuristicQueryPlanner(mdm *metadata.MetadataManager) *HeuristicQueryPlanner {
+	return &HeuristicQueryPlanner{
+		mdm: mdm,
+	}
+}
+
+func (qp *HeuristicQueryPlanner) CreatePlan(data *parse.QueryData, tx record.Transaction) (Plan, error) {
+	schema := record.NewSchema()
+	planners := make([]*tablePlanner, len(data.Tables))
+	for i, tblname := range data.Tables {
+		p, err := tableOrView(qp, qp.mdm, tblname, tx)
+		if err != nil {
+			return nil, err
+		}
+		schema.AddAll(p.Schema())
+		var indexes map[string]*metadata.IndexInfo
+		if _, ok := p.(*TablePlan); ok {
+			indexes, err = qp.mdm.GetIndexInfo(tblname, tx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		planners[i] = newTablePlanner(tx, p, data.Pred, indexes)
+	}
+	err := checkPredicate(schema, data.Pred)
+	if err != nil {
+		return nil, err
+	}
+
+	current, planners := lowestPlan(planners, func(tp *tablePlanner) Plan {
+		return tp.makeSelectPlan()
+	})
+	for len(planners) > 0 {
+		var next Plan
+		next, planners = lowestPlan(planners, func(tp *tablePlanner) Plan {
+			return tp.makeJoinPlan(current)
+		})
+		if next == nil {
+			next, planners = lowestPlan(planners, func(tp *tablePlanner) Plan {
+				return tp.makeProductPlan(current)
+			})
+		}
+		current = next
+	}
+	return groupSortProject(current, data, tx)
+}
+
+// lowestPlan builds a plan with each table planner and returns the one with the fewest output records,
+// along with the remaining planners. It returns nil and the planners unchanged if no plan could be built.
+func lowestPlan(planners []*tablePlanner, build func(tp *tablePlanner) Plan) (Plan, []*tablePlanner) {
+	var best Plan
+	bestIdx := -1
+	for i, tp := range planners {
+		p := build(tp)
+		if p != nil && (best == nil || p.RecordsOutput() < best.RecordsOutput()) {
+			best, bestIdx = p, i
+		}
+	}
+	if best == nil {
+		return nil, planners
+	}
+	rest := make([]*tablePlanner, 0, len(planners)-1)
+	rest = append(rest, planners[:bestIdx]...)
+	rest = append(rest, planners[bestIdx+1:]...)
+	return best, rest
+}
+
+// tablePlanner builds the plans which add one table or view to a partial query plan.
+// Only stored tables have indexes.
+type tablePlanner struct {
+	tx      record.Transaction
+	p       Plan
+	pred    *query.Predicate
+	indexes map[string]*metadata.IndexInfo
+}
+
+func newTablePlanner(tx record.Transaction, p Plan, pred *query.Predicate, indexes map[string]*metadata.IndexInfo) *tablePlanner {
+	return &tablePlanner{
+		tx:      tx,
+		p:       p,
+		pred:    pred,
+		indexes: indexes,
+	}
+}
+
+// makeSelectPlan applies the selection terms which concern only this table
+func (tp *tablePlanner) makeSelectPlan() Plan {
+	p := tp.makeIndexSelect()
+	if p == nil {
+		p = tp.p
+	}
+	return addSelectPred(p, tp.pred.SelectSubPred(tp.p.Schema()))
+}
+
+// makeJoinPlan joins the table to current, or returns nil if no term of the predicate joins them
+func (tp *tablePlanner) makeJoinPlan(current Plan) Plan {
+	joinpred := tp.pred.JoinSubPred(tp.p.Schema(), current.Schema())
+	if joinpred == nil {
+		return nil
+	}
+	p := cheapest(tp.makeIndexJoin(current), tp.makeHashJoin(current), tp.makeMergeJoin(current), tp.makeProductPlan(current))
+	return addSelectPred(p, joinpred)
+}
+
+// makeProductPlan multiplies current with the table, by a nested loop or a multibuffer product
+func (tp *tablePlanner) makeProductPlan(current Plan) Plan {
+	p := tp.makeSelectPlan()
+	return cheapest(NewProductPlan(current, p), NewMultibufferProductPlan(tp.tx, current, p))
+}
+
+// makeIndexSelect looks the table up through an index covering a term "field = constant",
+// or else through a B-tree index on a field the predicate bounds
+func (tp *tablePlanner) makeIndexSelect() Plan {
+	for _, field := range tp.p.Schema().Fields() {
+		ii, ok := tp.indexes[field]
+		if !ok {
+			continue
+		}
+		if val, ok := tp.pred.EquatesWithConstant(field); ok {
+			return NewIndexSelectPlan(tp.p.(*TablePlan), ii, val)
+		}
+	}
+	for _, field := range tp.p.Schema().Fields() {
+		ii, ok := tp.indexes[field]
+		if !ok || ii.IndexType() != metadata.IndexType_BTREE {
+			continue
+		}
+		if lo, hi := tp.pred.Bounds(field); lo != nil || hi != nil {
+			return NewIndexRangePlan(tp.p.(*TablePlan), ii, lo, hi)
+		}
+	}
+	return nil
+}
+
+func (tp *tablePlanner) makeIndexJoin(current Plan) Plan {
+	for _, field := range tp.p.Schema().Fields() {
+		ii, ok := tp.indexes[field]
+		if !ok {
+			continue
+		}
+		outerfield, ok := tp.pred.EquatesWithField(field)
+		if ok && current.Schema().HasField(outerfield) {
+			p := NewIndexJoinPlan(current, tp.p.(*TablePlan), ii, outerfield)
+			return addSelectPred(p, tp.pred.SelectSubPred(tp.p.Schema()))
+		}
+	}
+	return nil
+}
+
+// makeHashJoin builds a hash table of the table on its join field
+func (tp *tablePlanner) makeHashJoin(current Plan) Plan {
+	outerfield, field, ok := tp.joinFields(current)
+	if !ok {
+		return nil
+	}
+	return NewHashJoinPlan(tp.tx, current, tp.makeSelectPlan(), outerfield, field)
+}
+
+// makeMergeJoin merges current with the table, both sorted on their join fields
+func (tp *tablePlanner) makeMergeJoin(current Plan) Plan {
+	outerfield, field, ok := tp.joinFields(current)
+	if !ok {
+		return nil
+	}
+	return NewMergeJoinPlan(tp.tx, current, tp.makeSelectPlan(), outerfield, field)
+}
+
+// joinFields finds a field of the table which the predicate equates with a field of current
+func (tp *tablePlanner) joinFields(current Plan) (outerfield, field string, ok bool) {
+	for _, field := range tp.p.Schema().Fields() {
+		outerfield, ok := tp.pred.EquatesWithField(field)
+		if ok && current.Schema().HasField(outerfield) {
+			return outerfield, field, true
+		}
+	}
+	return "", "", false
+}
+
+// cheapest returns the plan accessing the fewest blocks, ignoring nil plans
+func cheapest(plans ...Plan) Plan {
+	var best Plan
+	for _, p := range plans {
+		if p != nil && (best == nil || p.BlocksAccessed() < best.BlocksAccessed()) {
+			best = p
+		}
+	}
+	return best
+}
+
+func addSelectPred(p Plan, pred *query.Predicate) Plan {
+	if pred == nil {
+		return p
+	}
+	return NewSelectPlan(p, pred)
+}
@@ -0,0 +1,126 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/query"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeuristicQueryPlanner(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table student (sid int, sname varchar(10), majorid int)")
+	execute(t, planner, tx, "create table dept (did int, dname varchar(10))")
+	execute(t, planner, tx, "create table enroll (studentid int, grade varchar(2))")
+	for i := 0; i < 3; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into dept (did, dname) values (%d, 'd%d')", i, i))
+	}
+	for i := 0; i < 60; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into student (sid, sname, majorid) values (%d, 's%d', %d)", i, i, i%3))
+	}
+	for i := 0; i < 200; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into enroll (studentid, grade) values (%d, '%c')", i%60, 'A'+i%4))
+	}
+	execute(t, planner, tx, "create view d1 as select did, dname from dept where did = 1")
+
+	// refresh the statistics
+	mdm, err := metadata.NewMetadataManager(false, tx)
+	require.NoError(t, err)
+	basic := NewPlanner(NewBasicQueryPlanner(mdm), nil)
+	heuristic := NewPlanner(NewHeuristicQueryPlanner(mdm), nil)
+
+	testcases := []string{
+		"select sname, grade from enroll, student, dept where studentid = sid and majorid = did and dname = 'd2'",
+		"select sname, dname from student, d1 where majorid = did and sid = 4",
+		"select dname, grade from dept, enroll where grade = 'B' and did = 0",
+		"select sid from student where 1 = 1 and sname = 's7'",
+	}
+	for _, sql := range testcases {
+		t.Run(sql, func(t *testing.T) {
+			bp, err := basic.CreateQueryPlan(sql, tx)
+			require.NoError(t, err)
+			hp, err := heuristic.CreateQueryPlan(sql, tx)
+			require.NoError(t, err)
+
+			require.Equal(t, bp.Schema(), hp.Schema())
+			require.LessOrEqual(t, hp.BlocksAccessed(), bp.BlocksAccessed())
+			require.ElementsMatch(t, rows(t, basic, tx, sql), rows(t, heuristic, tx, sql))
+		})
+	}
+
+	// the selection on dept is pushed down and dept comes first
+	p, err := heuristic.CreateQueryPlan(testcases[0], tx)
+	require.NoError(t, err)
+	p = p.(*ProjectPlan).p
+	for {
+		switch node := p.(type) {
+		case *SelectPlan:
+			p = node.p
+			continue
+		case *ProductPlan:
+			p = node.p1
+			continue
+		case *MultibufferProductPlan:
+			p = node.lhs
+			continue
+		case *HashJoinPlan:
+			p = node.lhs
+			continue
+		case *MergeJoinPlan:
+			p = node.p1.p
+			continue
+		}
+		break
+	}
+	require.Equal(t, "dept", p.(*TablePlan).tblname)
+
+	_, err = heuristic.CreateQueryPlan("select nosuchfield from student, dept", tx)
+	require.Error(t, err)
+	_, err = heuristic.CreateQueryPlan("select sid from student, nosuchtable", tx)
+	require.ErrorIs(t, err, metadata.ErrTableNotFound)
+	_, err = heuristic.CreateQueryPlan("select from student", tx)
+	require.ErrorIs(t, err, parse.ErrSyntax)
+	require.Empty(t, tx.Pinned())
+}
+
+func TestHeuristicQueryPlanner_saturatedCost(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table student (sid int, majorid int)")
+	execute(t, planner, tx, "create table dept (did int, dname varchar(10))")
+	execute(t, planner, tx, "create index didx on dept (did)")
+	for i := 0; i < 60; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into student (sid, majorid) values (%d, %d)", i, i%3))
+	}
+	for i := 0; i < 3; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into dept (did, dname) values (%d, 'd%d')", i, i))
+	}
+	mdm, err := metadata.NewMetadataManager(false, tx)
+	require.NoError(t, err)
+	student, err := NewTablePlan(tx, "student", mdm)
+	require.NoError(t, err)
+	dept, err := NewTablePlan(tx, "dept", mdm)
+	require.NoError(t, err)
+	indexes, err := mdm.GetIndexInfo("dept", tx)
+	require.NoError(t, err)
+
+	// joining an input whose cost saturates costs as much whichever the join,
+	// rather than making the hash or merge join look cheapest by wrapping around
+	current := Plan(student)
+	for range 4 {
+		current = NewProductPlan(current, current)
+	}
+	require.Equal(t, math.MaxInt, current.BlocksAccessed())
+	pred := query.NewPredicate(query.NewTerm(query.NewFieldExpression("majorid"), query.NewFieldExpression("did")))
+	tp := newTablePlanner(tx, dept, pred, indexes)
+	require.Equal(t, math.MaxInt, tp.makeHashJoin(current).BlocksAccessed())
+	require.Equal(t, math.MaxInt, tp.makeMergeJoin(current).BlocksAccessed())
+	p := tp.makeJoinPlan(current)
+	require.IsType(t, &IndexJoinPlan{}, p.(*SelectPlan).p)
+}
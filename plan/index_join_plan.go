@@ -0,0 +1,78 @@
+package plan
+
+import (
+	"simpledb/index"
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// IndexJoinPlan joins p1 with the table p2 by looking the join field of each record of p1
+// up in an index of p2
+type IndexJoinPlan struct {
+	p1        Plan
+	p2        *TablePlan
+	ii        *metadata.IndexInfo
+	joinfield string
+	schema    *record.Schema
+}
+
+func NewIndexJoinPlan(p1 Plan, p2 *TablePlan, ii *metadata.IndexInfo, joinfield string) *IndexJoinPlan {
+	schema := record.NewSchema()
+	schema.AddAll(p1.Schema())
+	schema.AddAll(p2.Schema())
+	return &IndexJoinPlan{
+		p1:        p1,
+		p2:        p2,
+		ii:        ii,
+		joinfield: joinfield,
+		schema:    schema,
+	}
+}
+
+func (ip *IndexJoinPlan) Open() (query.Scan, error) {
+	lhs, err := ip.p1.Open()
+	if err != nil {
+		return nil, err
+	}
+	ts, err := ip.p2.openTable()
+	if err != nil {
+		lhs.Close()
+		return nil, err
+	}
+	idx, err := ip.ii.Open()
+	if err != nil {
+		lhs.Close()
+		ts.Close()
+		return nil, err
+	}
+	s, err := index.NewIndexJoinScan(lhs, idx, ip.joinfield, ts)
+	if err != nil {
+		lhs.Close()
+		idx.Close()
+		ts.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// BlocksAccessed counts one pass over p1, one index search for each record of p1,
+// and one block for each output record
+func (ip *IndexJoinPlan) BlocksAccessed() int {
+	return addCost(addCost(ip.p1.BlocksAccessed(), mulCost(ip.p1.RecordsOutput(), ip.ii.BlocksAccessed())), ip.RecordsOutput())
+}
+
+func (ip *IndexJoinPlan) RecordsOutput() int {
+	return mulCost(ip.p1.RecordsOutput(), ip.ii.RecordsOutput())
+}
+
+func (ip *IndexJoinPlan) DistinctValues(field string) int {
+	if ip.p1.Schema().HasField(field) {
+		return ip.p1.DistinctValues(field)
+	}
+	return ip.p2.DistinctValues(field)
+}
+
+func (ip *IndexJoinPlan) Schema() *record.Schema {
+	return ip.schema
+}
@@ -0,0 +1,69 @@
+package plan
+
+import (
+	"simpledb/index"
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// IndexRangePlan reads the records of a table whose field lies between two inclusive bounds
+// through a B-tree index on the field. A nil bound leaves that side open.
+type IndexRangePlan struct {
+	p  *TablePlan
+	ii *metadata.IndexInfo
+	lo *query.Constant
+	hi *query.Constant
+}
+
+func NewIndexRangePlan(p *TablePlan, ii *metadata.IndexInfo, lo, hi *query.Constant) *IndexRangePlan {
+	return &IndexRangePlan{
+		p:  p,
+		ii: ii,
+		lo: lo,
+		hi: hi,
+	}
+}
+
+func (ip *IndexRangePlan) Open() (query.Scan, error) {
+	ts, err := ip.p.openTable()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := ip.ii.Open()
+	if err != nil {
+		ts.Close()
+		return nil, err
+	}
+	s, err := index.NewIndexRangeScan(ts, idx.(index.RangeIndex), ip.lo, ip.hi)
+	if err != nil {
+		idx.Close()
+		ts.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// BlocksAccessed counts the search of the index plus one block for each matching record
+func (ip *IndexRangePlan) BlocksAccessed() int {
+	return addCost(ip.ii.BlocksAccessed(), ip.RecordsOutput())
+}
+
+// RecordsOutput assumes each bound keeps a third of the records
+func (ip *IndexRangePlan) RecordsOutput() int {
+	n := ip.p.RecordsOutput()
+	for _, bound := range []*query.Constant{ip.lo, ip.hi} {
+		if bound != nil {
+			n /= rangeReductionFactor
+		}
+	}
+	return n
+}
+
+func (ip *IndexRangePlan) DistinctValues(field string) int {
+	return max(1, min(ip.p.DistinctValues(field), ip.RecordsOutput()))
+}
+
+func (ip *IndexRangePlan) Schema() *record.Schema {
+	return ip.p.Schema()
+}
@@ -0,0 +1,59 @@
+package plan
+
+import (
+	"simpledb/index"
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// IndexSelectPlan reads the records of a table having a value through an index on the field
+type IndexSelectPlan struct {
+	p   *TablePlan
+	ii  *metadata.IndexInfo
+	val query.Constant
+}
+
+func NewIndexSelectPlan(p *TablePlan, ii *metadata.IndexInfo, val query.Constant) *IndexSelectPlan {
+	return &IndexSelectPlan{
+		p:   p,
+		ii:  ii,
+		val: val,
+	}
+}
+
+func (ip *IndexSelectPlan) Open() (query.Scan, error) {
+	ts, err := ip.p.openTable()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := ip.ii.Open()
+	if err != nil {
+		ts.Close()
+		return nil, err
+	}
+	s, err := index.NewIndexSelectScan(ts, idx, ip.val)
+	if err != nil {
+		idx.Close()
+		ts.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// BlocksAccessed counts the search of the index plus one block for each matching record
+func (ip *IndexSelectPlan) BlocksAccessed() int {
+	return addCost(ip.ii.BlocksAccessed(), ip.RecordsOutput())
+}
+
+func (ip *IndexSelectPlan) RecordsOutput() int {
+	return ip.ii.RecordsOutput()
+}
+
+func (ip *IndexSelectPlan) DistinctValues(field string) int {
+	return ip.ii.DistinctValues(field)
+}
+
+func (ip *IndexSelectPlan) Schema() *record.Schema {
+	return ip.p.Schema()
+}
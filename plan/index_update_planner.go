@@ -0,0 +1,263 @@
+package plan
+
+import (
+	"simpledb/index"
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// IndexUpdatePlanner executes updates like BasicUpdatePlanner
+// and keeps the indexes of the modified table up to date
+type IndexUpdatePlanner struct {
+	*BasicUpdatePlanner
+	mdm *metadata.MetadataManager
+}
+
+func NewIndexUpdatePlanner(mdm *metadata.MetadataManager) *IndexUpdatePlanner {
+	return &IndexUpdatePlanner{
+		BasicUpdatePlanner: NewBasicUpdatePlanner(mdm),
+		mdm:                mdm,
+	}
+}
+
+func (up *IndexUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx record.Transaction) (int, error) {
+	p, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	err = checkInsert(p.Schema(), data)
+	if err != nil {
+		return 0, err
+	}
+	indexes, err := up.mdm.GetIndexInfo(data.TableName, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	ts, err := p.openTable()
+	if err != nil {
+		return 0, err
+	}
+	defer ts.Close()
+	err = ts.Insert()
+	if err != nil {
+		return 0, err
+	}
+	for i, field := range data.Fields {
+		err = ts.SetVal(field, data.Values[i])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// fields missing from the statement are indexed with their zero value
+	rid := ts.GetRID()
+	for field, ii := range indexes {
+		val, err := ts.GetVal(field)
+		if err != nil {
+			return 0, err
+		}
+		err = insertEntry(ii, val, rid)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 1, nil
+}
+
+func (up *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx record.Transaction) (int, error) {
+	tp, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	err = checkPredicate(tp.Schema(), data.Pred)
+	if err != nil {
+		return 0, err
+	}
+	idxs, err := up.openIndexes(data.TableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	defer closeIndexes(idxs)
+
+	s, err := NewSelectPlan(tp, data.Pred).Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	us := s.(query.UpdateScan)
+	count := 0
+	for {
+		ok, err := us.Next()
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			return count, nil
+		}
+		rid := us.GetRID()
+		for field, idx := range idxs {
+			val, err := us.GetVal(field)
+			if err != nil {
+				return count, err
+			}
+			err = idx.Delete(val, rid)
+			if err != nil {
+				return count, err
+			}
+		}
+		err = us.Delete()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func (up *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx record.Transaction) (int, error) {
+	tp, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	schema := tp.Schema()
+	err = checkModify(schema, data)
+	if err != nil {
+		return 0, err
+	}
+	indexes, err := up.mdm.GetIndexInfo(data.TableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	var idx index.Index
+	if ii, ok := indexes[data.FieldName]; ok {
+		idx, err = ii.Open()
+		if err != nil {
+			return 0, err
+		}
+		defer idx.Close()
+	}
+
+	s, err := NewSelectPlan(tp, data.Pred).Open()
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	us := s.(query.UpdateScan)
+	count := 0
+	for {
+		ok, err := us.Next()
+		if err != nil {
+			return count, err
+		}
+		if !ok {
+			return count, nil
+		}
+		newval, err := data.NewValue.Evaluate(us)
+		if err != nil {
+			return count, err
+		}
+		err = checkValue(schema, data.FieldName, newval)
+		if err != nil {
+			return count, err
+		}
+		oldval, err := us.GetVal(data.FieldName)
+		if err != nil {
+			return count, err
+		}
+		err = us.SetVal(data.FieldName, newval)
+		if err != nil {
+			return count, err
+		}
+		if idx != nil {
+			rid := us.GetRID()
+			err = idx.Delete(oldval, rid)
+			if err != nil {
+				return count, err
+			}
+			err = idx.Insert(newval, rid)
+			if err != nil {
+				return count, err
+			}
+		}
+		count++
+	}
+}
+
+// ExecuteCreateIndex registers the index and fills it with the records already in the table
+func (up *IndexUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx record.Transaction) (int, error) {
+	_, err := up.BasicUpdatePlanner.ExecuteCreateIndex(data, tx)
+	if err != nil {
+		return 0, err
+	}
+	indexes, err := up.mdm.GetIndexInfo(data.TableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	idx, err := indexes[data.FieldName].Open()
+	if err != nil {
+		return 0, err
+	}
+	defer idx.Close()
+
+	tp, err := NewTablePlan(tx, data.TableName, up.mdm)
+	if err != nil {
+		return 0, err
+	}
+	ts, err := tp.openTable()
+	if err != nil {
+		return 0, err
+	}
+	defer ts.Close()
+	for {
+		ok, err := ts.Next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, nil
+		}
+		val, err := ts.GetVal(data.FieldName)
+		if err != nil {
+			return 0, err
+		}
+		err = idx.Insert(val, ts.GetRID())
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (up *IndexUpdatePlanner) openIndexes(tblname string, tx record.Transaction) (map[string]index.Index, error) {
+	indexes, err := up.mdm.GetIndexInfo(tblname, tx)
+	if err != nil {
+		return nil, err
+	}
+	idxs := make(map[string]index.Index, len(indexes))
+	for field, ii := range indexes {
+		idxs[field], err = ii.Open()
+		if err != nil {
+			closeIndexes(idxs)
+			return nil, err
+		}
+	}
+	return idxs, nil
+}
+
+func closeIndexes(idxs map[string]index.Index) {
+	for _, idx := range idxs {
+		if idx != nil {
+			idx.Close()
+		}
+	}
+}
+
+func insertEntry(ii *metadata.IndexInfo, val query.Constant, rid record.RID) error {
+	idx, err := ii.Open()
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	return idx.Insert(val, rid)
+}
@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"fmt"
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexUpdatePlanner(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	mdm, err := metadata.NewMetadataManager(true, tx)
+	require.NoError(t, err)
+	planner := NewPlanner(NewHeuristicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+	basic := NewPlanner(NewBasicQueryPlanner(mdm), nil)
+
+	execute(t, planner, tx, "create table student (sid int, sname varchar(10), majorid int)")
+	execute(t, planner, tx, "create table dept (did int, dname varchar(10))")
+	for i := 0; i < 3; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into dept (did, dname) values (%d, 'd%d')", i, i))
+	}
+	for i := 0; i < 20; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into student (sid, sname, majorid) values (%d, 's%d', %d)", i, i, i%3))
+	}
+
+	// indexes created on a filled table index the existing records
+	execute(t, planner, tx, "create index majoridx on student (majorid)")
+	execute(t, planner, tx, "create index snameidx on student (sname)")
+	for i := 20; i < 30; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into student (sid, sname, majorid) values (%d, 's%d', %d)", i, i, i%3))
+	}
+	execute(t, planner, tx, "insert into student (sid, sname) values (99, 'nomajor')")
+
+	require.Equal(t, 1, execute(t, planner, tx, "update student set sname = 'renamed' where sid = 4"))
+	require.Equal(t, 10, execute(t, planner, tx, "update student set majorid = 5 where majorid = 2"))
+	require.Equal(t, 1, execute(t, planner, tx, "delete from student where sname = 's7'"))
+
+	testcases := []struct {
+		sql  string
+		rows int
+	}{
+		{"select sid from student where majorid = 0", 11}, // includes nomajor
+		{"select sid from student where majorid = 1", 9},
+		{"select sid from student where majorid = 2", 0},
+		{"select sid from student where majorid = 5", 10},
+		{"select sid from student where sname = 'renamed'", 1},
+		{"select sid from student where sname = 's4'", 0},
+		{"select sid from student where sname = 's7'", 0},
+		{"select sname, dname from dept, student where did = majorid", 20},
+		{"select sname, dname from dept, student where did = majorid and dname = 'd1'", 9},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.sql, func(t *testing.T) {
+			got := rows(t, planner, tx, tt.sql)
+			require.Len(t, got, tt.rows)
+			require.ElementsMatch(t, rows(t, basic, tx, tt.sql), got)
+		})
+	}
+
+	// the lookups go through the indexes
+	p, err := planner.CreateQueryPlan("select sid from student where majorid = 1", tx)
+	require.NoError(t, err)
+	require.IsType(t, &IndexSelectPlan{}, p.(*ProjectPlan).p.(*SelectPlan).p)
+
+	execute(t, planner, tx, "create index sididx on student (sid)")
+	p, err = planner.CreateQueryPlan("select sname from dept, student where did = sid and dname = 'd1'", tx)
+	require.NoError(t, err)
+	ij := p.(*ProjectPlan).p.(*SelectPlan).p.(*IndexJoinPlan)
+	require.Equal(t, "did", ij.joinfield)
+	require.Equal(t, "sid", ij.ii.FieldName())
+	require.Equal(t, []string{"'s1'"}, rows(t, planner, tx, "select sname from dept, student where did = sid and dname = 'd1'"))
+
+	// scanning student once is cheaper than looking up a third of it through the index
+	p, err = planner.CreateQueryPlan("select sname from dept, student where did = majorid and dname = 'd1'", tx)
+	require.NoError(t, err)
+	require.IsType(t, &HashJoinPlan{}, p.(*ProjectPlan).p.(*SelectPlan).p)
+
+	_, err = planner.ExecuteUpdate("create index badidx on student (nosuchfield)", tx)
+	require.ErrorIs(t, err, query.ErrFieldNotFound)
+	_, err = planner.ExecuteUpdate("create index badidx on nosuchtable (a)", tx)
+	require.ErrorIs(t, err, metadata.ErrTableNotFound)
+
+	// index names are unique across the tables, and a field has a single index
+	_, err = planner.ExecuteUpdate("create index sididx on dept (did)", tx)
+	require.ErrorIs(t, err, metadata.ErrIndexExists)
+	_, err = planner.ExecuteUpdate("create index sidbtree on student (sid) using btree", tx)
+	require.ErrorIs(t, err, metadata.ErrFieldIndexed)
+	ii, err := mdm.GetIndexInfo("student", tx)
+	require.NoError(t, err)
+	require.Equal(t, "sididx", ii["sid"].IndexName())
+	require.Empty(t, tx.Pinned())
+}
+
+func TestIndexUpdatePlanner_btree(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	mdm, err := metadata.NewMetadataManager(true, tx)
+	require.NoError(t, err)
+	planner := NewPlanner(NewHeuristicQueryPlanner(mdm), NewIndexUpdatePlanner(mdm))
+	basic := NewPlanner(NewBasicQueryPlanner(mdm), nil)
+
+	execute(t, planner, tx, "create table reading (ts int, sensor varchar(8), val int)")
+	execute(t, planner, tx, "create index tsidx on reading (ts) using btree")
+	for i := 0; i < 300; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into reading (ts, sensor, val) values (%d, 's%d', %d)", (i*7)%150, i%3, i))
+	}
+	require.Equal(t, 1, execute(t, planner, tx, "update reading set ts = 1000 where val = 10 and ts = 70"))
+	require.Equal(t, 1, execute(t, planner, tx, "delete from reading where val = 11"))
+
+	testcases := []struct {
+		sql  string
+		rows int
+		plan Plan
+	}{
+		{"select val from reading where ts = 14", 2, &IndexSelectPlan{}},
+		{"select val from reading where ts >= 140", 21, &IndexRangePlan{}},
+		{"select val from reading where ts > 140", 19, &IndexRangePlan{}},
+		{"select val from reading where 10 > ts", 20, &IndexRangePlan{}},
+		{"select val from reading where ts between 20 and 29 and sensor = 's1'", 6, &IndexRangePlan{}},
+		{"select val from reading where ts between 29 and 20", 0, &IndexRangePlan{}},
+		{"select val from reading where ts >= 999", 1, &IndexRangePlan{}},
+		{"select val from reading where ts <> 14", 297, &TablePlan{}},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.sql, func(t *testing.T) {
+			got := rows(t, planner, tx, tt.sql)
+			require.Len(t, got, tt.rows)
+			require.ElementsMatch(t, rows(t, basic, tx, tt.sql), got)
+
+			p, err := planner.CreateQueryPlan(tt.sql, tx)
+			require.NoError(t, err)
+			require.IsType(t, tt.plan, p.(*ProjectPlan).p.(*SelectPlan).p)
+		})
+	}
+
+	_, err = planner.ExecuteUpdate("create index badidx on reading (val) using bitmap", tx)
+	require.ErrorIs(t, err, metadata.ErrUnknownIndexType)
+	require.Empty(t, tx.Pinned())
+}
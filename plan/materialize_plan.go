@@ -0,0 +1,107 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// MaterializePlan saves the output of the underlying plan into a temporary table
+// when it is opened, and reads the table
+type MaterializePlan struct {
+	tx record.Transaction
+	p  Plan
+}
+
+func NewMaterializePlan(tx record.Transaction, p Plan) *MaterializePlan {
+	return &MaterializePlan{
+		tx: tx,
+		p:  p,
+	}
+}
+
+// Open returns a scan over the temporary table, which removes the table once it is closed
+func (mp *MaterializePlan) Open() (query.Scan, error) {
+	tt, err := mp.materialize()
+	if err != nil {
+		return nil, err
+	}
+	return tt.OpenOwned()
+}
+
+// materialize copies the output of the underlying plan into a new temporary table
+func (mp *MaterializePlan) materialize() (*query.TempTable, error) {
+	src, err := mp.p.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tt := query.NewTempTable(mp.tx, mp.p.Schema())
+	err = copyAll(src, tt)
+	if err != nil {
+		_ = tt.Remove()
+		return nil, err
+	}
+	return tt, nil
+}
+
+// copyAll copies the records of src into the temporary table
+func copyAll(src query.Scan, tt *query.TempTable) error {
+	dest, err := tt.Open()
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	fields := tt.Layout().Schema().Fields()
+	for {
+		ok, err := src.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		err = query.CopyRecord(src, dest, fields)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// BlocksAccessed counts the blocks of the temporary table, not the one-time cost of filling it
+func (mp *MaterializePlan) BlocksAccessed() int {
+	layout := record.NewLayout(mp.p.Schema())
+	rpb := max(mp.tx.BlockSize()/layout.SlotSize(), 1)
+	return ceilDiv(mp.p.RecordsOutput(), rpb)
+}
+
+func (mp *MaterializePlan) RecordsOutput() int {
+	return mp.p.RecordsOutput()
+}
+
+func (mp *MaterializePlan) DistinctValues(field string) int {
+	return mp.p.DistinctValues(field)
+}
+
+func (mp *MaterializePlan) Schema() *record.Schema {
+	return mp.p.Schema()
+}
+
+// tempsScan removes the temporary tables read by the scan once it is closed
+type tempsScan struct {
+	query.Scan
+	temps []*query.TempTable
+}
+
+func (s *tempsScan) Close() {
+	s.Scan.Close()
+	removeTemps(s.temps)
+}
+
+// removeTemps removes temporary tables which are no longer read.
+// A table which cannot be removed is left for the next startup to remove.
+func removeTemps(temps []*query.TempTable) {
+	for _, tt := range temps {
+		_ = tt.Remove()
+	}
+}
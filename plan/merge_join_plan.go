@@ -0,0 +1,81 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// MergeJoinPlan joins p1 and p2 on fldname1 = fldname2 by merging both inputs sorted on their join field,
+// reading each of them once. An input which is a SortPlan on its join field is used as it is;
+// any other input is sorted first.
+type MergeJoinPlan struct {
+	p1       *SortPlan
+	p2       *SortPlan
+	fldname1 string
+	fldname2 string
+	// sorts are the inputs this plan sorts itself
+	sorts  []*SortPlan
+	schema *record.Schema
+}
+
+func NewMergeJoinPlan(tx record.Transaction, p1, p2 Plan, fldname1, fldname2 string) *MergeJoinPlan {
+	schema := record.NewSchema()
+	schema.AddAll(p1.Schema())
+	schema.AddAll(p2.Schema())
+	mp := &MergeJoinPlan{
+		fldname1: fldname1,
+		fldname2: fldname2,
+		schema:   schema,
+	}
+	mp.p1 = mp.sortedOn(tx, p1, fldname1)
+	mp.p2 = mp.sortedOn(tx, p2, fldname2)
+	return mp
+}
+
+// sortedOn returns the plan sorted on the field
+func (mp *MergeJoinPlan) sortedOn(tx record.Transaction, p Plan, field string) *SortPlan {
+	if sp, ok := p.(*SortPlan); ok && len(sp.fields) > 0 && sp.fields[0] == field {
+		return sp
+	}
+	sp := NewSortPlan(tx, p, []string{field})
+	mp.sorts = append(mp.sorts, sp)
+	return sp
+}
+
+func (mp *MergeJoinPlan) Open() (query.Scan, error) {
+	s1, err := mp.p1.Open()
+	if err != nil {
+		return nil, err
+	}
+	s2, err := mp.p2.Open()
+	if err != nil {
+		s1.Close()
+		return nil, err
+	}
+	return query.NewMergeJoinScan(s1, s2.(*query.SortScan), mp.fldname1, mp.fldname2)
+}
+
+// BlocksAccessed counts one pass over each sorted input. Unlike SortPlan, it includes the cost
+// of the sorts it adds: a pass over the input plus writing the runs which the merge reads back.
+func (mp *MergeJoinPlan) BlocksAccessed() int {
+	cost := addCost(mp.p1.BlocksAccessed(), mp.p2.BlocksAccessed())
+	for _, sp := range mp.sorts {
+		cost = addCost(cost, addCost(sp.p.BlocksAccessed(), sp.BlocksAccessed()))
+	}
+	return cost
+}
+
+func (mp *MergeJoinPlan) RecordsOutput() int {
+	return joinRecordsOutput(mp.p1, mp.p2, mp.fldname1, mp.fldname2)
+}
+
+func (mp *MergeJoinPlan) DistinctValues(field string) int {
+	if mp.p1.Schema().HasField(field) {
+		return mp.p1.DistinctValues(field)
+	}
+	return mp.p2.DistinctValues(field)
+}
+
+func (mp *MergeJoinPlan) Schema() *record.Schema {
+	return mp.schema
+}
@@ -0,0 +1,119 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeJoinPlan(t *testing.T) {
+	for _, available := range []int{3, 64} {
+		t.Run(fmt.Sprintf("available %d", available), func(t *testing.T) {
+			tx := recordtest.NewTransaction(200)
+			r, s := newJoinTables(t, tx)
+			tx.SetAvailableBuffs(available)
+
+			pred := query.NewPredicate(query.NewTerm(query.NewFieldExpression("a"), query.NewFieldExpression("b")))
+			expect := collectPlan(t, NewSelectPlan(NewProductPlan(r, s), pred))
+
+			// each value of a matches a group of 3 records of s, whichever side is outer
+			require.ElementsMatch(t, expect, collectPlan(t, NewMergeJoinPlan(tx, r, s, "a", "b")))
+			require.Len(t, collectPlan(t, NewMergeJoinPlan(tx, s, r, "b", "a")), 150)
+			require.Empty(t, tx.Pinned())
+		})
+	}
+}
+
+func TestMergeJoinPlan_duplicates(t *testing.T) {
+	tx := recordtest.NewTransaction(200)
+	tx.SetAvailableBuffs(4)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table l (k int, v int)")
+	execute(t, planner, tx, "create table m (j int, w int)")
+	for i := 0; i < 40; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into l (k, v) values (%d, %d)", (i*7)%10, i))
+		execute(t, planner, tx, fmt.Sprintf("insert into m (j, w) values (%d, %d)", (i*3)%8, i))
+	}
+	mdm, err := metadata.NewMetadataManager(false, tx)
+	require.NoError(t, err)
+	lp, err := NewTablePlan(tx, "l", mdm)
+	require.NoError(t, err)
+	mp, err := NewTablePlan(tx, "m", mdm)
+	require.NoError(t, err)
+
+	// keys 0 to 7 appear 4 times in l and 5 times in m
+	pred := query.NewPredicate(query.NewTerm(query.NewFieldExpression("k"), query.NewFieldExpression("j")))
+	expect := collectPlan(t, NewSelectPlan(NewProductPlan(lp, mp), pred))
+	require.Len(t, expect, 160)
+
+	p := NewMergeJoinPlan(tx, lp, mp, "k", "j")
+	require.ElementsMatch(t, expect, collectPlan(t, p))
+
+	s, err := p.Open()
+	require.NoError(t, err)
+	for range 2 {
+		require.NoError(t, s.BeforeFirst())
+		n := 0
+		for {
+			ok, err := s.Next()
+			require.NoError(t, err)
+			if !ok {
+				break
+			}
+			n++
+		}
+		require.Equal(t, 160, n)
+	}
+	s.Close()
+	require.Empty(t, tx.Pinned())
+}
+
+func TestMergeJoinPlan_estimates(t *testing.T) {
+	tx := recordtest.NewTransaction(200)
+	r, s := newJoinTables(t, tx)
+
+	// sorting each input costs a pass over it plus writing its runs
+	p := NewMergeJoinPlan(tx, r, s, "a", "b")
+	require.Equal(t, 3*(r.BlocksAccessed()+s.BlocksAccessed()), p.BlocksAccessed())
+	require.Equal(t, 150, p.RecordsOutput())
+	require.Equal(t, 60, p.DistinctValues("a"))
+
+	// an input already sorted on its join field is only read
+	sorted := NewSortPlan(tx, s, []string{"b", "y"})
+	p = NewMergeJoinPlan(tx, r, sorted, "a", "b")
+	require.Same(t, sorted, p.p2)
+	require.Equal(t, 3*r.BlocksAccessed()+s.BlocksAccessed(), p.BlocksAccessed())
+}
+
+// estimates is a plan with fixed estimates
+type estimates struct {
+	Plan
+	records  int
+	distinct int
+}
+
+func (e estimates) RecordsOutput() int              { return e.records }
+func (e estimates) DistinctValues(field string) int { return e.distinct }
+
+func TestJoinRecordsOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		p1, p2 estimates
+		want   int
+	}{
+		{name: "small", p1: estimates{records: 100, distinct: 10}, p2: estimates{records: 20, distinct: 20}, want: 100},
+		{name: "product overflows", p1: estimates{records: 1 << 40, distinct: 1 << 30}, p2: estimates{records: 1 << 30, distinct: 1}, want: 1 << 40},
+		{name: "output overflows", p1: estimates{records: 1 << 40, distinct: 2}, p2: estimates{records: 1 << 40, distinct: 1}, want: math.MaxInt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, joinRecordsOutput(tt.p1, tt.p2, "a", "b"))
+			require.Equal(t, tt.want, joinRecordsOutput(tt.p2, tt.p1, "b", "a"))
+		})
+	}
+}
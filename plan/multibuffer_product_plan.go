@@ -0,0 +1,87 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// MultibufferProductPlan combines every record of lhs with every record of rhs.
+// rhs is materialized into a temporary table, which is read in chunks of as many blocks
+// as the buffer manager can spare; lhs is scanned once per chunk.
+// The temporary table is removed once the scan is closed.
+type MultibufferProductPlan struct {
+	tx        record.Transaction
+	available int
+	lhs       Plan
+	rhs       Plan
+	schema    *record.Schema
+}
+
+func NewMultibufferProductPlan(tx record.Transaction, lhs, rhs Plan) *MultibufferProductPlan {
+	schema := record.NewSchema()
+	schema.AddAll(lhs.Schema())
+	schema.AddAll(rhs.Schema())
+	return &MultibufferProductPlan{
+		tx:        tx,
+		available: tx.AvailableBuffs(),
+		lhs:       lhs,
+		rhs:       rhs,
+		schema:    schema,
+	}
+}
+
+// Open materializes rhs, then sizes the chunks from the buffers available at that time
+func (mp *MultibufferProductPlan) Open() (query.Scan, error) {
+	tt, err := NewMaterializePlan(mp.tx, mp.rhs).materialize()
+	if err != nil {
+		return nil, err
+	}
+	s, err := mp.open(tt)
+	if err != nil {
+		_ = tt.Remove()
+		return nil, err
+	}
+	return &tempsScan{Scan: s, temps: []*query.TempTable{tt}}, nil
+}
+
+func (mp *MultibufferProductPlan) open(tt *query.TempTable) (query.Scan, error) {
+	size, err := mp.tx.Size(tt.TableName() + ".tbl")
+	if err != nil {
+		return nil, err
+	}
+	chunksize := BestFactor(mp.tx.AvailableBuffs(), size)
+
+	lhs, err := mp.lhs.Open()
+	if err != nil {
+		return nil, err
+	}
+	s, err := query.NewMultibufferProductScan(mp.tx, lhs, tt.TableName(), tt.Layout(), chunksize)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// BlocksAccessed counts one pass over rhs plus one pass over lhs for each chunk.
+// The number of chunks depends on the buffers available when the plan is created.
+func (mp *MultibufferProductPlan) BlocksAccessed() int {
+	size := NewMaterializePlan(mp.tx, mp.rhs).BlocksAccessed()
+	chunksize := BestFactor(mp.available, size)
+	numchunks := ceilDiv(size, chunksize)
+	return addCost(mp.rhs.BlocksAccessed(), mulCost(mp.lhs.BlocksAccessed(), max(numchunks, 1)))
+}
+
+func (mp *MultibufferProductPlan) RecordsOutput() int {
+	return mulCost(mp.lhs.RecordsOutput(), mp.rhs.RecordsOutput())
+}
+
+func (mp *MultibufferProductPlan) DistinctValues(field string) int {
+	if mp.lhs.Schema().HasField(field) {
+		return mp.lhs.DistinctValues(field)
+	}
+	return mp.rhs.DistinctValues(field)
+}
+
+func (mp *MultibufferProductPlan) Schema() *record.Schema {
+	return mp.schema
+}
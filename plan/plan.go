@@ -0,0 +1,97 @@
+package plan
+
+import (
+	"math"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// Plan is a node of a query tree. It estimates the cost of its query
+// without executing it, and opens a Scan over its output.
+type Plan interface {
+	Open() (query.Scan, error)
+	// BlocksAccessed estimates the number of block accesses needed to read the output
+	BlocksAccessed() int
+	// RecordsOutput estimates the number of output records
+	RecordsOutput() int
+	// DistinctValues estimates the number of distinct values of the field in the output
+	DistinctValues(field string) int
+	Schema() *record.Schema
+}
+
+// rangeReductionFactor is the share of the records assumed to satisfy a range comparison
+const rangeReductionFactor = 3
+
+// reductionFactor estimates by how much the predicate shrinks the output of the plan
+func reductionFactor(pred *query.Predicate, p Plan) int {
+	factor := 1
+	for _, t := range pred.Terms() {
+		factor = mulCost(factor, termReductionFactor(t, p))
+	}
+	return factor
+}
+
+// joinRecordsOutput estimates the output of the equijoin of p1 and p2 on fields f1 and f2.
+// It assumes that every value of the join field with fewer values matches a value of the other one.
+func joinRecordsOutput(p1, p2 Plan, f1, f2 string) int {
+	dv := max(p1.DistinctValues(f1), p2.DistinctValues(f2), 1)
+	r1, r2 := p1.RecordsOutput(), p2.RecordsOutput()
+	if r1 < r2 {
+		r1, r2 = r2, r1
+	}
+	if r := mulCost(r1, r2); r < math.MaxInt {
+		return r / dv
+	}
+	// dividing first keeps the estimate of a huge join from saturating needlessly
+	return mulCost(r1/dv, r2)
+}
+
+// mulCost multiplies two estimates, saturating at math.MaxInt instead of wrapping around,
+// so that a huge cost never looks cheap
+func mulCost(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+// addCost adds two estimates, saturating at math.MaxInt
+func addCost(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// ceilDiv divides the estimate by n, rounding up, without overflowing on a saturated estimate
+func ceilDiv(a, n int) int {
+	q := a / n
+	if a%n != 0 {
+		q++
+	}
+	return q
+}
+
+func termReductionFactor(t *query.Term, p Plan) int {
+	lhs, rhs := t.LHS(), t.RHS()
+	if t.Operator() != query.Operator_EQ && (lhs.IsFieldName() || rhs.IsFieldName()) {
+		if t.Operator() == query.Operator_NE {
+			return 1
+		}
+		return rangeReductionFactor
+	}
+	switch {
+	case lhs.IsFieldName() && rhs.IsFieldName():
+		return max(p.DistinctValues(lhs.AsFieldName()), p.DistinctValues(rhs.AsFieldName()))
+	case lhs.IsFieldName():
+		return p.DistinctValues(lhs.AsFieldName())
+	case rhs.IsFieldName():
+		return p.DistinctValues(rhs.AsFieldName())
+	default:
+		// comparing two constants needs no record
+		if ok, _ := t.IsSatisfied(nil); ok {
+			return 1
+		}
+		return math.MaxInt
+	}
+}
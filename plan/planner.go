@@ -0,0 +1,169 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"simpledb/parse"
+	"simpledb/query"
+	"simpledb/record"
+	"slices"
+)
+
+var (
+	ErrNotQuery     = errors.New("statement is not a query")
+	ErrNotUpdate    = errors.New("statement is not an update")
+	ErrTypeMismatch = errors.New("type mismatch")
+	ErrValueTooLong = errors.New("value too long")
+	ErrNotGrouped   = errors.New("field is neither grouped nor aggregated")
+)
+
+// QueryPlanner turns a parsed query into a plan
+type QueryPlanner interface {
+	CreatePlan(data *parse.QueryData, tx record.Transaction) (Plan, error)
+}
+
+// UpdatePlanner executes the statements which modify the database.
+// Each method returns the number of affected records.
+type UpdatePlanner interface {
+	ExecuteInsert(data *parse.InsertData, tx record.Transaction) (int, error)
+	ExecuteDelete(data *parse.DeleteData, tx record.Transaction) (int, error)
+	ExecuteModify(data *parse.ModifyData, tx record.Transaction) (int, error)
+	ExecuteCreateTable(data *parse.CreateTableData, tx record.Transaction) (int, error)
+	ExecuteCreateView(data *parse.CreateViewData, tx record.Transaction) (int, error)
+	ExecuteCreateIndex(data *parse.CreateIndexData, tx record.Transaction) (int, error)
+}
+
+// Planner parses SQL statements and hands them to the query or the update planner
+type Planner struct {
+	qp QueryPlanner
+	up UpdatePlanner
+}
+
+func NewPlanner(qp QueryPlanner, up UpdatePlanner) *Planner {
+	return &Planner{
+		qp: qp,
+		up: up,
+	}
+}
+
+// CreateQueryPlan returns the plan of a select statement
+func (p *Planner) CreateQueryPlan(sql string, tx record.Transaction) (Plan, error) {
+	stmt, err := parse.Parse(sql)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := stmt.(*parse.QueryData)
+	if !ok {
+		return nil, ErrNotQuery
+	}
+	return p.qp.CreatePlan(data, tx)
+}
+
+// ExecuteQuery plans a select statement and opens a scan over its output.
+// The caller must close the scan.
+func (p *Planner) ExecuteQuery(sql string, tx record.Transaction) (query.Scan, error) {
+	plan, err := p.CreateQueryPlan(sql, tx)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Open()
+}
+
+// ExecuteUpdate executes an insert, delete, update or create statement
+// and returns the number of affected records
+func (p *Planner) ExecuteUpdate(sql string, tx record.Transaction) (int, error) {
+	stmt, err := parse.Parse(sql)
+	if err != nil {
+		return 0, err
+	}
+	switch data := stmt.(type) {
+	case *parse.InsertData:
+		return p.up.ExecuteInsert(data, tx)
+	case *parse.DeleteData:
+		return p.up.ExecuteDelete(data, tx)
+	case *parse.ModifyData:
+		return p.up.ExecuteModify(data, tx)
+	case *parse.CreateTableData:
+		return p.up.ExecuteCreateTable(data, tx)
+	case *parse.CreateViewData:
+		return p.up.ExecuteCreateView(data, tx)
+	case *parse.CreateIndexData:
+		return p.up.ExecuteCreateIndex(data, tx)
+	default:
+		return 0, ErrNotUpdate
+	}
+}
+
+// checkFields verifies that every field of the list exists in the schema
+func checkFields(schema *record.Schema, fields []string) error {
+	for _, field := range fields {
+		if !schema.HasField(field) {
+			return fmt.Errorf("%w: %s", query.ErrFieldNotFound, field)
+		}
+	}
+	return nil
+}
+
+// checkPredicate verifies that every field the predicate mentions exists in the schema
+func checkPredicate(schema *record.Schema, pred *query.Predicate) error {
+	for _, t := range pred.Terms() {
+		for _, e := range []*query.Expression{t.LHS(), t.RHS()} {
+			if !e.AppliesTo(schema) {
+				return fmt.Errorf("%w: %s", query.ErrFieldNotFound, e.AsFieldName())
+			}
+		}
+	}
+	return nil
+}
+
+// checkGrouping verifies that the group fields and the aggregated fields exist in the schema,
+// that SUM and AVG aggregate integers, and that every output field is grouped or aggregated
+func checkGrouping(schema *record.Schema, data *parse.QueryData) error {
+	err := checkFields(schema, data.GroupFields)
+	if err != nil {
+		return err
+	}
+	aggregated := make(map[string]struct{}, len(data.Aggregates))
+	for _, fn := range data.Aggregates {
+		field := fn.SourceField()
+		if !schema.HasField(field) {
+			return fmt.Errorf("%w: %s", query.ErrFieldNotFound, field)
+		}
+		agg := fn.Aggregate()
+		if (agg == query.Aggregate_SUM || agg == query.Aggregate_AVG) && schema.Type(field) != record.FieldType_INTEGER {
+			return fmt.Errorf("%w: %s needs an int field, %s is a varchar field", ErrTypeMismatch, agg, field)
+		}
+		aggregated[fn.FieldName()] = struct{}{}
+	}
+	for _, field := range data.Fields {
+		if _, found := aggregated[field]; found || slices.Contains(data.GroupFields, field) {
+			continue
+		}
+		if !schema.HasField(field) {
+			return fmt.Errorf("%w: %s", query.ErrFieldNotFound, field)
+		}
+		return fmt.Errorf("%w: %s", ErrNotGrouped, field)
+	}
+	return nil
+}
+
+// checkValue verifies that the value can be stored into the field
+func checkValue(schema *record.Schema, field string, val query.Constant) error {
+	if !schema.HasField(field) {
+		return fmt.Errorf("%w: %s", query.ErrFieldNotFound, field)
+	}
+	switch schema.Type(field) {
+	case record.FieldType_INTEGER:
+		if !val.IsInt() {
+			return fmt.Errorf("%w: %s is an int field, got %s", ErrTypeMismatch, field, val)
+		}
+	case record.FieldType_VARCHAR:
+		if val.IsInt() {
+			return fmt.Errorf("%w: %s is a varchar field, got %s", ErrTypeMismatch, field, val)
+		}
+		if len(val.AsString()) > schema.Length(field) {
+			return fmt.Errorf("%w: %s holds at most %d characters", ErrValueTooLong, field, schema.Length(field))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/query"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newPlanner(t *testing.T, tx *recordtest.Transaction) (*Planner, *metadata.MetadataManager) {
+	mdm, err := metadata.NewMetadataManager(true, tx)
+	require.NoError(t, err)
+	return NewPlanner(NewBasicQueryPlanner(mdm), NewBasicUpdatePlanner(mdm)), mdm
+}
+
+func execute(t *testing.T, planner *Planner, tx *recordtest.Transaction, sql string) int {
+	t.Helper()
+	n, err := planner.ExecuteUpdate(sql, tx)
+	require.NoError(t, err, sql)
+	return n
+}
+
+func rows(t *testing.T, planner *Planner, tx *recordtest.Transaction, sql string) []string {
+	t.Helper()
+	p, err := planner.CreateQueryPlan(sql, tx)
+	require.NoError(t, err)
+	s, err := p.Open()
+	require.NoError(t, err)
+	defer s.Close()
+
+	got := []string{}
+	for {
+		ok, err := s.Next()
+		require.NoError(t, err)
+		if !ok {
+			return got
+		}
+		vals := ""
+		for i, field := range p.Schema().Fields() {
+			val, err := s.GetVal(field)
+			require.NoError(t, err)
+			if i > 0 {
+				vals += " "
+			}
+			vals += val.String()
+		}
+		got = append(got, vals)
+	}
+}
+
+func TestPlanner(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	planner, _ := newPlanner(t, tx)
+
+	execute(t, planner, tx, "create table student (sid int, sname varchar(10), majorid int)")
+	execute(t, planner, tx, "create table dept (did int, dname varchar(10))")
+	for i := 0; i < 6; i++ {
+		n := execute(t, planner, tx, fmt.Sprintf("insert into student (sid, sname, majorid) values (%d, 's%d', %d)", i, i, 10+i%2))
+		require.Equal(t, 1, n)
+	}
+	execute(t, planner, tx, "insert into dept (did, dname) values (10, 'math')")
+	execute(t, planner, tx, "insert into dept (dname, did) values ('compsci', 11)")
+
+	require.Equal(t, []string{"'s1' 'compsci'", "'s3' 'compsci'", "'s5' 'compsci'"},
+		rows(t, planner, tx, "select sname, dname from student, dept where majorid = did and did = 11"))
+
+	// views are expanded into their definition
+	execute(t, planner, tx, "create view mathstudents as select sid, sname from student, dept where majorid = did and dname = 'math'")
+	require.Equal(t, []string{"'s2'"}, rows(t, planner, tx, "select sname from mathstudents where sid = 2"))
+
+	n := execute(t, planner, tx, "update student set majorid = 10 where sname = 's5'")
+	require.Equal(t, 1, n)
+	n = execute(t, planner, tx, "update student set sname = 'x' where majorid = 11")
+	require.Equal(t, 2, n)
+	n = execute(t, planner, tx, "delete from student where majorid = 10")
+	require.Equal(t, 4, n)
+	require.Equal(t, []string{"1 'x'", "3 'x'"}, rows(t, planner, tx, "select sid, sname from student"))
+
+	s, err := planner.ExecuteQuery("select did from dept where dname = 'math'", tx)
+	require.NoError(t, err)
+	ok, err := s.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	did, err := s.GetInt("did")
+	require.NoError(t, err)
+	require.Equal(t, int32(10), did)
+	s.Close()
+
+	require.Empty(t, tx.Pinned())
+}
+
+func TestPlanner_errors(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table t (a int, b varchar(3))")
+	execute(t, planner, tx, "create view v as select a from t")
+
+	testcases := []struct {
+		sql   string
+		query bool
+		err   error
+	}{
+		{"select a from nosuchtable", true, metadata.ErrTableNotFound},
+		{"select c from t", true, query.ErrFieldNotFound},
+		{"select a from t where c = 1", true, query.ErrFieldNotFound},
+		{"select a frm t", true, parse.ErrSyntax},
+		{"delete from t", true, ErrNotQuery},
+		{"insert into t (a) values ('x')", false, ErrTypeMismatch},
+		{"insert into t (b) values (1)", false, ErrTypeMismatch},
+		{"insert into t (b) values ('abcd')", false, ErrValueTooLong},
+		{"insert into t (c) values (1)", false, query.ErrFieldNotFound},
+		{"update t set c = 1", false, query.ErrFieldNotFound},
+		{"update t set a = 'x'", false, ErrTypeMismatch},
+		{"delete from t where c = 1", false, query.ErrFieldNotFound},
+		{"select a from t", false, ErrNotUpdate},
+		{"select a, count(b) from t", true, ErrNotGrouped},
+		{"select sum(b) from t", true, ErrTypeMismatch},
+		{"select max(c) from t", true, query.ErrFieldNotFound},
+		{"select a from t group by c", true, query.ErrFieldNotFound},
+		{"select a from t order by c", true, query.ErrFieldNotFound},
+		{"select count(a) from t order by a", true, query.ErrFieldNotFound},
+		{"create table t (c varchar(3))", false, metadata.ErrTableExists},
+		{"create table v (c int)", false, metadata.ErrTableExists},
+		{"create view t as select b from t", false, metadata.ErrTableExists},
+		{"create view v as select b from t", false, metadata.ErrTableExists},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.sql, func(t *testing.T) {
+			var err error
+			if tt.query {
+				_, err = planner.CreateQueryPlan(tt.sql, tx)
+			} else {
+				_, err = planner.ExecuteUpdate(tt.sql, tx)
+			}
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+	require.Empty(t, tx.Pinned())
+}
+
+func TestPlan_estimates(t *testing.T) {
+	tx := recordtest.NewTransaction(400)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table t (a int, b int)")
+	execute(t, planner, tx, "create table u (c int)")
+	for i := 0; i < 100; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into t (a, b) values (%d, %d)", i%10, i%4))
+	}
+	for i := 0; i < 20; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into u (c) values (%d)", i))
+	}
+
+	// reopening the catalog refreshes the statistics
+	mdm, err := metadata.NewMetadataManager(false, tx)
+	require.NoError(t, err)
+
+	// 12-byte slots, 33 records per block
+	tp, err := NewTablePlan(tx, "t", mdm)
+	require.NoError(t, err)
+	require.Equal(t, 4, tp.BlocksAccessed())
+	require.Equal(t, 100, tp.RecordsOutput())
+	require.Equal(t, 10, tp.DistinctValues("a"))
+
+	up, err := NewTablePlan(tx, "u", mdm)
+	require.NoError(t, err)
+
+	a := query.NewFieldExpression("a")
+	sp := NewSelectPlan(tp, query.NewPredicate(query.NewTerm(a, query.NewConstantExpression(query.NewIntConstant(3)))))
+	require.Equal(t, 4, sp.BlocksAccessed())
+	require.Equal(t, 10, sp.RecordsOutput())
+	require.Equal(t, 1, sp.DistinctValues("a"))
+	require.Equal(t, 4, sp.DistinctValues("b"))
+
+	pp := NewProductPlan(tp, up)
+	require.Equal(t, 4+100*1, pp.BlocksAccessed())
+	require.Equal(t, 2000, pp.RecordsOutput())
+	require.Equal(t, 20, pp.DistinctValues("c"))
+
+	join := NewSelectPlan(pp, query.NewPredicate(query.NewTerm(a, query.NewFieldExpression("c"))))
+	require.Equal(t, 100, join.RecordsOutput())
+	require.Equal(t, 10, join.DistinctValues("c"))
+
+	proj := NewProjectPlan(join, []string{"b"})
+	require.Equal(t, []string{"b"}, proj.Schema().Fields())
+	require.Equal(t, 100, proj.RecordsOutput())
+	require.Equal(t, join.BlocksAccessed(), proj.BlocksAccessed())
+
+	// the estimates of a product too large for an int saturate instead of wrapping around
+	huge := Plan(pp)
+	for range 3 {
+		huge = NewProductPlan(huge, huge)
+	}
+	require.Equal(t, math.MaxInt, huge.RecordsOutput())
+	require.Equal(t, math.MaxInt, huge.BlocksAccessed())
+}
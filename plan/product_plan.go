@@ -0,0 +1,63 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// ProductPlan combines every record of p1 with every record of p2
+type ProductPlan struct {
+	p1     Plan
+	p2     Plan
+	schema *record.Schema
+}
+
+func NewProductPlan(p1, p2 Plan) *ProductPlan {
+	schema := record.NewSchema()
+	schema.AddAll(p1.Schema())
+	schema.AddAll(p2.Schema())
+	return &ProductPlan{
+		p1:     p1,
+		p2:     p2,
+		schema: schema,
+	}
+}
+
+func (pp *ProductPlan) Open() (query.Scan, error) {
+	s1, err := pp.p1.Open()
+	if err != nil {
+		return nil, err
+	}
+	s2, err := pp.p2.Open()
+	if err != nil {
+		s1.Close()
+		return nil, err
+	}
+	s, err := query.NewProductScan(s1, s2)
+	if err != nil {
+		s1.Close()
+		s2.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// BlocksAccessed counts one pass over p1 plus one pass over p2 for each record of p1
+func (pp *ProductPlan) BlocksAccessed() int {
+	return addCost(pp.p1.BlocksAccessed(), mulCost(pp.p1.RecordsOutput(), pp.p2.BlocksAccessed()))
+}
+
+func (pp *ProductPlan) RecordsOutput() int {
+	return mulCost(pp.p1.RecordsOutput(), pp.p2.RecordsOutput())
+}
+
+func (pp *ProductPlan) DistinctValues(field string) int {
+	if pp.p1.Schema().HasField(field) {
+		return pp.p1.DistinctValues(field)
+	}
+	return pp.p2.DistinctValues(field)
+}
+
+func (pp *ProductPlan) Schema() *record.Schema {
+	return pp.schema
+}
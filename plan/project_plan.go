@@ -0,0 +1,47 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// ProjectPlan keeps some of the fields of the underlying plan
+type ProjectPlan struct {
+	p      Plan
+	schema *record.Schema
+}
+
+func NewProjectPlan(p Plan, fields []string) *ProjectPlan {
+	schema := record.NewSchema()
+	for _, field := range fields {
+		schema.Add(field, p.Schema())
+	}
+	return &ProjectPlan{
+		p:      p,
+		schema: schema,
+	}
+}
+
+func (pp *ProjectPlan) Open() (query.Scan, error) {
+	s, err := pp.p.Open()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewProjectScan(s, pp.schema.Fields()), nil
+}
+
+func (pp *ProjectPlan) BlocksAccessed() int {
+	return pp.p.BlocksAccessed()
+}
+
+func (pp *ProjectPlan) RecordsOutput() int {
+	return pp.p.RecordsOutput()
+}
+
+func (pp *ProjectPlan) DistinctValues(field string) int {
+	return pp.p.DistinctValues(field)
+}
+
+func (pp *ProjectPlan) Schema() *record.Schema {
+	return pp.schema
+}
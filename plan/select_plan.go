@@ -0,0 +1,49 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// SelectPlan keeps the records of the underlying plan which satisfy the predicate
+type SelectPlan struct {
+	p    Plan
+	pred *query.Predicate
+}
+
+func NewSelectPlan(p Plan, pred *query.Predicate) *SelectPlan {
+	return &SelectPlan{
+		p:    p,
+		pred: pred,
+	}
+}
+
+func (sp *SelectPlan) Open() (query.Scan, error) {
+	s, err := sp.p.Open()
+	if err != nil {
+		return nil, err
+	}
+	return query.NewSelectScan(s, sp.pred), nil
+}
+
+func (sp *SelectPlan) BlocksAccessed() int {
+	return sp.p.BlocksAccessed()
+}
+
+func (sp *SelectPlan) RecordsOutput() int {
+	return sp.p.RecordsOutput() / reductionFactor(sp.pred, sp.p)
+}
+
+func (sp *SelectPlan) DistinctValues(field string) int {
+	if _, ok := sp.pred.EquatesWithConstant(field); ok {
+		return 1
+	}
+	if other, ok := sp.pred.EquatesWithField(field); ok {
+		return min(sp.p.DistinctValues(field), sp.p.DistinctValues(other))
+	}
+	return sp.p.DistinctValues(field)
+}
+
+func (sp *SelectPlan) Schema() *record.Schema {
+	return sp.p.Schema()
+}
@@ -0,0 +1,306 @@
+package plan
+
+import (
+	"simpledb/query"
+	"simpledb/record"
+	"sort"
+)
+
+// SortPlan sorts the output of the underlying plan on the fields with an external merge sort.
+// Opening it splits the records into sorted runs, each as large as the usable buffers,
+// and merges the runs until few enough are left to be merged by the scan itself.
+// The buffer budget is taken from the buffers available when the plan is opened.
+// Every run is a temporary table, removed once it has been merged or the scan is closed.
+type SortPlan struct {
+	tx     record.Transaction
+	p      Plan
+	fields []string
+	schema *record.Schema
+}
+
+func NewSortPlan(tx record.Transaction, p Plan, fields []string) *SortPlan {
+	return &SortPlan{
+		tx:     tx,
+		p:      p,
+		fields: fields,
+		schema: p.Schema(),
+	}
+}
+
+// Open returns a *query.SortScan
+func (sp *SortPlan) Open() (query.Scan, error) {
+	available := sp.tx.AvailableBuffs()
+	runs, err := sp.splitIntoRuns(usableBuffers(available))
+	if err != nil {
+		return nil, err
+	}
+	for {
+		k := max(BestRoot(available, len(runs)), 2)
+		if len(runs) <= k {
+			return sp.openRuns(runs)
+		}
+		runs, err = sp.mergeRuns(runs, k)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// splitIntoRuns writes the records to temporary tables, filling as many blocks as there are buffers,
+// and sorts each run in its pinned blocks. There is always at least one run.
+func (sp *SortPlan) splitIntoRuns(buffers int) ([]*query.TempTable, error) {
+	src, err := sp.p.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	// the source may hold some of the buffers the runs were sized by
+	buffers = min(buffers, usableBuffers(sp.tx.AvailableBuffs()))
+
+	runs := []*query.TempTable{}
+	ok, err := src.Next()
+	for err == nil {
+		var run *query.TempTable
+		run, ok, err = sp.writeRun(src, ok, buffers)
+		if run != nil {
+			runs = append(runs, run)
+		}
+		if err == nil && !ok {
+			return runs, nil
+		}
+	}
+	removeTemps(runs)
+	return nil, err
+}
+
+// writeRun copies the current record of src and the following ones into a new run,
+// until the run fills the buffers, and then sorts the run.
+// It returns true if src is left on a record which did not fit.
+func (sp *SortPlan) writeRun(src query.Scan, ok bool, buffers int) (*query.TempTable, bool, error) {
+	run := query.NewTempTable(sp.tx, sp.schema)
+	rs := &runSorter{
+		schema: sp.schema,
+		fields: sp.fields,
+		rpb:    max(sp.tx.BlockSize()/run.Layout().SlotSize(), 1),
+	}
+	defer rs.close()
+
+	var err error
+	for ; err == nil && ok && rs.n < buffers*rs.rpb; ok, err = src.Next() {
+		if rs.n%rs.rpb == 0 {
+			err = rs.appendPage(sp.tx, run)
+			if err != nil {
+				return run, false, err
+			}
+		}
+		err = rs.insert(src)
+		if err != nil {
+			return run, false, err
+		}
+	}
+	if err != nil {
+		return run, false, err
+	}
+	sort.Sort(rs)
+	return run, ok, rs.err
+}
+
+// runSorter sorts the records of a run in the blocks of the run, which stay pinned:
+// the ith record is in slot i%rpb of the ith/rpb block
+type runSorter struct {
+	schema *record.Schema
+	fields []string
+	rpb    int
+	pages  []*record.RecordPage
+	n      int
+	// err is the first error met while sorting
+	err error
+}
+
+func (rs *runSorter) appendPage(tx record.Transaction, run *query.TempTable) error {
+	block, err := tx.Append(run.TableName() + ".tbl")
+	if err != nil {
+		return err
+	}
+	rp, err := record.NewRecordPage(tx, block, run.Layout())
+	if err != nil {
+		return err
+	}
+	rs.pages = append(rs.pages, rp)
+	return rp.Format()
+}
+
+// insert copies the current record of src into the next slot
+func (rs *runSorter) insert(src query.Scan) error {
+	rp := rs.pages[rs.n/rs.rpb]
+	slot, err := rp.InsertAfter(rs.n%rs.rpb - 1)
+	if err != nil {
+		return err
+	}
+	if slot < 0 {
+		return record.ErrSlotOutOfRange
+	}
+	for _, field := range rs.schema.Fields() {
+		val, err := src.GetVal(field)
+		if err != nil {
+			return err
+		}
+		err = rs.set(rs.n, field, val)
+		if err != nil {
+			return err
+		}
+	}
+	rs.n++
+	return nil
+}
+
+func (rs *runSorter) get(i int, field string) (query.Constant, error) {
+	rp, slot := rs.pages[i/rs.rpb], i%rs.rpb
+	if rs.schema.Type(field) == record.FieldType_INTEGER {
+		n, err := rp.GetInt(slot, field)
+		return query.NewIntConstant(n), err
+	}
+	s, err := rp.GetString(slot, field)
+	return query.NewStringConstant(s), err
+}
+
+func (rs *runSorter) set(i int, field string, val query.Constant) error {
+	rp, slot := rs.pages[i/rs.rpb], i%rs.rpb
+	if rs.schema.Type(field) == record.FieldType_INTEGER {
+		return rp.SetInt(slot, field, val.AsInt())
+	}
+	return rp.SetString(slot, field, val.AsString())
+}
+
+func (rs *runSorter) Len() int {
+	return rs.n
+}
+
+func (rs *runSorter) Less(i, j int) bool {
+	for _, field := range rs.fields {
+		v1, err := rs.get(i, field)
+		if err != nil {
+			rs.fail(err)
+			return false
+		}
+		v2, err := rs.get(j, field)
+		if err != nil {
+			rs.fail(err)
+			return false
+		}
+		if c := v1.Compare(v2); c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+// Swap exchanges the records field by field
+func (rs *runSorter) Swap(i, j int) {
+	for _, field := range rs.schema.Fields() {
+		v1, err := rs.get(i, field)
+		if err != nil {
+			rs.fail(err)
+			return
+		}
+		v2, err := rs.get(j, field)
+		if err != nil {
+			rs.fail(err)
+			return
+		}
+		err = rs.set(i, field, v2)
+		if err != nil {
+			rs.fail(err)
+			return
+		}
+		err = rs.set(j, field, v1)
+		if err != nil {
+			rs.fail(err)
+			return
+		}
+	}
+}
+
+func (rs *runSorter) fail(err error) {
+	if rs.err == nil {
+		rs.err = err
+	}
+}
+
+// close unpins the blocks of the run
+func (rs *runSorter) close() {
+	for _, rp := range rs.pages {
+		rp.Close()
+	}
+}
+
+// mergeRuns merges the runs k at a time
+func (sp *SortPlan) mergeRuns(runs []*query.TempTable, k int) ([]*query.TempTable, error) {
+	result := []*query.TempTable{}
+	for i := 0; i < len(runs); i += k {
+		group := runs[i:min(i+k, len(runs))]
+		if len(group) == 1 {
+			result = append(result, group[0])
+			continue
+		}
+		// merging the group removes it, whether or not the merge succeeds
+		run, err := sp.mergeGroup(group)
+		if err != nil {
+			removeTemps(result)
+			removeTemps(runs[i+len(group):])
+			return nil, err
+		}
+		result = append(result, run)
+	}
+	return result, nil
+}
+
+func (sp *SortPlan) mergeGroup(group []*query.TempTable) (*query.TempTable, error) {
+	src, err := sp.openRuns(group)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	run := query.NewTempTable(sp.tx, sp.schema)
+	err = copyAll(src, run)
+	if err != nil {
+		_ = run.Remove()
+		return nil, err
+	}
+	return run, nil
+}
+
+// openRuns merges the runs with a scan which removes them once it is closed
+func (sp *SortPlan) openRuns(runs []*query.TempTable) (*query.SortScan, error) {
+	scans := make([]query.UpdateScan, 0, len(runs))
+	for i, run := range runs {
+		ts, err := run.OpenOwned()
+		if err != nil {
+			for _, s := range scans {
+				s.Close()
+			}
+			removeTemps(runs[i:])
+			return nil, err
+		}
+		scans = append(scans, ts)
+	}
+	return query.NewSortScan(scans, sp.fields)
+}
+
+// BlocksAccessed counts the blocks of the sorted output, not the one-time cost of sorting
+func (sp *SortPlan) BlocksAccessed() int {
+	return NewMaterializePlan(sp.tx, sp.p).BlocksAccessed()
+}
+
+func (sp *SortPlan) RecordsOutput() int {
+	return sp.p.RecordsOutput()
+}
+
+func (sp *SortPlan) DistinctValues(field string) int {
+	return sp.p.DistinctValues(field)
+}
+
+func (sp *SortPlan) Schema() *record.Schema {
+	return sp.schema
+}
@@ -0,0 +1,150 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record/recordtest"
+	"simpledb/storage"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBestRoot(t *testing.T) {
+	testcases := []struct {
+		available int
+		size      int
+		expect    int
+	}{
+		{available: 3, size: 100, expect: 1},
+		{available: 12, size: 8, expect: 8},
+		{available: 12, size: 100, expect: 10},
+		{available: 7, size: 100, expect: 5},
+		{available: 5, size: 100, expect: 3},
+	}
+	for _, tt := range testcases {
+		t.Run(fmt.Sprintf("%d/%d", tt.available, tt.size), func(t *testing.T) {
+			require.Equal(t, tt.expect, BestRoot(tt.available, tt.size))
+		})
+	}
+}
+
+func TestSortPlan(t *testing.T) {
+	for _, available := range []int{3, 5, 64} {
+		t.Run(fmt.Sprintf("available %d", available), func(t *testing.T) {
+			tx := recordtest.NewTransaction(100)
+			tx.SetAvailableBuffs(available)
+			planner, mdm := newPlanner(t, tx)
+			execute(t, planner, tx, "create table t (a int, b varchar(5))")
+
+			// 300 records, with 7 records per block
+			expect := []string{}
+			for _, i := range rand.New(rand.NewSource(1)).Perm(300) {
+				execute(t, planner, tx, fmt.Sprintf("insert into t (a, b) values (%d, 'b%d')", i%100, i))
+				expect = append(expect, fmt.Sprintf("%d 'b%d'", i%100, i))
+			}
+
+			tp, err := NewTablePlan(tx, "t", mdm)
+			require.NoError(t, err)
+			sp := NewSortPlan(tx, tp, []string{"a"})
+			got := []string{}
+			keys := []int32{}
+			s, err := sp.Open()
+			require.NoError(t, err)
+			for {
+				ok, err := s.Next()
+				require.NoError(t, err)
+				if !ok {
+					break
+				}
+				a, err := s.GetVal("a")
+				require.NoError(t, err)
+				b, err := s.GetVal("b")
+				require.NoError(t, err)
+				got = append(got, a.String()+" "+b.String())
+				keys = append(keys, a.AsInt())
+			}
+			s.Close()
+
+			// records with equal keys may come out in any order
+			require.True(t, slices.IsSorted(keys))
+			require.ElementsMatch(t, expect, got)
+			require.Empty(t, tx.Pinned())
+			// the runs are built in pinned blocks within the buffers, and removed once read
+			require.LessOrEqual(t, tx.PeakPinned(), available)
+			require.False(t, slices.ContainsFunc(tx.Files(), storage.IsTempFile))
+		})
+	}
+}
+
+func TestSortPlan_empty(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table t (a int)")
+
+	require.Empty(t, rows(t, planner, tx, "select a from t order by a"))
+	require.Empty(t, rows(t, planner, tx, "select a, count(a) from t group by a"))
+	// a scalar aggregate has one row even over no records
+	require.Equal(t, []string{"0"}, rows(t, planner, tx, "select count(a) from t"))
+	require.Empty(t, tx.Pinned())
+	require.False(t, slices.ContainsFunc(tx.Files(), storage.IsTempFile))
+}
+
+func TestGroupByPlan(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	tx.SetAvailableBuffs(4)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table emp (id int, dept varchar(5), salary int)")
+	for i := 0; i < 40; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into emp (id, dept, salary) values (%d, 'd%d', %d)", i, i%3, 100+i))
+	}
+
+	require.Equal(t, []string{
+		"'d0' 14 1673 100 139 119",
+		"'d1' 13 1547 101 137 119",
+		"'d2' 13 1560 102 138 120",
+	}, rows(t, planner, tx, "select dept, count(id), sum(salary), min(salary), max(salary), avg(salary) from emp group by dept"))
+
+	// without group fields every record is in one group
+	require.Equal(t, []string{"40 'd0' 'd2'"}, rows(t, planner, tx, "select count(id), min(dept), max(dept) from emp"))
+
+	// groups can be ordered by their aggregates and filtered before grouping
+	require.Equal(t, []string{"'d1' 3", "'d2' 3", "'d0' 4"},
+		rows(t, planner, tx, "select dept, count(id) from emp where id < 10 group by dept order by countofid, dept"))
+	require.Empty(t, tx.Pinned())
+}
+
+func TestGroupByPlan_estimates(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	planner, _ := newPlanner(t, tx)
+	execute(t, planner, tx, "create table t (a int, b int)")
+	for i := 0; i < 30; i++ {
+		execute(t, planner, tx, fmt.Sprintf("insert into t (a, b) values (%d, %d)", i%5, i))
+	}
+	// reopening the catalog refreshes the statistics
+	mdm, err := metadata.NewMetadataManager(false, tx)
+	require.NoError(t, err)
+	tp, err := NewTablePlan(tx, "t", mdm)
+	require.NoError(t, err)
+
+	gp := NewGroupByPlan(tx, tp, []string{"a"}, []*query.AggregationFn{query.NewAggregationFn(query.Aggregate_MAX, "b")})
+	require.Equal(t, []string{"a", "maxofb"}, gp.Schema().Fields())
+	require.Equal(t, 5, gp.RecordsOutput())
+	require.Equal(t, 5, gp.DistinctValues("a"))
+	require.Equal(t, 5, gp.DistinctValues("maxofb"))
+
+	// 30 records of 12 bytes with 8 records per block
+	require.Equal(t, 4, NewSortPlan(tx, tp, []string{"a"}).BlocksAccessed())
+
+	// sorting an input whose estimate saturates does not overflow
+	huge := Plan(tp)
+	for range 4 {
+		huge = NewProductPlan(huge, huge)
+	}
+	require.Equal(t, math.MaxInt, huge.RecordsOutput())
+	require.Equal(t, math.MaxInt/8+1, NewSortPlan(tx, huge, []string{"a"}).BlocksAccessed())
+}
@@ -0,0 +1,58 @@
+package plan
+
+import (
+	"simpledb/metadata"
+	"simpledb/query"
+	"simpledb/record"
+)
+
+// TablePlan reads every record of a stored table
+type TablePlan struct {
+	tx      record.Transaction
+	tblname string
+	layout  *record.Layout
+	si      *metadata.StatInfo
+}
+
+// NewTablePlan looks the table up in the catalog
+func NewTablePlan(tx record.Transaction, tblname string, mdm *metadata.MetadataManager) (*TablePlan, error) {
+	layout, err := mdm.GetLayout(tblname, tx)
+	if err != nil {
+		return nil, err
+	}
+	si, err := mdm.GetStatInfo(tblname, layout, tx)
+	if err != nil {
+		return nil, err
+	}
+	return &TablePlan{
+		tx:      tx,
+		tblname: tblname,
+		layout:  layout,
+		si:      si,
+	}, nil
+}
+
+// Open returns a *query.TableScan, which is updatable
+func (tp *TablePlan) Open() (query.Scan, error) {
+	return tp.openTable()
+}
+
+func (tp *TablePlan) BlocksAccessed() int {
+	return tp.si.BlocksAccessed()
+}
+
+func (tp *TablePlan) RecordsOutput() int {
+	return tp.si.RecordsOutput()
+}
+
+func (tp *TablePlan) DistinctValues(field string) int {
+	return tp.si.DistinctValues(field)
+}
+
+func (tp *TablePlan) Schema() *record.Schema {
+	return tp.layout.Schema()
+}
+
+func (tp *TablePlan) openTable() (*query.TableScan, error) {
+	return query.NewTableScan(tp.tx, tp.tblname, tp.layout)
+}
@@ -0,0 +1,142 @@
+package query
+
+import (
+	"fmt"
+	"math"
+)
+
+// Aggregate is the function an AggregationFn computes over the records of a group
+type Aggregate int
+
+const (
+	Aggregate_COUNT Aggregate = iota
+	Aggregate_SUM
+	Aggregate_MIN
+	Aggregate_MAX
+	Aggregate_AVG
+)
+
+var aggregateText = [...]string{
+	Aggregate_COUNT: "count",
+	Aggregate_SUM:   "sum",
+	Aggregate_MIN:   "min",
+	Aggregate_MAX:   "max",
+	Aggregate_AVG:   "avg",
+}
+
+func (a Aggregate) String() string {
+	return aggregateText[a]
+}
+
+// LookupAggregate returns the aggregate with the name, as written in SQL
+func LookupAggregate(name string) (Aggregate, bool) {
+	for a, text := range aggregateText {
+		if text == name {
+			return Aggregate(a), true
+		}
+	}
+	return 0, false
+}
+
+// AggregationFn accumulates one aggregate of a field over the records of a group.
+// SUM and AVG apply to integer fields; AVG is truncated to an integer.
+// The sum is accumulated in 64 bits, so only a SUM which does not fit in an integer fails.
+type AggregationFn struct {
+	agg     Aggregate
+	fldname string
+	val     Constant
+	count   int32
+	sum     int64
+}
+
+func NewAggregationFn(agg Aggregate, fldname string) *AggregationFn {
+	return &AggregationFn{
+		agg:     agg,
+		fldname: fldname,
+	}
+}
+
+func (fn *AggregationFn) Aggregate() Aggregate {
+	return fn.agg
+}
+
+// SourceField returns the field the function aggregates
+func (fn *AggregationFn) SourceField() string {
+	return fn.fldname
+}
+
+// FieldName returns the name of the output field, such as "countofa" for count(a)
+func (fn *AggregationFn) FieldName() string {
+	return fn.agg.String() + "of" + fn.fldname
+}
+
+// Reset starts a new group without records
+func (fn *AggregationFn) Reset() {
+	fn.count = 0
+	fn.sum = 0
+	fn.val = Constant{}
+}
+
+// ProcessFirst starts a new group with the current record of the scan
+func (fn *AggregationFn) ProcessFirst(s Scan) error {
+	fn.Reset()
+	val, err := s.GetVal(fn.fldname)
+	if err != nil {
+		return err
+	}
+	fn.val = val
+	return fn.add(val)
+}
+
+// ProcessNext adds the current record of the scan to the group
+func (fn *AggregationFn) ProcessNext(s Scan) error {
+	val, err := s.GetVal(fn.fldname)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fn.agg == Aggregate_MIN && val.Compare(fn.val) < 0:
+		fn.val = val
+	case fn.agg == Aggregate_MAX && val.Compare(fn.val) > 0:
+		fn.val = val
+	}
+	return fn.add(val)
+}
+
+func (fn *AggregationFn) add(val Constant) error {
+	fn.count++
+	if fn.agg == Aggregate_SUM || fn.agg == Aggregate_AVG {
+		if !val.IsInt() {
+			return ErrNotNumeric
+		}
+		fn.sum += int64(val.AsInt())
+	}
+	return nil
+}
+
+// Value returns the aggregate of the records processed since ProcessFirst or Reset.
+// Only COUNT has a value over no records.
+func (fn *AggregationFn) Value() (Constant, error) {
+	if fn.count == 0 && fn.agg != Aggregate_COUNT {
+		return Constant{}, ErrNoRecords
+	}
+	switch fn.agg {
+	case Aggregate_COUNT:
+		return NewIntConstant(fn.count), nil
+	case Aggregate_SUM:
+		if fn.sum < math.MinInt32 || fn.sum > math.MaxInt32 {
+			return Constant{}, fmt.Errorf("%w: %s is %d", ErrOverflow, fn, fn.sum)
+		}
+		return NewIntConstant(int32(fn.sum)), nil
+	case Aggregate_AVG:
+		// the average of integers is an integer
+		return NewIntConstant(int32(fn.sum / int64(fn.count))), nil
+	default:
+		return fn.val, nil
+	}
+}
+
+// String returns the function as it is written in SQL
+func (fn *AggregationFn) String() string {
+	return fn.agg.String() + "(" + fn.fldname + ")"
+}
@@ -0,0 +1,83 @@
+package query
+
+import (
+	"simpledb/record"
+	"simpledb/storage"
+)
+
+// ChunkScan reads the records of a range of blocks of a table.
+// Every block of the chunk stays pinned until the scan is closed.
+type ChunkScan struct {
+	pages   []*record.RecordPage
+	layout  *record.Layout
+	current int
+	slot    int
+}
+
+// NewChunkScan pins the blocks start to end, inclusive, of the table
+func NewChunkScan(tx record.Transaction, tblname string, layout *record.Layout, start, end int) (*ChunkScan, error) {
+	cs := &ChunkScan{
+		pages:  make([]*record.RecordPage, 0, end-start+1),
+		layout: layout,
+	}
+	for blknum := start; blknum <= end; blknum++ {
+		rp, err := record.NewRecordPage(tx, storage.NewBlock(tblname+".tbl", blknum), layout)
+		if err != nil {
+			cs.Close()
+			return nil, err
+		}
+		cs.pages = append(cs.pages, rp)
+	}
+	cs.BeforeFirst()
+	return cs, nil
+}
+
+func (cs *ChunkScan) BeforeFirst() error {
+	cs.current = 0
+	cs.slot = -1
+	return nil
+}
+
+func (cs *ChunkScan) Next() (bool, error) {
+	for cs.current < len(cs.pages) {
+		var err error
+		cs.slot, err = cs.pages[cs.current].NextAfter(cs.slot)
+		if err != nil {
+			return false, err
+		}
+		if cs.slot >= 0 {
+			return true, nil
+		}
+		cs.current++
+		cs.slot = -1
+	}
+	return false, nil
+}
+
+func (cs *ChunkScan) GetInt(field string) (int32, error) {
+	return cs.pages[cs.current].GetInt(cs.slot, field)
+}
+
+func (cs *ChunkScan) GetString(field string) (string, error) {
+	return cs.pages[cs.current].GetString(cs.slot, field)
+}
+
+func (cs *ChunkScan) GetVal(field string) (Constant, error) {
+	if cs.layout.Schema().Type(field) == record.FieldType_INTEGER {
+		n, err := cs.GetInt(field)
+		return NewIntConstant(n), err
+	}
+	s, err := cs.GetString(field)
+	return NewStringConstant(s), err
+}
+
+func (cs *ChunkScan) HasField(field string) bool {
+	return cs.layout.Schema().HasField(field)
+}
+
+func (cs *ChunkScan) Close() {
+	for _, rp := range cs.pages {
+		rp.Close()
+	}
+	cs.pages = nil
+}
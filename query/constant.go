@@ -0,0 +1,74 @@
+package query
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// Constant is an integer or string value stored in a record.
+// Constants are comparable, so they can be used as map keys.
+type Constant struct {
+	ival  int32
+	sval  string
+	isInt bool
+}
+
+func NewIntConstant(n int32) Constant {
+	return Constant{ival: n, isInt: true}
+}
+
+func NewStringConstant(s string) Constant {
+	return Constant{sval: s}
+}
+
+func (c Constant) IsInt() bool {
+	return c.isInt
+}
+
+func (c Constant) AsInt() int32 {
+	return c.ival
+}
+
+func (c Constant) AsString() string {
+	return c.sval
+}
+
+func (c Constant) Equals(other Constant) bool {
+	return c == other
+}
+
+// Compare returns -1, 0 or +1 depending on whether c is less than, equal to or greater than other.
+// Integers sort before strings.
+func (c Constant) Compare(other Constant) int {
+	if c.isInt != other.isInt {
+		if c.isInt {
+			return -1
+		}
+		return 1
+	}
+	if c.isInt {
+		return cmp.Compare(c.ival, other.ival)
+	}
+	return strings.Compare(c.sval, other.sval)
+}
+
+// HashCode returns a hash code for the constant
+func (c Constant) HashCode() int {
+	if c.isInt {
+		return int(c.ival)
+	}
+	hash := 0
+	for i := 0; i < len(c.sval); i++ {
+		hash = 31*hash + int(c.sval[i])
+	}
+	return hash
+}
+
+// String returns the constant as it is written in SQL
+func (c Constant) String() string {
+	if c.isInt {
+		return strconv.Itoa(int(c.ival))
+	}
+	return "'" + strings.ReplaceAll(c.sval, "'", "''") + "'"
+}
@@ -0,0 +1,49 @@
+package query
+
+import "simpledb/record"
+
+// Expression is either a constant or the name of a field
+type Expression struct {
+	val     Constant
+	fldname string
+}
+
+func NewConstantExpression(val Constant) *Expression {
+	return &Expression{val: val}
+}
+
+func NewFieldExpression(fldname string) *Expression {
+	return &Expression{fldname: fldname}
+}
+
+func (e *Expression) IsFieldName() bool {
+	return e.fldname != ""
+}
+
+func (e *Expression) AsConstant() Constant {
+	return e.val
+}
+
+func (e *Expression) AsFieldName() string {
+	return e.fldname
+}
+
+func (e *Expression) String() string {
+	if e.IsFieldName() {
+		return e.fldname
+	}
+	return e.val.String()
+}
+
+// Evaluate returns the value of the expression for the current record of the scan
+func (e *Expression) Evaluate(s Scan) (Constant, error) {
+	if e.IsFieldName() {
+		return s.GetVal(e.fldname)
+	}
+	return e.val, nil
+}
+
+// AppliesTo returns true if every field the expression mentions is in the schema
+func (e *Expression) AppliesTo(schema *record.Schema) bool {
+	return !e.IsFieldName() || schema.HasField(e.fldname)
+}
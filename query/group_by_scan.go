@@ -0,0 +1,153 @@
+package query
+
+import "slices"
+
+// GroupByScan outputs one record per group of the underlying scan,
+// holding the group fields and the aggregates of the group.
+// The underlying scan must be sorted on the group fields.
+// Without group fields, the whole scan is a single group, even when it is empty.
+type GroupByScan struct {
+	s           Scan
+	groupfields []string
+	aggfns      []*AggregationFn
+	groupval    map[string]Constant
+	moregroups  bool
+	// emptygroup is set until the group of an empty scan without group fields is output
+	emptygroup bool
+}
+
+// NewGroupByScan positions the scan before its first group
+func NewGroupByScan(s Scan, groupfields []string, aggfns []*AggregationFn) (*GroupByScan, error) {
+	gs := &GroupByScan{
+		s:           s,
+		groupfields: groupfields,
+		aggfns:      aggfns,
+	}
+	err := gs.BeforeFirst()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return gs, nil
+}
+
+func (gs *GroupByScan) BeforeFirst() error {
+	err := gs.s.BeforeFirst()
+	if err != nil {
+		return err
+	}
+	gs.moregroups, err = gs.s.Next()
+	if err != nil {
+		return err
+	}
+	gs.emptygroup = !gs.moregroups && len(gs.groupfields) == 0
+	return nil
+}
+
+// Next reads the records of the next group, leaving the underlying scan on the first record of the following one
+func (gs *GroupByScan) Next() (bool, error) {
+	if gs.emptygroup {
+		gs.emptygroup = false
+		gs.groupval = map[string]Constant{}
+		for _, fn := range gs.aggfns {
+			fn.Reset()
+		}
+		return true, nil
+	}
+	if !gs.moregroups {
+		return false, nil
+	}
+	var err error
+	gs.groupval, err = gs.groupValue()
+	if err != nil {
+		return false, err
+	}
+	for _, fn := range gs.aggfns {
+		err = fn.ProcessFirst(gs.s)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for {
+		gs.moregroups, err = gs.s.Next()
+		if err != nil {
+			return false, err
+		}
+		if !gs.moregroups {
+			return true, nil
+		}
+		val, err := gs.groupValue()
+		if err != nil {
+			return false, err
+		}
+		if !sameGroup(gs.groupval, val) {
+			return true, nil
+		}
+		for _, fn := range gs.aggfns {
+			err = fn.ProcessNext(gs.s)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+func (gs *GroupByScan) groupValue() (map[string]Constant, error) {
+	val := make(map[string]Constant, len(gs.groupfields))
+	for _, field := range gs.groupfields {
+		v, err := gs.s.GetVal(field)
+		if err != nil {
+			return nil, err
+		}
+		val[field] = v
+	}
+	return val, nil
+}
+
+func sameGroup(v1, v2 map[string]Constant) bool {
+	for field, v := range v1 {
+		if !v.Equals(v2[field]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (gs *GroupByScan) GetInt(field string) (int32, error) {
+	val, err := gs.GetVal(field)
+	return val.AsInt(), err
+}
+
+func (gs *GroupByScan) GetString(field string) (string, error) {
+	val, err := gs.GetVal(field)
+	return val.AsString(), err
+}
+
+func (gs *GroupByScan) GetVal(field string) (Constant, error) {
+	if val, found := gs.groupval[field]; found {
+		return val, nil
+	}
+	for _, fn := range gs.aggfns {
+		if fn.FieldName() == field {
+			return fn.Value()
+		}
+	}
+	return Constant{}, ErrFieldNotFound
+}
+
+func (gs *GroupByScan) HasField(field string) bool {
+	if slices.Contains(gs.groupfields, field) {
+		return true
+	}
+	for _, fn := range gs.aggfns {
+		if fn.FieldName() == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (gs *GroupByScan) Close() {
+	gs.s.Close()
+}
@@ -0,0 +1,191 @@
+package query
+
+import "simpledb/record"
+
+// HashPartition opens the inputs of one partition of a hash join:
+// the records of both sides whose join values hash to the same bucket
+type HashPartition func() (lhs Scan, rhs Scan, err error)
+
+// HashJoinScan outputs the records of lhs and rhs whose join fields are equal.
+// For each partition it loads the rhs records into an in-memory hash table,
+// then probes the table with every lhs record. Only one partition is open at a time.
+// The table holds at most chunksize records: the rhs of a partition which holds more
+// is loaded a chunk at a time, and lhs is scanned once per chunk, as in a block nested loop join.
+type HashJoinScan struct {
+	parts     []HashPartition
+	lhsSchema *record.Schema
+	rhsSchema *record.Schema
+	lhsfield  string
+	rhsfield  string
+	chunksize int
+	part      int
+	lhs       Scan
+	rhs       Scan
+	// chunk is the number of the chunk of rhs in the table, rhsmore is set while rhs has records left
+	chunk   int
+	rhsmore bool
+	table   map[Constant][]map[string]Constant
+	matches []map[string]Constant
+	rhsrec  map[string]Constant
+}
+
+// NewHashJoinScan opens the first partition and positions the scan before its first record.
+// chunksize is the number of rhs records the hash table may hold, at least 1.
+func NewHashJoinScan(parts []HashPartition, lhsSchema, rhsSchema *record.Schema, lhsfield, rhsfield string, chunksize int) (*HashJoinScan, error) {
+	hs := &HashJoinScan{
+		parts:     parts,
+		lhsSchema: lhsSchema,
+		rhsSchema: rhsSchema,
+		lhsfield:  lhsfield,
+		rhsfield:  rhsfield,
+		chunksize: max(chunksize, 1),
+	}
+	err := hs.BeforeFirst()
+	if err != nil {
+		hs.Close()
+		return nil, err
+	}
+	return hs, nil
+}
+
+func (hs *HashJoinScan) BeforeFirst() error {
+	if hs.part == 0 && hs.chunk == 0 && hs.lhs != nil {
+		// the hash table of the first chunk of the first partition is still loaded
+		hs.matches = nil
+		return hs.lhs.BeforeFirst()
+	}
+	return hs.openPartition(0)
+}
+
+func (hs *HashJoinScan) Next() (bool, error) {
+	for hs.lhs != nil {
+		if len(hs.matches) > 0 {
+			hs.rhsrec = hs.matches[0]
+			hs.matches = hs.matches[1:]
+			return true, nil
+		}
+		ok, err := hs.lhs.Next()
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			err = hs.nextChunk()
+			if err != nil {
+				return false, err
+			}
+			continue
+		}
+		val, err := hs.lhs.GetVal(hs.lhsfield)
+		if err != nil {
+			return false, err
+		}
+		hs.matches = hs.table[val]
+	}
+	return false, nil
+}
+
+// openPartition closes the current partition, opens partition i and loads its first chunk.
+// Past the last partition, nothing is left open.
+func (hs *HashJoinScan) openPartition(i int) error {
+	hs.closePartition()
+	hs.part = i
+	hs.chunk = 0
+	if i >= len(hs.parts) {
+		return nil
+	}
+	lhs, rhs, err := hs.parts[i]()
+	if err != nil {
+		return err
+	}
+	hs.lhs, hs.rhs = lhs, rhs
+	return hs.loadChunk()
+}
+
+// nextChunk loads the next chunk of rhs and rewinds lhs, or opens the next partition
+// once rhs has been loaded entirely
+func (hs *HashJoinScan) nextChunk() error {
+	if !hs.rhsmore {
+		return hs.openPartition(hs.part + 1)
+	}
+	err := hs.loadChunk()
+	if err != nil {
+		return err
+	}
+	if len(hs.table) == 0 {
+		// the previous chunk took the last records
+		return hs.openPartition(hs.part + 1)
+	}
+	hs.chunk++
+	return hs.lhs.BeforeFirst()
+}
+
+// loadChunk loads the next chunksize records of rhs into the hash table
+func (hs *HashJoinScan) loadChunk() error {
+	hs.table = make(map[Constant][]map[string]Constant)
+	hs.matches = nil
+	fields := hs.rhsSchema.Fields()
+	for n := 0; n < hs.chunksize; n++ {
+		ok, err := hs.rhs.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			hs.rhsmore = false
+			return nil
+		}
+		rec := make(map[string]Constant, len(fields))
+		for _, field := range fields {
+			rec[field], err = hs.rhs.GetVal(field)
+			if err != nil {
+				return err
+			}
+		}
+		key := rec[hs.rhsfield]
+		hs.table[key] = append(hs.table[key], rec)
+	}
+	hs.rhsmore = true
+	return nil
+}
+
+func (hs *HashJoinScan) closePartition() {
+	if hs.lhs != nil {
+		hs.lhs.Close()
+		hs.lhs = nil
+	}
+	if hs.rhs != nil {
+		hs.rhs.Close()
+		hs.rhs = nil
+	}
+	hs.table = nil
+	hs.matches = nil
+	hs.rhsrec = nil
+	hs.rhsmore = false
+}
+
+func (hs *HashJoinScan) GetInt(field string) (int32, error) {
+	val, err := hs.GetVal(field)
+	return val.AsInt(), err
+}
+
+func (hs *HashJoinScan) GetString(field string) (string, error) {
+	val, err := hs.GetVal(field)
+	return val.AsString(), err
+}
+
+func (hs *HashJoinScan) GetVal(field string) (Constant, error) {
+	if hs.lhsSchema.HasField(field) && hs.lhs != nil {
+		return hs.lhs.GetVal(field)
+	}
+	if val, found := hs.rhsrec[field]; found {
+		return val, nil
+	}
+	return Constant{}, ErrFieldNotFound
+}
+
+func (hs *HashJoinScan) HasField(field string) bool {
+	return hs.lhsSchema.HasField(field) || hs.rhsSchema.HasField(field)
+}
+
+func (hs *HashJoinScan) Close() {
+	hs.closePartition()
+}
@@ -0,0 +1,124 @@
+package query
+
+// MergeJoinScan outputs the records of s1 and s2 whose join fields are equal.
+// Both scans must be sorted on their join field. When several records of s1 share
+// a join value, s2 returns to the start of its group of that value for each of them.
+type MergeJoinScan struct {
+	s1       Scan
+	s2       *SortScan
+	fldname1 string
+	fldname2 string
+	joinval  *Constant
+}
+
+// NewMergeJoinScan positions the scan before its first record
+func NewMergeJoinScan(s1 Scan, s2 *SortScan, fldname1, fldname2 string) (*MergeJoinScan, error) {
+	ms := &MergeJoinScan{
+		s1:       s1,
+		s2:       s2,
+		fldname1: fldname1,
+		fldname2: fldname2,
+	}
+	err := ms.BeforeFirst()
+	if err != nil {
+		ms.Close()
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (ms *MergeJoinScan) BeforeFirst() error {
+	err := ms.s1.BeforeFirst()
+	if err != nil {
+		return err
+	}
+	ms.joinval = nil
+	return ms.s2.BeforeFirst()
+}
+
+// Next moves on within the current group of s2, then to the next record of s1 with the same
+// join value, and otherwise advances whichever scan is behind until the join values meet
+func (ms *MergeJoinScan) Next() (bool, error) {
+	hasmore2, err := ms.s2.Next()
+	if err != nil {
+		return false, err
+	}
+	if hasmore2 && ms.joinval != nil {
+		val, err := ms.s2.GetVal(ms.fldname2)
+		if err != nil {
+			return false, err
+		}
+		if val.Equals(*ms.joinval) {
+			return true, nil
+		}
+	}
+
+	hasmore1, err := ms.s1.Next()
+	if err != nil {
+		return false, err
+	}
+	if hasmore1 && ms.joinval != nil {
+		val, err := ms.s1.GetVal(ms.fldname1)
+		if err != nil {
+			return false, err
+		}
+		if val.Equals(*ms.joinval) {
+			return true, ms.s2.RestorePosition()
+		}
+	}
+
+	for hasmore1 && hasmore2 {
+		v1, err := ms.s1.GetVal(ms.fldname1)
+		if err != nil {
+			return false, err
+		}
+		v2, err := ms.s2.GetVal(ms.fldname2)
+		if err != nil {
+			return false, err
+		}
+		switch c := v1.Compare(v2); {
+		case c < 0:
+			hasmore1, err = ms.s1.Next()
+		case c > 0:
+			hasmore2, err = ms.s2.Next()
+		default:
+			ms.s2.SavePosition()
+			ms.joinval = &v2
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (ms *MergeJoinScan) GetInt(field string) (int32, error) {
+	if ms.s1.HasField(field) {
+		return ms.s1.GetInt(field)
+	}
+	return ms.s2.GetInt(field)
+}
+
+func (ms *MergeJoinScan) GetString(field string) (string, error) {
+	if ms.s1.HasField(field) {
+		return ms.s1.GetString(field)
+	}
+	return ms.s2.GetString(field)
+}
+
+func (ms *MergeJoinScan) GetVal(field string) (Constant, error) {
+	if ms.s1.HasField(field) {
+		return ms.s1.GetVal(field)
+	}
+	return ms.s2.GetVal(field)
+}
+
+func (ms *MergeJoinScan) HasField(field string) bool {
+	return ms.s1.HasField(field) || ms.s2.HasField(field)
+}
+
+func (ms *MergeJoinScan) Close() {
+	ms.s1.Close()
+	ms.s2.Close()
+}
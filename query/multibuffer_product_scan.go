@@ -0,0 +1,112 @@
+package query
+
+import "simpledb/record"
+
+// MultibufferProductScan combines every record of lhs with every record of a stored table.
+// The table is read in chunks of blocks which are pinned together, and lhs is scanned
+// once per chunk instead of the table once per record of lhs.
+type MultibufferProductScan struct {
+	tx        record.Transaction
+	lhs       Scan
+	tblname   string
+	layout    *record.Layout
+	chunksize int
+	filesize  int
+	nextblk   int
+	chunk     *ChunkScan
+	prod      *ProductScan
+}
+
+// NewMultibufferProductScan positions the scan before its first record.
+// Each chunk holds at most chunksize blocks of the table.
+func NewMultibufferProductScan(tx record.Transaction, lhs Scan, tblname string, layout *record.Layout, chunksize int) (*MultibufferProductScan, error) {
+	filesize, err := tx.Size(tblname + ".tbl")
+	if err != nil {
+		return nil, err
+	}
+	ms := &MultibufferProductScan{
+		tx:        tx,
+		lhs:       lhs,
+		tblname:   tblname,
+		layout:    layout,
+		chunksize: max(chunksize, 1),
+		filesize:  filesize,
+	}
+	err = ms.BeforeFirst()
+	if err != nil {
+		ms.Close()
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (ms *MultibufferProductScan) BeforeFirst() error {
+	ms.nextblk = 0
+	_, err := ms.useNextChunk()
+	return err
+}
+
+func (ms *MultibufferProductScan) Next() (bool, error) {
+	if ms.prod == nil {
+		return false, nil
+	}
+	for {
+		ok, err := ms.prod.Next()
+		if err != nil || ok {
+			return ok, err
+		}
+		ok, err = ms.useNextChunk()
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+}
+
+// useNextChunk pins the next chunk of the table and rewinds lhs.
+// It returns false when the table has no more blocks.
+func (ms *MultibufferProductScan) useNextChunk() (bool, error) {
+	if ms.chunk != nil {
+		ms.chunk.Close()
+		ms.chunk = nil
+		ms.prod = nil
+	}
+	if ms.nextblk >= ms.filesize {
+		return false, nil
+	}
+	end := min(ms.nextblk+ms.chunksize, ms.filesize) - 1
+	chunk, err := NewChunkScan(ms.tx, ms.tblname, ms.layout, ms.nextblk, end)
+	if err != nil {
+		return false, err
+	}
+	ms.chunk = chunk
+	ms.nextblk = end + 1
+	ms.prod, err = NewProductScan(ms.lhs, ms.chunk)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (ms *MultibufferProductScan) GetInt(field string) (int32, error) {
+	return ms.prod.GetInt(field)
+}
+
+func (ms *MultibufferProductScan) GetString(field string) (string, error) {
+	return ms.prod.GetString(field)
+}
+
+func (ms *MultibufferProductScan) GetVal(field string) (Constant, error) {
+	return ms.prod.GetVal(field)
+}
+
+func (ms *MultibufferProductScan) HasField(field string) bool {
+	return ms.lhs.HasField(field) || ms.layout.Schema().HasField(field)
+}
+
+// Close closes lhs and unpins the current chunk
+func (ms *MultibufferProductScan) Close() {
+	ms.lhs.Close()
+	if ms.chunk != nil {
+		ms.chunk.Close()
+	}
+}
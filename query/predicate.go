@@ -0,0 +1,117 @@
+package query
+
+import (
+	"simpledb/record"
+	"strings"
+)
+
+// Predicate is a conjunction of terms. An empty predicate is always true.
+type Predicate struct {
+	terms []*Term
+}
+
+func NewPredicate(terms ...*Term) *Predicate {
+	return &Predicate{
+		terms: terms,
+	}
+}
+
+// ConjoinWith adds the terms of the other predicate
+func (p *Predicate) ConjoinWith(other *Predicate) {
+	p.terms = append(p.terms, other.terms...)
+}
+
+func (p *Predicate) Terms() []*Term {
+	return p.terms
+}
+
+func (p *Predicate) IsEmpty() bool {
+	return len(p.terms) == 0
+}
+
+func (p *Predicate) String() string {
+	terms := make([]string, len(p.terms))
+	for i, t := range p.terms {
+		terms[i] = t.String()
+	}
+	return strings.Join(terms, " and ")
+}
+
+// IsSatisfied returns true if every term is satisfied by the current record of the scan
+func (p *Predicate) IsSatisfied(s Scan) (bool, error) {
+	for _, t := range p.terms {
+		ok, err := t.IsSatisfied(s)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// SelectSubPred returns the terms which apply to the schema, or nil if there are none
+func (p *Predicate) SelectSubPred(schema *record.Schema) *Predicate {
+	result := NewPredicate()
+	for _, t := range p.terms {
+		if t.AppliesTo(schema) {
+			result.terms = append(result.terms, t)
+		}
+	}
+	if result.IsEmpty() {
+		return nil
+	}
+	return result
+}
+
+// JoinSubPred returns the terms which apply to the union of both schemas but to neither of them alone,
+// or nil if there are none
+func (p *Predicate) JoinSubPred(schema1, schema2 *record.Schema) *Predicate {
+	union := record.NewSchema()
+	union.AddAll(schema1)
+	union.AddAll(schema2)
+
+	result := NewPredicate()
+	for _, t := range p.terms {
+		if !t.AppliesTo(schema1) && !t.AppliesTo(schema2) && t.AppliesTo(union) {
+			result.terms = append(result.terms, t)
+		}
+	}
+	if result.IsEmpty() {
+		return nil
+	}
+	return result
+}
+
+// EquatesWithConstant returns the constant if some term is of the form "field = c"
+func (p *Predicate) EquatesWithConstant(fldname string) (Constant, bool) {
+	for _, t := range p.terms {
+		if c, ok := t.EquatesWithConstant(fldname); ok {
+			return c, true
+		}
+	}
+	return Constant{}, false
+}
+
+// EquatesWithField returns the other field if some term is of the form "field = other"
+func (p *Predicate) EquatesWithField(fldname string) (string, bool) {
+	for _, t := range p.terms {
+		if f, ok := t.EquatesWithField(fldname); ok {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Bounds returns the tightest inclusive bounds the terms put on the field,
+// or nil when the field is unbounded on that side
+func (p *Predicate) Bounds(fldname string) (lo, hi *Constant) {
+	for _, t := range p.terms {
+		tlo, thi := t.Bounds(fldname)
+		if tlo != nil && (lo == nil || tlo.Compare(*lo) > 0) {
+			lo = tlo
+		}
+		if thi != nil && (hi == nil || thi.Compare(*hi) < 0) {
+			hi = thi
+		}
+	}
+	return lo, hi
+}
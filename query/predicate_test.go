@@ -0,0 +1,87 @@
+package query
+
+import (
+	"simpledb/record"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredicate_SubPred(t *testing.T) {
+	student := record.NewSchema()
+	student.AddIntField("sid")
+	student.AddIntField("majorid")
+	dept := record.NewSchema()
+	dept.AddIntField("did")
+
+	selectTerm := NewTerm(NewFieldExpression("sid"), NewConstantExpression(NewIntConstant(3)))
+	joinTerm := NewTerm(NewFieldExpression("majorid"), NewFieldExpression("did"))
+	pred := NewPredicate(selectTerm, joinTerm)
+
+	require.Equal(t, NewPredicate(selectTerm), pred.SelectSubPred(student))
+	require.Nil(t, pred.SelectSubPred(dept))
+	require.Equal(t, NewPredicate(joinTerm), pred.JoinSubPred(student, dept))
+	require.Nil(t, NewPredicate(selectTerm).JoinSubPred(student, dept))
+
+	c, ok := pred.EquatesWithConstant("sid")
+	require.True(t, ok)
+	require.Equal(t, NewIntConstant(3), c)
+	_, ok = pred.EquatesWithConstant("majorid")
+	require.False(t, ok)
+
+	f, ok := pred.EquatesWithField("did")
+	require.True(t, ok)
+	require.Equal(t, "majorid", f)
+	_, ok = pred.EquatesWithField("sid")
+	require.False(t, ok)
+}
+
+func TestTerm_IsSatisfied(t *testing.T) {
+	one, two := NewConstantExpression(NewIntConstant(1)), NewConstantExpression(NewIntConstant(2))
+	testcases := []struct {
+		op     Operator
+		expect bool
+	}{
+		{Operator_EQ, false},
+		{Operator_NE, true},
+		{Operator_LT, true},
+		{Operator_LE, true},
+		{Operator_GT, false},
+		{Operator_GE, false},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.op.String(), func(t *testing.T) {
+			ok, err := NewComparisonTerm(one, tt.op, two).IsSatisfied(nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, ok)
+		})
+	}
+}
+
+func TestPredicate_Bounds(t *testing.T) {
+	a := NewFieldExpression("a")
+	c := func(n int32) *Expression {
+		return NewConstantExpression(NewIntConstant(n))
+	}
+	pred := NewPredicate(
+		NewComparisonTerm(a, Operator_GT, c(1)),
+		NewComparisonTerm(c(3), Operator_LE, a), // a >= 3
+		NewComparisonTerm(a, Operator_LT, c(9)),
+		NewComparisonTerm(a, Operator_NE, c(5)),
+		NewComparisonTerm(NewFieldExpression("b"), Operator_LT, c(0)),
+	)
+	lo, hi := pred.Bounds("a")
+	require.Equal(t, NewIntConstant(3), *lo)
+	require.Equal(t, NewIntConstant(9), *hi)
+
+	lo, hi = pred.Bounds("b")
+	require.Nil(t, lo)
+	require.Equal(t, NewIntConstant(0), *hi)
+
+	lo, hi = NewPredicate(NewTerm(a, c(4))).Bounds("a")
+	require.Equal(t, NewIntConstant(4), *lo)
+	require.Equal(t, NewIntConstant(4), *hi)
+
+	_, ok := NewPredicate(NewComparisonTerm(a, Operator_GE, c(4))).EquatesWithConstant("a")
+	require.False(t, ok)
+}
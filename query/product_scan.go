@@ -0,0 +1,82 @@
+package query
+
+// ProductScan outputs every combination of a record of s1 with a record of s2.
+// s2 is scanned once for each record of s1.
+type ProductScan struct {
+	s1     Scan
+	s2     Scan
+	hasLHS bool
+}
+
+// NewProductScan returns a ProductScan positioned before its first record
+func NewProductScan(s1, s2 Scan) (*ProductScan, error) {
+	ps := &ProductScan{
+		s1: s1,
+		s2: s2,
+	}
+	err := ps.BeforeFirst()
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (ps *ProductScan) BeforeFirst() error {
+	err := ps.s1.BeforeFirst()
+	if err != nil {
+		return err
+	}
+	ps.hasLHS, err = ps.s1.Next()
+	if err != nil {
+		return err
+	}
+	return ps.s2.BeforeFirst()
+}
+
+func (ps *ProductScan) Next() (bool, error) {
+	for ps.hasLHS {
+		ok, err := ps.s2.Next()
+		if err != nil || ok {
+			return ok, err
+		}
+		ps.hasLHS, err = ps.s1.Next()
+		if err != nil {
+			return false, err
+		}
+		err = ps.s2.BeforeFirst()
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (ps *ProductScan) GetInt(field string) (int32, error) {
+	if ps.s1.HasField(field) {
+		return ps.s1.GetInt(field)
+	}
+	return ps.s2.GetInt(field)
+}
+
+func (ps *ProductScan) GetString(field string) (string, error) {
+	if ps.s1.HasField(field) {
+		return ps.s1.GetString(field)
+	}
+	return ps.s2.GetString(field)
+}
+
+func (ps *ProductScan) GetVal(field string) (Constant, error) {
+	if ps.s1.HasField(field) {
+		return ps.s1.GetVal(field)
+	}
+	return ps.s2.GetVal(field)
+}
+
+func (ps *ProductScan) HasField(field string) bool {
+	return ps.s1.HasField(field) || ps.s2.HasField(field)
+}
+
+func (ps *ProductScan) Close() {
+	ps.s1.Close()
+	ps.s2.Close()
+}
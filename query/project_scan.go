@@ -0,0 +1,53 @@
+package query
+
+import "slices"
+
+// ProjectScan outputs the records of the underlying scan restricted to some of their fields
+type ProjectScan struct {
+	s      Scan
+	fields []string
+}
+
+func NewProjectScan(s Scan, fields []string) *ProjectScan {
+	return &ProjectScan{
+		s:      s,
+		fields: fields,
+	}
+}
+
+func (ps *ProjectScan) BeforeFirst() error {
+	return ps.s.BeforeFirst()
+}
+
+func (ps *ProjectScan) Next() (bool, error) {
+	return ps.s.Next()
+}
+
+func (ps *ProjectScan) GetInt(field string) (int32, error) {
+	if !ps.HasField(field) {
+		return 0, ErrFieldNotFound
+	}
+	return ps.s.GetInt(field)
+}
+
+func (ps *ProjectScan) GetString(field string) (string, error) {
+	if !ps.HasField(field) {
+		return "", ErrFieldNotFound
+	}
+	return ps.s.GetString(field)
+}
+
+func (ps *ProjectScan) GetVal(field string) (Constant, error) {
+	if !ps.HasField(field) {
+		return Constant{}, ErrFieldNotFound
+	}
+	return ps.s.GetVal(field)
+}
+
+func (ps *ProjectScan) HasField(field string) bool {
+	return slices.Contains(ps.fields, field)
+}
+
+func (ps *ProjectScan) Close() {
+	ps.s.Close()
+}
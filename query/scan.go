@@ -0,0 +1,40 @@
+package query
+
+import (
+	"errors"
+	"simpledb/record"
+)
+
+var (
+	ErrFieldNotFound = errors.New("field not found")
+	ErrNotUpdatable  = errors.New("scan is not updatable")
+	ErrNotNumeric    = errors.New("value is not numeric")
+	ErrNoRecords     = errors.New("aggregate has no value over no records")
+	ErrOverflow      = errors.New("integer out of range")
+)
+
+// Scan iterates over the output records of a relational operator
+type Scan interface {
+	// BeforeFirst positions the scan before its first record
+	BeforeFirst() error
+	// Next moves to the next record and returns false when there is none
+	Next() (bool, error)
+	GetInt(field string) (int32, error)
+	GetString(field string) (string, error)
+	GetVal(field string) (Constant, error)
+	HasField(field string) bool
+	// Close releases the resources held by the scan and its children
+	Close()
+}
+
+// UpdateScan is a Scan whose records can be modified
+type UpdateScan interface {
+	Scan
+	SetInt(field string, val int32) error
+	SetString(field string, val string) error
+	SetVal(field string, val Constant) error
+	Insert() error
+	Delete() error
+	GetRID() record.RID
+	MoveToRID(rid record.RID) error
+}
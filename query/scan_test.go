@@ -0,0 +1,201 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"simpledb/record"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTable(t *testing.T, tx record.Transaction, tblname string, schema *record.Schema, n int, fill func(ts *TableScan, i int)) *TableScan {
+	ts, err := NewTableScan(tx, tblname, record.NewLayout(schema))
+	require.NoError(t, err)
+	for i := 0; i < n; i++ {
+		require.NoError(t, ts.Insert())
+		fill(ts, i)
+	}
+	require.NoError(t, ts.BeforeFirst())
+	return ts
+}
+
+func collect(t *testing.T, s Scan, fields ...string) [][]Constant {
+	got := [][]Constant{}
+	require.NoError(t, s.BeforeFirst())
+	for {
+		ok, err := s.Next()
+		require.NoError(t, err)
+		if !ok {
+			return got
+		}
+		row := make([]Constant, len(fields))
+		for i, f := range fields {
+			row[i], err = s.GetVal(f)
+			require.NoError(t, err)
+		}
+		got = append(got, row)
+	}
+}
+
+func TestScans(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+
+	student := record.NewSchema()
+	student.AddIntField("sid")
+	student.AddStringField("sname", 10)
+	student.AddIntField("majorid")
+	s1 := newTable(t, tx, "student", student, 6, func(ts *TableScan, i int) {
+		require.NoError(t, ts.SetVal("sid", NewIntConstant(int32(i))))
+		require.NoError(t, ts.SetVal("sname", NewStringConstant(fmt.Sprintf("s%d", i))))
+		require.NoError(t, ts.SetVal("majorid", NewIntConstant(int32(10+i%2))))
+	})
+
+	dept := record.NewSchema()
+	dept.AddIntField("did")
+	dept.AddStringField("dname", 8)
+	s2 := newTable(t, tx, "dept", dept, 2, func(ts *TableScan, i int) {
+		require.NoError(t, ts.SetInt("did", int32(10+i)))
+		require.NoError(t, ts.SetString("dname", fmt.Sprintf("d%d", 10+i)))
+	})
+
+	// select sname, dname from student, dept where majorid = did and dname = 'd11'
+	product, err := NewProductScan(s1, s2)
+	require.NoError(t, err)
+	require.Len(t, collect(t, product), 12)
+
+	pred := NewPredicate(
+		NewTerm(NewFieldExpression("majorid"), NewFieldExpression("did")),
+		NewTerm(NewFieldExpression("dname"), NewConstantExpression(NewStringConstant("d11"))),
+	)
+	s := NewProjectScan(NewSelectScan(product, pred), []string{"sname", "dname"})
+	require.Equal(t, [][]Constant{
+		{NewStringConstant("s1"), NewStringConstant("d11")},
+		{NewStringConstant("s3"), NewStringConstant("d11")},
+		{NewStringConstant("s5"), NewStringConstant("d11")},
+	}, collect(t, s, "sname", "dname"))
+
+	require.True(t, s.HasField("sname"))
+	require.False(t, s.HasField("sid"))
+	_, err = s.GetInt("sid")
+	require.ErrorIs(t, err, ErrFieldNotFound)
+
+	s.Close()
+	require.Empty(t, tx.Pinned())
+}
+
+func TestSelectScan_update(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	schema := record.NewSchema()
+	schema.AddIntField("a")
+	ts := newTable(t, tx, "t", schema, 10, func(ts *TableScan, i int) {
+		require.NoError(t, ts.SetInt("a", int32(i%3)))
+	})
+
+	// update t set a = 7 where a = 1
+	ss := NewSelectScan(ts, NewPredicate(NewTerm(NewFieldExpression("a"), NewConstantExpression(NewIntConstant(1)))))
+	for {
+		ok, err := ss.Next()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		require.NoError(t, ss.SetVal("a", NewIntConstant(7)))
+	}
+
+	// delete from t where a = 0
+	ss = NewSelectScan(ts, NewPredicate(NewTerm(NewConstantExpression(NewIntConstant(0)), NewFieldExpression("a"))))
+	require.NoError(t, ss.BeforeFirst())
+	for {
+		ok, err := ss.Next()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		require.NoError(t, ss.Delete())
+	}
+
+	seven, two := NewIntConstant(7), NewIntConstant(2)
+	require.Equal(t, [][]Constant{{seven}, {two}, {seven}, {two}, {seven}, {two}}, collect(t, ts, "a"))
+
+	// a select over a read-only scan can't be updated
+	ss = NewSelectScan(NewProjectScan(ts, []string{"a"}), NewPredicate())
+	require.ErrorIs(t, ss.SetInt("a", 1), ErrNotUpdatable)
+	require.ErrorIs(t, ss.Insert(), ErrNotUpdatable)
+
+	ss.Close()
+	require.Empty(t, tx.Pinned())
+}
+
+func TestProductScan_empty(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	schema1 := record.NewSchema()
+	schema1.AddIntField("a")
+	schema2 := record.NewSchema()
+	schema2.AddIntField("b")
+
+	empty := newTable(t, tx, "t1", schema1, 0, nil)
+	full := newTable(t, tx, "t2", schema2, 3, func(ts *TableScan, i int) {
+		require.NoError(t, ts.SetInt("b", int32(i)))
+	})
+
+	ps, err := NewProductScan(empty, full)
+	require.NoError(t, err)
+	require.Empty(t, collect(t, ps))
+
+	ps, err = NewProductScan(full, empty)
+	require.NoError(t, err)
+	require.Empty(t, collect(t, ps))
+	ps.Close()
+}
+
+func TestGroupByScan_empty(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	schema := record.NewSchema()
+	schema.AddIntField("a")
+	empty := newTable(t, tx, "t", schema, 0, nil)
+
+	// without group fields, an empty scan is a single group
+	count := NewAggregationFn(Aggregate_COUNT, "a")
+	sum := NewAggregationFn(Aggregate_SUM, "a")
+	gs, err := NewGroupByScan(empty, nil, []*AggregationFn{count, sum})
+	require.NoError(t, err)
+	require.Equal(t, [][]Constant{{NewIntConstant(0)}}, collect(t, gs, "countofa"))
+	require.NoError(t, gs.BeforeFirst())
+	ok, err := gs.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, err = gs.GetVal("sumofa")
+	require.ErrorIs(t, err, ErrNoRecords)
+
+	// with group fields, an empty scan has no groups
+	gs, err = NewGroupByScan(empty, []string{"a"}, []*AggregationFn{count})
+	require.NoError(t, err)
+	require.Empty(t, collect(t, gs, "a", "countofa"))
+	gs.Close()
+}
+
+func TestAggregationFn_overflow(t *testing.T) {
+	tx := recordtest.NewTransaction(100)
+	schema := record.NewSchema()
+	schema.AddIntField("a")
+	ts := newTable(t, tx, "t", schema, 3, func(ts *TableScan, i int) {
+		require.NoError(t, ts.SetInt("a", math.MaxInt32))
+	})
+
+	gs, err := NewGroupByScan(ts, nil, []*AggregationFn{
+		NewAggregationFn(Aggregate_SUM, "a"),
+		NewAggregationFn(Aggregate_AVG, "a"),
+	})
+	require.NoError(t, err)
+	ok, err := gs.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, err = gs.GetVal("sumofa")
+	require.ErrorIs(t, err, ErrOverflow)
+	avg, err := gs.GetVal("avgofa")
+	require.NoError(t, err)
+	require.Equal(t, NewIntConstant(math.MaxInt32), avg)
+	gs.Close()
+}
@@ -0,0 +1,119 @@
+package query
+
+import "simpledb/record"
+
+// SelectScan outputs the records of the underlying scan which satisfy the predicate.
+// It is updatable when the underlying scan is.
+type SelectScan struct {
+	s    Scan
+	pred *Predicate
+}
+
+func NewSelectScan(s Scan, pred *Predicate) *SelectScan {
+	return &SelectScan{
+		s:    s,
+		pred: pred,
+	}
+}
+
+func (ss *SelectScan) BeforeFirst() error {
+	return ss.s.BeforeFirst()
+}
+
+func (ss *SelectScan) Next() (bool, error) {
+	for {
+		ok, err := ss.s.Next()
+		if err != nil || !ok {
+			return false, err
+		}
+		ok, err = ss.pred.IsSatisfied(ss.s)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+}
+
+func (ss *SelectScan) GetInt(field string) (int32, error) {
+	return ss.s.GetInt(field)
+}
+
+func (ss *SelectScan) GetString(field string) (string, error) {
+	return ss.s.GetString(field)
+}
+
+func (ss *SelectScan) GetVal(field string) (Constant, error) {
+	return ss.s.GetVal(field)
+}
+
+func (ss *SelectScan) HasField(field string) bool {
+	return ss.s.HasField(field)
+}
+
+func (ss *SelectScan) Close() {
+	ss.s.Close()
+}
+
+func (ss *SelectScan) SetInt(field string, val int32) error {
+	us, err := ss.updateScan()
+	if err != nil {
+		return err
+	}
+	return us.SetInt(field, val)
+}
+
+func (ss *SelectScan) SetString(field string, val string) error {
+	us, err := ss.updateScan()
+	if err != nil {
+		return err
+	}
+	return us.SetString(field, val)
+}
+
+func (ss *SelectScan) SetVal(field string, val Constant) error {
+	us, err := ss.updateScan()
+	if err != nil {
+		return err
+	}
+	return us.SetVal(field, val)
+}
+
+func (ss *SelectScan) Insert() error {
+	us, err := ss.updateScan()
+	if err != nil {
+		return err
+	}
+	return us.Insert()
+}
+
+func (ss *SelectScan) Delete() error {
+	us, err := ss.updateScan()
+	if err != nil {
+		return err
+	}
+	return us.Delete()
+}
+
+// GetRID returns the identifier of the current record.
+// It panics if the underlying scan is not updatable.
+func (ss *SelectScan) GetRID() record.RID {
+	return ss.s.(UpdateScan).GetRID()
+}
+
+func (ss *SelectScan) MoveToRID(rid record.RID) error {
+	us, err := ss.updateScan()
+	if err != nil {
+		return err
+	}
+	return us.MoveToRID(rid)
+}
+
+func (ss *SelectScan) updateScan() (UpdateScan, error) {
+	us, ok := ss.s.(UpdateScan)
+	if !ok {
+		return nil, ErrNotUpdatable
+	}
+	return us, nil
+}
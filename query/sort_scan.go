@@ -0,0 +1,152 @@
+package query
+
+import "simpledb/record"
+
+// SortScan merges runs, each sorted on the fields, into a single sorted output.
+// Its position can be saved and restored, as a merge join does for groups of equal keys.
+type SortScan struct {
+	runs    []UpdateScan
+	fields  []string
+	hasmore []bool
+	current int
+	saved   *sortPosition
+}
+
+// sortPosition is the head of every run along with the run holding the current record
+type sortPosition struct {
+	rids    []record.RID
+	hasmore []bool
+	current int
+}
+
+// NewSortScan positions the scan before its first record
+func NewSortScan(runs []UpdateScan, fields []string) (*SortScan, error) {
+	ss := &SortScan{
+		runs:    runs,
+		fields:  fields,
+		hasmore: make([]bool, len(runs)),
+	}
+	err := ss.BeforeFirst()
+	if err != nil {
+		ss.Close()
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (ss *SortScan) BeforeFirst() error {
+	for i, run := range ss.runs {
+		err := run.BeforeFirst()
+		if err != nil {
+			return err
+		}
+		ss.hasmore[i], err = run.Next()
+		if err != nil {
+			return err
+		}
+	}
+	ss.current = -1
+	return nil
+}
+
+// Next advances the run holding the current record and moves to the smallest head among the runs
+func (ss *SortScan) Next() (bool, error) {
+	if ss.current >= 0 {
+		var err error
+		ss.hasmore[ss.current], err = ss.runs[ss.current].Next()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	ss.current = -1
+	for i, run := range ss.runs {
+		if !ss.hasmore[i] {
+			continue
+		}
+		if ss.current < 0 {
+			ss.current = i
+			continue
+		}
+		c, err := CompareRecords(run, ss.runs[ss.current], ss.fields)
+		if err != nil {
+			return false, err
+		}
+		if c < 0 {
+			ss.current = i
+		}
+	}
+	return ss.current >= 0, nil
+}
+
+// SavePosition remembers the current record so that RestorePosition can return to it
+func (ss *SortScan) SavePosition() {
+	pos := &sortPosition{
+		rids:    make([]record.RID, len(ss.runs)),
+		hasmore: append([]bool{}, ss.hasmore...),
+		current: ss.current,
+	}
+	for i, run := range ss.runs {
+		if ss.hasmore[i] {
+			pos.rids[i] = run.GetRID()
+		}
+	}
+	ss.saved = pos
+}
+
+// RestorePosition moves back to the record remembered by SavePosition
+func (ss *SortScan) RestorePosition() error {
+	pos := ss.saved
+	for i, run := range ss.runs {
+		if !pos.hasmore[i] {
+			continue
+		}
+		err := run.MoveToRID(pos.rids[i])
+		if err != nil {
+			return err
+		}
+	}
+	copy(ss.hasmore, pos.hasmore)
+	ss.current = pos.current
+	return nil
+}
+
+func (ss *SortScan) GetInt(field string) (int32, error) {
+	return ss.runs[ss.current].GetInt(field)
+}
+
+func (ss *SortScan) GetString(field string) (string, error) {
+	return ss.runs[ss.current].GetString(field)
+}
+
+func (ss *SortScan) GetVal(field string) (Constant, error) {
+	return ss.runs[ss.current].GetVal(field)
+}
+
+func (ss *SortScan) HasField(field string) bool {
+	return len(ss.runs) > 0 && ss.runs[0].HasField(field)
+}
+
+func (ss *SortScan) Close() {
+	for _, run := range ss.runs {
+		run.Close()
+	}
+}
+
+// CompareRecords compares the current records of the scans on the fields, in order
+func CompareRecords(s1, s2 Scan, fields []string) (int, error) {
+	for _, field := range fields {
+		v1, err := s1.GetVal(field)
+		if err != nil {
+			return 0, err
+		}
+		v2, err := s2.GetVal(field)
+		if err != nil {
+			return 0, err
+		}
+		if c := v1.Compare(v2); c != 0 {
+			return c, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,37 @@
+package query
+
+import "simpledb/record"
+
+// TableScan is a record.TableScan which also reads and writes Constants
+type TableScan struct {
+	*record.TableScan
+	layout *record.Layout
+}
+
+// NewTableScan opens the table and positions the scan before its first record
+func NewTableScan(tx record.Transaction, tblname string, layout *record.Layout) (*TableScan, error) {
+	ts, err := record.NewTableScan(tx, tblname, layout)
+	if err != nil {
+		return nil, err
+	}
+	return &TableScan{
+		TableScan: ts,
+		layout:    layout,
+	}, nil
+}
+
+func (ts *TableScan) GetVal(field string) (Constant, error) {
+	if ts.layout.Schema().Type(field) == record.FieldType_INTEGER {
+		n, err := ts.GetInt(field)
+		return NewIntConstant(n), err
+	}
+	s, err := ts.GetString(field)
+	return NewStringConstant(s), err
+}
+
+func (ts *TableScan) SetVal(field string, val Constant) error {
+	if ts.layout.Schema().Type(field) == record.FieldType_INTEGER {
+		return ts.SetInt(field, val.AsInt())
+	}
+	return ts.SetString(field, val.AsString())
+}
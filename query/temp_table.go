@@ -0,0 +1,90 @@
+package query
+
+import (
+	"fmt"
+	"simpledb/record"
+	"simpledb/storage"
+	"sync/atomic"
+)
+
+// TempTablePrefix starts the name of every temporary table.
+// The file manager removes the files of temporary tables left behind by a previous process.
+const TempTablePrefix = storage.TempFilePrefix
+
+var nextTempTable atomic.Int64
+
+// TempTable is a table which holds intermediate results, such as materialized scans and sorted runs.
+// It is not registered in the catalog, and its name tempN is unique within the process.
+// Its file is removed by Remove, or by closing the scan returned by OpenOwned.
+type TempTable struct {
+	tx      record.Transaction
+	tblname string
+	layout  *record.Layout
+}
+
+func NewTempTable(tx record.Transaction, schema *record.Schema) *TempTable {
+	return &TempTable{
+		tx:      tx,
+		tblname: fmt.Sprintf("%s%d", TempTablePrefix, nextTempTable.Add(1)),
+		layout:  record.NewLayout(schema),
+	}
+}
+
+// Open returns an updatable scan over the table
+func (tt *TempTable) Open() (*TableScan, error) {
+	return NewTableScan(tt.tx, tt.tblname, tt.layout)
+}
+
+// OpenOwned returns an updatable scan over the table which removes the table once it is closed,
+// for a table which is read by this scan alone
+func (tt *TempTable) OpenOwned() (UpdateScan, error) {
+	ts, err := tt.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &ownedScan{TableScan: ts, tt: tt}, nil
+}
+
+// Remove deletes the file of the table. No scan of the table may be open.
+func (tt *TempTable) Remove() error {
+	return tt.tx.Remove(tt.tblname + ".tbl")
+}
+
+func (tt *TempTable) TableName() string {
+	return tt.tblname
+}
+
+func (tt *TempTable) Layout() *record.Layout {
+	return tt.layout
+}
+
+// ownedScan is a scan which removes its table when it is closed
+type ownedScan struct {
+	*TableScan
+	tt *TempTable
+}
+
+func (s *ownedScan) Close() {
+	s.TableScan.Close()
+	// the file is left for the next startup to remove if it cannot be removed now
+	_ = s.tt.Remove()
+}
+
+// CopyRecord inserts a record into dest holding the values of the fields of the current record of src
+func CopyRecord(src Scan, dest UpdateScan, fields []string) error {
+	err := dest.Insert()
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		val, err := src.GetVal(field)
+		if err != nil {
+			return err
+		}
+		err = dest.SetVal(field, val)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package query
+
+import "simpledb/record"
+
+// Operator is the comparison a term makes between its expressions
+type Operator int
+
+const (
+	Operator_EQ Operator = iota
+	Operator_NE
+	Operator_LT
+	Operator_LE
+	Operator_GT
+	Operator_GE
+)
+
+var operatorText = [...]string{
+	Operator_EQ: "=",
+	Operator_NE: "<>",
+	Operator_LT: "<",
+	Operator_LE: "<=",
+	Operator_GT: ">",
+	Operator_GE: ">=",
+}
+
+func (op Operator) String() string {
+	return operatorText[op]
+}
+
+// flip returns the operator which compares the same way with the expressions swapped
+func (op Operator) flip() Operator {
+	switch op {
+	case Operator_LT:
+		return Operator_GT
+	case Operator_LE:
+		return Operator_GE
+	case Operator_GT:
+		return Operator_LT
+	case Operator_GE:
+		return Operator_LE
+	default:
+		return op
+	}
+}
+
+// Term is a comparison of two expressions
+type Term struct {
+	lhs *Expression
+	op  Operator
+	rhs *Expression
+}
+
+// NewTerm returns a term comparing the expressions for equality
+func NewTerm(lhs, rhs *Expression) *Term {
+	return NewComparisonTerm(lhs, Operator_EQ, rhs)
+}
+
+func NewComparisonTerm(lhs *Expression, op Operator, rhs *Expression) *Term {
+	return &Term{
+		lhs: lhs,
+		op:  op,
+		rhs: rhs,
+	}
+}
+
+func (t *Term) LHS() *Expression {
+	return t.lhs
+}
+
+func (t *Term) Operator() Operator {
+	return t.op
+}
+
+func (t *Term) RHS() *Expression {
+	return t.rhs
+}
+
+func (t *Term) String() string {
+	return t.lhs.String() + " " + t.op.String() + " " + t.rhs.String()
+}
+
+// IsSatisfied returns true if the values of the expressions for the current record of the scan
+// compare as the operator says
+func (t *Term) IsSatisfied(s Scan) (bool, error) {
+	lval, err := t.lhs.Evaluate(s)
+	if err != nil {
+		return false, err
+	}
+	rval, err := t.rhs.Evaluate(s)
+	if err != nil {
+		return false, err
+	}
+	cmp := lval.Compare(rval)
+	switch t.op {
+	case Operator_NE:
+		return cmp != 0, nil
+	case Operator_LT:
+		return cmp < 0, nil
+	case Operator_LE:
+		return cmp <= 0, nil
+	case Operator_GT:
+		return cmp > 0, nil
+	case Operator_GE:
+		return cmp >= 0, nil
+	default:
+		return cmp == 0, nil
+	}
+}
+
+// AppliesTo returns true if both expressions apply to the schema
+func (t *Term) AppliesTo(schema *record.Schema) bool {
+	return t.lhs.AppliesTo(schema) && t.rhs.AppliesTo(schema)
+}
+
+// EquatesWithConstant returns the constant if the term is of the form "field = c" or "c = field"
+func (t *Term) EquatesWithConstant(fldname string) (Constant, bool) {
+	if t.op != Operator_EQ {
+		return Constant{}, false
+	}
+	return t.comparesWithConstant(fldname)
+}
+
+// EquatesWithField returns the other field if the term is of the form "field = other" or "other = field"
+func (t *Term) EquatesWithField(fldname string) (string, bool) {
+	if t.op != Operator_EQ {
+		return "", false
+	}
+	switch {
+	case t.lhs.IsFieldName() && t.lhs.AsFieldName() == fldname && t.rhs.IsFieldName():
+		return t.rhs.AsFieldName(), true
+	case t.rhs.IsFieldName() && t.rhs.AsFieldName() == fldname && t.lhs.IsFieldName():
+		return t.lhs.AsFieldName(), true
+	default:
+		return "", false
+	}
+}
+
+// Bounds returns the lowest and highest values of the field which may satisfy the term,
+// or nil when the term does not bound the field from that side.
+// Both bounds are inclusive: "field > c" yields the lower bound c.
+func (t *Term) Bounds(fldname string) (lo, hi *Constant) {
+	c, ok := t.comparesWithConstant(fldname)
+	if !ok {
+		return nil, nil
+	}
+	op := t.op
+	if !t.lhs.IsFieldName() {
+		op = op.flip()
+	}
+	switch op {
+	case Operator_EQ:
+		return &c, &c
+	case Operator_LT, Operator_LE:
+		return nil, &c
+	case Operator_GT, Operator_GE:
+		return &c, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (t *Term) comparesWithConstant(fldname string) (Constant, bool) {
+	switch {
+	case t.lhs.IsFieldName() && t.lhs.AsFieldName() == fldname && !t.rhs.IsFieldName():
+		return t.rhs.AsConstant(), true
+	case t.rhs.IsFieldName() && t.rhs.AsFieldName() == fldname && !t.lhs.IsFieldName():
+		return t.lhs.AsConstant(), true
+	default:
+		return Constant{}, false
+	}
+}
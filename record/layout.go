@@ -0,0 +1,60 @@
+package record
+
+import "simpledb/storage"
+
+// Layout describes where each field of a record lives inside a slot.
+// Every slot starts with an int32 flag telling whether it is empty or used.
+type Layout struct {
+	schema   *Schema
+	offsets  map[string]int
+	slotsize int
+}
+
+// NewLayout computes the field offsets and the slot size of the schema
+func NewLayout(schema *Schema) *Layout {
+	offsets := make(map[string]int)
+	pos := 4 // the empty/used flag
+	for _, name := range schema.Fields() {
+		offsets[name] = pos
+		pos += lengthInBytes(schema, name)
+	}
+
+	return &Layout{
+		schema:   schema,
+		offsets:  offsets,
+		slotsize: pos,
+	}
+}
+
+// NewLayoutFromMetadata creates a Layout from offsets which were computed before
+func NewLayoutFromMetadata(schema *Schema, offsets map[string]int, slotsize int) *Layout {
+	return &Layout{
+		schema:   schema,
+		offsets:  offsets,
+		slotsize: slotsize,
+	}
+}
+
+func (l *Layout) Schema() *Schema {
+	return l.schema
+}
+
+// Offset returns the position of the field relative to the beginning of the slot
+func (l *Layout) Offset(name string) int {
+	return l.offsets[name]
+}
+
+// SlotSize returns the size of a slot in bytes
+func (l *Layout) SlotSize() int {
+	return l.slotsize
+}
+
+func lengthInBytes(schema *Schema, name string) int {
+	switch schema.Type(name) {
+	case FieldType_VARCHAR:
+		var p storage.Page
+		return 4 + p.MaxLen(schema.Length(name))
+	default:
+		return 4
+	}
+}
@@ -0,0 +1,21 @@
+package record
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLayout(t *testing.T) {
+	schema := NewSchema()
+	schema.AddIntField("A")
+	schema.AddStringField("B", 9)
+	schema.AddIntField("C")
+
+	layout := NewLayout(schema)
+	require.Equal(t, 4, layout.Offset("A"))
+	require.Equal(t, 8, layout.Offset("B"))
+	require.Equal(t, 21, layout.Offset("C"))
+	require.Equal(t, 25, layout.SlotSize())
+	require.Equal(t, []string{"A", "B", "C"}, layout.Schema().Fields())
+}
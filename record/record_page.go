@@ -0,0 +1,172 @@
+package record
+
+import (
+	"errors"
+	"simpledb/storage"
+)
+
+const (
+	SlotFlag_EMPTY int32 = iota
+	SlotFlag_USED
+)
+
+var (
+	ErrSlotOutOfRange = errors.New("slot out of range")
+)
+
+// Transaction is the part of a transaction the record layer relies on
+type Transaction interface {
+	Pin(block *storage.Block) error
+	Unpin(block *storage.Block)
+	GetInt32(block *storage.Block, offset int) (int32, error)
+	GetString(block *storage.Block, offset int) (string, error)
+	SetInt32(block *storage.Block, offset int, n int32) error
+	SetString(block *storage.Block, offset int, v string) error
+	Size(filename string) (int, error)
+	Append(filename string) (*storage.Block, error)
+	// Remove deletes a temporary file whose blocks are unpinned
+	Remove(filename string) error
+	BlockSize() int
+	// AvailableBuffs returns the number of buffers which are not pinned
+	AvailableBuffs() int
+}
+
+// RecordPage stores fixed-length records in the slots of a block.
+// The block stays pinned until Close is called.
+type RecordPage struct {
+	tx     Transaction
+	block  *storage.Block
+	layout *Layout
+}
+
+// NewRecordPage pins the block and returns a RecordPage over it
+func NewRecordPage(tx Transaction, block *storage.Block, layout *Layout) (*RecordPage, error) {
+	err := tx.Pin(block)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordPage{
+		tx:     tx,
+		block:  block,
+		layout: layout,
+	}, nil
+}
+
+func (rp *RecordPage) Block() *storage.Block {
+	return rp.block
+}
+
+// Close unpins the block
+func (rp *RecordPage) Close() {
+	rp.tx.Unpin(rp.block)
+}
+
+// GetInt returns the integer value of the field in the slot
+func (rp *RecordPage) GetInt(slot int, field string) (int32, error) {
+	if !rp.isValidSlot(slot) {
+		return 0, ErrSlotOutOfRange
+	}
+	return rp.tx.GetInt32(rp.block, rp.offset(slot)+rp.layout.Offset(field))
+}
+
+// GetString returns the string value of the field in the slot
+func (rp *RecordPage) GetString(slot int, field string) (string, error) {
+	if !rp.isValidSlot(slot) {
+		return "", ErrSlotOutOfRange
+	}
+	return rp.tx.GetString(rp.block, rp.offset(slot)+rp.layout.Offset(field))
+}
+
+// SetInt stores an integer into the field in the slot
+func (rp *RecordPage) SetInt(slot int, field string, val int32) error {
+	if !rp.isValidSlot(slot) {
+		return ErrSlotOutOfRange
+	}
+	return rp.tx.SetInt32(rp.block, rp.offset(slot)+rp.layout.Offset(field), val)
+}
+
+// SetString stores a string into the field in the slot
+func (rp *RecordPage) SetString(slot int, field string, val string) error {
+	if !rp.isValidSlot(slot) {
+		return ErrSlotOutOfRange
+	}
+	return rp.tx.SetString(rp.block, rp.offset(slot)+rp.layout.Offset(field), val)
+}
+
+// Delete marks the slot as empty
+func (rp *RecordPage) Delete(slot int) error {
+	return rp.setFlag(slot, SlotFlag_EMPTY)
+}
+
+// Format empties every slot of the block and zeroes its fields
+func (rp *RecordPage) Format() error {
+	schema := rp.layout.Schema()
+	for slot := 0; rp.isValidSlot(slot); slot++ {
+		err := rp.setFlag(slot, SlotFlag_EMPTY)
+		if err != nil {
+			return err
+		}
+		for _, field := range schema.Fields() {
+			switch schema.Type(field) {
+			case FieldType_INTEGER:
+				err = rp.SetInt(slot, field, 0)
+			case FieldType_VARCHAR:
+				err = rp.SetString(slot, field, "")
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NextAfter returns the first used slot after the given one, or -1 if there is none.
+// Pass -1 to search from the beginning of the block.
+func (rp *RecordPage) NextAfter(slot int) (int, error) {
+	return rp.searchAfter(slot, SlotFlag_USED)
+}
+
+// InsertAfter finds the first empty slot after the given one, marks it as used and returns it.
+// It returns -1 if the block is full.
+func (rp *RecordPage) InsertAfter(slot int) (int, error) {
+	next, err := rp.searchAfter(slot, SlotFlag_EMPTY)
+	if err != nil {
+		return -1, err
+	}
+	if next >= 0 {
+		err = rp.setFlag(next, SlotFlag_USED)
+		if err != nil {
+			return -1, err
+		}
+	}
+	return next, nil
+}
+
+func (rp *RecordPage) searchAfter(slot int, flag int32) (int, error) {
+	for slot++; rp.isValidSlot(slot); slot++ {
+		got, err := rp.tx.GetInt32(rp.block, rp.offset(slot))
+		if err != nil {
+			return -1, err
+		}
+		if got == flag {
+			return slot, nil
+		}
+	}
+	return -1, nil
+}
+
+func (rp *RecordPage) setFlag(slot int, flag int32) error {
+	if !rp.isValidSlot(slot) {
+		return ErrSlotOutOfRange
+	}
+	return rp.tx.SetInt32(rp.block, rp.offset(slot), flag)
+}
+
+func (rp *RecordPage) isValidSlot(slot int) bool {
+	return slot >= 0 && rp.offset(slot+1) <= rp.tx.BlockSize()
+}
+
+func (rp *RecordPage) offset(slot int) int {
+	return slot * rp.layout.SlotSize()
+}
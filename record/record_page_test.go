@@ -0,0 +1,65 @@
+package record
+
+import (
+	"simpledb/record/recordtest"
+	"simpledb/storage"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordPage(t *testing.T) {
+	schema := NewSchema()
+	schema.AddIntField("A")
+	schema.AddStringField("B", 9)
+	layout := NewLayout(schema) // 21 bytes per slot
+
+	tx := recordtest.NewTransaction(100)
+	block := storage.NewBlock("testfile", 0)
+	rp, err := NewRecordPage(tx, block, layout)
+	require.NoError(t, err)
+	require.Equal(t, 1, tx.Pins(block))
+	require.NoError(t, rp.Format())
+
+	// fill every slot
+	slots := []int{}
+	slot, err := rp.InsertAfter(-1)
+	require.NoError(t, err)
+	for slot >= 0 {
+		require.NoError(t, rp.SetInt(slot, "A", int32(slot*10)))
+		require.NoError(t, rp.SetString(slot, "B", "rec"))
+		slots = append(slots, slot)
+		slot, err = rp.InsertAfter(slot)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []int{0, 1, 2, 3}, slots)
+
+	require.NoError(t, rp.Delete(1))
+	require.NoError(t, rp.Delete(2))
+
+	got := []int32{}
+	slot, err = rp.NextAfter(-1)
+	require.NoError(t, err)
+	for slot >= 0 {
+		n, err := rp.GetInt(slot, "A")
+		require.NoError(t, err)
+		s, err := rp.GetString(slot, "B")
+		require.NoError(t, err)
+		require.Equal(t, "rec", s)
+		got = append(got, n)
+		slot, err = rp.NextAfter(slot)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []int32{0, 30}, got)
+
+	// deleted slots are reused
+	slot, err = rp.InsertAfter(-1)
+	require.NoError(t, err)
+	require.Equal(t, 1, slot)
+
+	_, err = rp.GetInt(4, "A")
+	require.ErrorIs(t, err, ErrSlotOutOfRange)
+
+	rp.Close()
+	require.Equal(t, 0, tx.Pins(block))
+}
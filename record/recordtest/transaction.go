@@ -0,0 +1,132 @@
+// Package recordtest provides an in-memory transaction for testing the layers built on top of records.
+package recordtest
+
+import (
+	"simpledb/storage"
+	"slices"
+)
+
+// DefaultAvailableBuffs is the number of buffers a new Transaction reports as available
+const DefaultAvailableBuffs = 8
+
+// Transaction keeps its blocks in memory and ignores locking and logging
+type Transaction struct {
+	blocksize int
+	pages     map[storage.Block]*storage.Page
+	pins      map[storage.Block]int
+	sizes     map[string]int
+	available int
+	// peak is the largest number of blocks pinned at once
+	peak int
+}
+
+func NewTransaction(blocksize int) *Transaction {
+	return &Transaction{
+		blocksize: blocksize,
+		pages:     make(map[storage.Block]*storage.Page),
+		pins:      make(map[storage.Block]int),
+		sizes:     make(map[string]int),
+		available: DefaultAvailableBuffs,
+	}
+}
+
+func (tx *Transaction) page(block *storage.Block) *storage.Page {
+	p, found := tx.pages[*block]
+	if !found {
+		p = storage.NewPage(tx.blocksize)
+		tx.pages[*block] = p
+	}
+	return p
+}
+
+func (tx *Transaction) Pin(block *storage.Block) error {
+	tx.pins[*block]++
+	tx.peak = max(tx.peak, len(tx.Pinned()))
+	return nil
+}
+
+func (tx *Transaction) Unpin(block *storage.Block) {
+	tx.pins[*block]--
+}
+
+// Pins returns the number of pins currently held on the block
+func (tx *Transaction) Pins(block *storage.Block) int {
+	return tx.pins[*block]
+}
+
+// Pinned returns the blocks which are still pinned
+func (tx *Transaction) Pinned() []storage.Block {
+	blocks := []storage.Block{}
+	for block, n := range tx.pins {
+		if n != 0 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// PeakPinned returns the largest number of blocks which have been pinned at once
+func (tx *Transaction) PeakPinned() int {
+	return tx.peak
+}
+
+func (tx *Transaction) GetInt32(block *storage.Block, offset int) (int32, error) {
+	return tx.page(block).GetInt32(offset)
+}
+
+func (tx *Transaction) GetString(block *storage.Block, offset int) (string, error) {
+	return tx.page(block).GetString(offset)
+}
+
+func (tx *Transaction) SetInt32(block *storage.Block, offset int, n int32) error {
+	return tx.page(block).SetInt32(offset, n)
+}
+
+func (tx *Transaction) SetString(block *storage.Block, offset int, v string) error {
+	return tx.page(block).SetString(offset, v)
+}
+
+func (tx *Transaction) Size(filename string) (int, error) {
+	return tx.sizes[filename], nil
+}
+
+func (tx *Transaction) Append(filename string) (*storage.Block, error) {
+	block := storage.NewBlock(filename, tx.sizes[filename])
+	tx.sizes[filename]++
+	return block, nil
+}
+
+func (tx *Transaction) Remove(filename string) error {
+	for block := range tx.pages {
+		if block.Filename == filename {
+			delete(tx.pages, block)
+		}
+	}
+	delete(tx.sizes, filename)
+	return nil
+}
+
+// Files returns the names of the files which have blocks
+func (tx *Transaction) Files() []string {
+	files := []string{}
+	for filename, size := range tx.sizes {
+		if size > 0 {
+			files = append(files, filename)
+		}
+	}
+	slices.Sort(files)
+	return files
+}
+
+func (tx *Transaction) BlockSize() int {
+	return tx.blocksize
+}
+
+func (tx *Transaction) AvailableBuffs() int {
+	return tx.available
+}
+
+// SetAvailableBuffs changes the number of buffers reported by AvailableBuffs
+func (tx *Transaction) SetAvailableBuffs(n int) {
+	tx.available = n
+}
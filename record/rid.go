@@ -0,0 +1,20 @@
+package record
+
+import "fmt"
+
+// RID identifies a record by its block number and slot
+type RID struct {
+	BlockNum int
+	Slot     int
+}
+
+func NewRID(blknum, slot int) RID {
+	return RID{
+		BlockNum: blknum,
+		Slot:     slot,
+	}
+}
+
+func (r RID) String() string {
+	return fmt.Sprintf("[%d, %d]", r.BlockNum, r.Slot)
+}
@@ -0,0 +1,79 @@
+package record
+
+type FieldType int
+
+const (
+	FieldType_INTEGER FieldType = iota
+	FieldType_VARCHAR
+)
+
+type FieldInfo struct {
+	Type   FieldType
+	Length int
+}
+
+// Schema holds the names and types of the fields of a table.
+// The length of a varchar field is its maximum number of characters.
+type Schema struct {
+	fields []string
+	info   map[string]FieldInfo
+}
+
+// NewSchema creates an empty Schema
+func NewSchema() *Schema {
+	return &Schema{
+		fields: []string{},
+		info:   make(map[string]FieldInfo),
+	}
+}
+
+// AddField adds a field with the given type and length
+func (s *Schema) AddField(name string, typ FieldType, length int) {
+	if _, found := s.info[name]; !found {
+		s.fields = append(s.fields, name)
+	}
+	s.info[name] = FieldInfo{Type: typ, Length: length}
+}
+
+// AddIntField adds an integer field
+func (s *Schema) AddIntField(name string) {
+	s.AddField(name, FieldType_INTEGER, 0)
+}
+
+// AddStringField adds a varchar field which holds up to length characters
+func (s *Schema) AddStringField(name string, length int) {
+	s.AddField(name, FieldType_VARCHAR, length)
+}
+
+// Add copies the field from the other schema
+func (s *Schema) Add(name string, other *Schema) {
+	s.AddField(name, other.Type(name), other.Length(name))
+}
+
+// AddAll copies every field from the other schema
+func (s *Schema) AddAll(other *Schema) {
+	for _, name := range other.Fields() {
+		s.Add(name, other)
+	}
+}
+
+// Fields returns the field names in the order they were added
+func (s *Schema) Fields() []string {
+	return s.fields
+}
+
+// HasField returns true if the schema has the field
+func (s *Schema) HasField(name string) bool {
+	_, found := s.info[name]
+	return found
+}
+
+// Type returns the type of the field
+func (s *Schema) Type(name string) FieldType {
+	return s.info[name].Type
+}
+
+// Length returns the declared length of the field
+func (s *Schema) Length(name string) int {
+	return s.info[name].Length
+}
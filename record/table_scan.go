@@ -0,0 +1,184 @@
+package record
+
+import "simpledb/storage"
+
+// TableScan iterates over the records of a table stored in the file <table>.tbl
+type TableScan struct {
+	tx          Transaction
+	layout      *Layout
+	filename    string
+	rp          *RecordPage
+	currentSlot int
+}
+
+// NewTableScan opens the table and positions the scan before its first record
+func NewTableScan(tx Transaction, tblname string, layout *Layout) (*TableScan, error) {
+	ts := &TableScan{
+		tx:       tx,
+		layout:   layout,
+		filename: tblname + ".tbl",
+	}
+
+	size, err := tx.Size(ts.filename)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		err = ts.moveToNewBlock()
+	} else {
+		err = ts.moveToBlock(0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// BeforeFirst positions the scan before the first record
+func (ts *TableScan) BeforeFirst() error {
+	return ts.moveToBlock(0)
+}
+
+// Next moves to the next record and returns false when there is none
+func (ts *TableScan) Next() (bool, error) {
+	var err error
+	ts.currentSlot, err = ts.rp.NextAfter(ts.currentSlot)
+	if err != nil {
+		return false, err
+	}
+	for ts.currentSlot < 0 {
+		last, err := ts.atLastBlock()
+		if err != nil {
+			return false, err
+		}
+		if last {
+			return false, nil
+		}
+		err = ts.moveToBlock(ts.rp.Block().Num + 1)
+		if err != nil {
+			return false, err
+		}
+		ts.currentSlot, err = ts.rp.NextAfter(ts.currentSlot)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (ts *TableScan) GetInt(field string) (int32, error) {
+	return ts.rp.GetInt(ts.currentSlot, field)
+}
+
+func (ts *TableScan) GetString(field string) (string, error) {
+	return ts.rp.GetString(ts.currentSlot, field)
+}
+
+func (ts *TableScan) HasField(field string) bool {
+	return ts.layout.Schema().HasField(field)
+}
+
+// Close unpins the current block
+func (ts *TableScan) Close() {
+	if ts.rp != nil {
+		ts.rp.Close()
+		ts.rp = nil
+	}
+}
+
+func (ts *TableScan) SetInt(field string, val int32) error {
+	return ts.rp.SetInt(ts.currentSlot, field, val)
+}
+
+func (ts *TableScan) SetString(field string, val string) error {
+	return ts.rp.SetString(ts.currentSlot, field, val)
+}
+
+// Insert moves to an empty slot, appending a block to the file if necessary, and marks it as used
+func (ts *TableScan) Insert() error {
+	var err error
+	ts.currentSlot, err = ts.rp.InsertAfter(ts.currentSlot)
+	if err != nil {
+		return err
+	}
+	for ts.currentSlot < 0 {
+		last, err := ts.atLastBlock()
+		if err != nil {
+			return err
+		}
+		if last {
+			err = ts.moveToNewBlock()
+		} else {
+			err = ts.moveToBlock(ts.rp.Block().Num + 1)
+		}
+		if err != nil {
+			return err
+		}
+		ts.currentSlot, err = ts.rp.InsertAfter(ts.currentSlot)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete marks the current record as deleted
+func (ts *TableScan) Delete() error {
+	return ts.rp.Delete(ts.currentSlot)
+}
+
+// MoveToRID positions the scan at the record
+func (ts *TableScan) MoveToRID(rid RID) error {
+	ts.Close()
+	rp, err := NewRecordPage(ts.tx, storage.NewBlock(ts.filename, rid.BlockNum), ts.layout)
+	if err != nil {
+		return err
+	}
+	ts.rp = rp
+	ts.currentSlot = rid.Slot
+	return nil
+}
+
+// GetRID returns the identifier of the current record
+func (ts *TableScan) GetRID() RID {
+	return NewRID(ts.rp.Block().Num, ts.currentSlot)
+}
+
+func (ts *TableScan) moveToBlock(blknum int) error {
+	ts.Close()
+	rp, err := NewRecordPage(ts.tx, storage.NewBlock(ts.filename, blknum), ts.layout)
+	if err != nil {
+		return err
+	}
+	ts.rp = rp
+	ts.currentSlot = -1
+	return nil
+}
+
+func (ts *TableScan) moveToNewBlock() error {
+	ts.Close()
+	block, err := ts.tx.Append(ts.filename)
+	if err != nil {
+		return err
+	}
+	rp, err := NewRecordPage(ts.tx, block, ts.layout)
+	if err != nil {
+		return err
+	}
+	err = rp.Format()
+	if err != nil {
+		rp.Close()
+		return err
+	}
+	ts.rp = rp
+	ts.currentSlot = -1
+	return nil
+}
+
+func (ts *TableScan) atLastBlock() (bool, error) {
+	size, err := ts.tx.Size(ts.filename)
+	if err != nil {
+		return false, err
+	}
+	return ts.rp.Block().Num == size-1, nil
+}
@@ -0,0 +1,78 @@
+package record
+
+import (
+	"fmt"
+	"simpledb/record/recordtest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableScan(t *testing.T) {
+	schema := NewSchema()
+	schema.AddIntField("A")
+	schema.AddStringField("B", 9)
+	layout := NewLayout(schema) // 4 slots per block
+
+	tx := recordtest.NewTransaction(100)
+	ts, err := NewTableScan(tx, "T", layout)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("A", int32(i)))
+		require.NoError(t, ts.SetString("B", fmt.Sprintf("rec%d", i)))
+	}
+	size, err := tx.Size("T.tbl")
+	require.NoError(t, err)
+	require.Equal(t, 3, size)
+	require.Equal(t, NewRID(2, 1), ts.GetRID())
+
+	// delete the even records
+	require.NoError(t, ts.BeforeFirst())
+	var rid RID
+	for {
+		ok, err := ts.Next()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		n, err := ts.GetInt("A")
+		require.NoError(t, err)
+		if n%2 == 0 {
+			require.NoError(t, ts.Delete())
+		}
+		if n == 5 {
+			rid = ts.GetRID()
+		}
+	}
+
+	got := []string{}
+	require.NoError(t, ts.BeforeFirst())
+	for {
+		ok, err := ts.Next()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+		s, err := ts.GetString("B")
+		require.NoError(t, err)
+		got = append(got, s)
+	}
+	require.Equal(t, []string{"rec1", "rec3", "rec5", "rec7", "rec9"}, got)
+
+	require.NoError(t, ts.MoveToRID(rid))
+	n, err := ts.GetInt("A")
+	require.NoError(t, err)
+	require.Equal(t, int32(5), n)
+
+	// inserting reuses the deleted slots
+	require.NoError(t, ts.BeforeFirst())
+	require.NoError(t, ts.Insert())
+	require.Equal(t, NewRID(0, 0), ts.GetRID())
+	require.True(t, ts.HasField("A"))
+	require.False(t, ts.HasField("C"))
+
+	ts.Close()
+	require.Empty(t, tx.Pinned())
+}
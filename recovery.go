@@ -0,0 +1,136 @@
+package simpledb
+
+import (
+	"encoding/binary"
+	"simpledb/log"
+	logrecord "simpledb/log/record"
+	"simpledb/storage"
+)
+
+// RecoveryManager brings the data files back to a consistent state after a crash.
+// It works on the files directly, so it must run before any buffer is pinned.
+type RecoveryManager struct {
+	fm storage.FileManager
+	lm *log.LogManager
+	// written holds the files changed by the recovery
+	written map[string]struct{}
+}
+
+func NewRecoveryManager(fm storage.FileManager, lm *log.LogManager) *RecoveryManager {
+	return &RecoveryManager{
+		fm:      fm,
+		lm:      lm,
+		written: make(map[string]struct{}),
+	}
+}
+
+// Recover undoes the changes of the transactions which neither committed nor rolled back,
+// redoes the changes of the committed ones and writes a checkpoint record.
+// The changed files are synced first, since the log before the checkpoint is never read again.
+func (rm *RecoveryManager) Recover() error {
+	itr, err := rm.lm.Iterator()
+	if err != nil {
+		return err
+	}
+
+	committed := make(map[int]struct{})
+	finished := make(map[int]struct{})
+	redo := [][]byte{}
+
+	// undo phase: walk the log backward until the last checkpoint
+scan:
+	for itr.HasNext() {
+		data, err := itr.Next()
+		if err != nil {
+			return err
+		}
+		inst := binary.BigEndian.Uint32(data[:4])
+		switch inst {
+		case logrecord.Instruction_CHECKPOINT:
+			break scan
+		case logrecord.Instruction_COMMIT:
+			record := &logrecord.CommitRecord{}
+			record.Read(data)
+			committed[record.TxID] = struct{}{}
+			finished[record.TxID] = struct{}{}
+		case logrecord.Instruction_ROLLBACK:
+			record := &logrecord.RollbackRecord{}
+			record.Read(data)
+			finished[record.TxID] = struct{}{}
+		case logrecord.Instruction_SETINT32, logrecord.Instruction_SETSTRING:
+			txid := int(binary.BigEndian.Uint32(data[4:8]))
+			if _, ok := committed[txid]; ok {
+				redo = append(redo, data)
+				continue
+			}
+			if _, ok := finished[txid]; ok {
+				// rolled back transactions have already undone their changes
+				continue
+			}
+			err = rm.apply(data, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// redo phase: replay the committed changes from the oldest to the newest
+	for i := len(redo) - 1; i >= 0; i-- {
+		err = rm.apply(redo[i], false)
+		if err != nil {
+			return err
+		}
+	}
+
+	for filename := range rm.written {
+		err = rm.fm.Sync(filename)
+		if err != nil {
+			return err
+		}
+	}
+	return rm.lm.Checkpoint()
+}
+
+// apply writes the old value (undo) or the new value (redo) of an update record to its block
+func (rm *RecoveryManager) apply(data []byte, undo bool) error {
+	var block *storage.Block
+	var set func(p *storage.Page) error
+
+	inst := binary.BigEndian.Uint32(data[:4])
+	switch inst {
+	case logrecord.Instruction_SETINT32:
+		record := &logrecord.SetInt32Record{}
+		record.Read(data)
+		block = record.Block()
+		set = func(p *storage.Page) error {
+			if undo {
+				return p.SetInt32(record.Offset, record.OldValue)
+			}
+			return p.SetInt32(record.Offset, record.NewValue)
+		}
+	case logrecord.Instruction_SETSTRING:
+		record := &logrecord.SetStringRecord{}
+		record.Read(data)
+		block = record.Block()
+		set = func(p *storage.Page) error {
+			if undo {
+				return p.SetString(record.Offset, record.OldValue)
+			}
+			return p.SetString(record.Offset, record.NewValue)
+		}
+	default:
+		return nil
+	}
+
+	page := storage.NewPage(rm.fm.Blocksize())
+	err := rm.fm.Read(block, page)
+	if err != nil {
+		return err
+	}
+	err = set(page)
+	if err != nil {
+		return err
+	}
+	rm.written[block.Filename] = struct{}{}
+	return rm.fm.Write(block, page)
+}
@@ -0,0 +1,54 @@
+package simpledb
+
+import (
+	"simpledb/storage"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryManager_Recover(t *testing.T) {
+	dir := t.TempDir()
+	blk0 := storage.NewBlock("recoverytest", 0)
+	blk1 := storage.NewBlock("recoverytest", 1)
+
+	db, err := NewDB(dir, 400, 3)
+	require.NoError(t, err)
+
+	// committed but never flushed
+	tx1, err := db.NewTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx1.SetInt32(blk0, 0, 100))
+	require.NoError(t, tx1.SetString(blk0, 4, "committed"))
+	require.NoError(t, tx1.Commit())
+
+	// flushed but never committed
+	tx2, err := db.NewTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx2.SetInt32(blk1, 0, 200))
+	require.NoError(t, tx2.SetString(blk1, 4, "uncommitted"))
+	require.NoError(t, db.BufferManager.FlushAll(tx2.id))
+
+	// crash and reopen; closing releases the files without flushing the buffers
+	require.NoError(t, db.Close())
+	db, err = NewDB(dir, 400, 3)
+	require.NoError(t, err)
+
+	page := storage.NewPage(400)
+	require.NoError(t, db.fm.Read(blk0, page))
+
+	n, err := page.GetInt32(0)
+	require.NoError(t, err)
+	require.Equal(t, int32(100), n)
+	s, err := page.GetString(4)
+	require.NoError(t, err)
+	require.Equal(t, "committed", s)
+
+	require.NoError(t, db.fm.Read(blk1, page))
+	n, err = page.GetInt32(0)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), n)
+	s, err = page.GetString(4)
+	require.NoError(t, err)
+	require.Equal(t, "", s)
+}
@@ -0,0 +1,226 @@
+package simpledb
+
+import "container/list"
+
+// ReplacementPolicy chooses the buffer whose block is replaced when a block which is not
+// in the pool is pinned. Buffers are identified by their frame, the index in the pool.
+// The buffer manager tells the policy about every pin and about the release of the last pin,
+// and asks for a victim only once every frame holds a block.
+type ReplacementPolicy interface {
+	// Pinned is called whenever the frame is pinned
+	Pinned(frame int)
+	// Unpinned is called when the last pin of the frame is released.
+	// A frame read by a sequential scan is unlikely to be read again soon,
+	// so the policy should replace it before the others.
+	Unpinned(frame int, sequential bool)
+	// Victim returns an unpinned frame and forgets its history, since it is about to hold another block.
+	// It returns false if every frame is pinned.
+	Victim() (int, bool)
+}
+
+// NaivePolicy replaces the first unpinned frame of the pool.
+// Victim scans the frames, so it takes time proportional to the size of the pool.
+type NaivePolicy struct {
+	unpinned []bool
+}
+
+func NewNaivePolicy() *NaivePolicy {
+	return &NaivePolicy{}
+}
+
+func (p *NaivePolicy) Pinned(frame int) {
+	p.unpinned = grow(p.unpinned, frame)
+	p.unpinned[frame] = false
+}
+
+func (p *NaivePolicy) Unpinned(frame int, sequential bool) {
+	p.unpinned = grow(p.unpinned, frame)
+	p.unpinned[frame] = true
+}
+
+func (p *NaivePolicy) Victim() (int, bool) {
+	for frame, unpinned := range p.unpinned {
+		if unpinned {
+			p.unpinned[frame] = false
+			return frame, true
+		}
+	}
+	return 0, false
+}
+
+// LRUPolicy replaces the frame which has been unpinned for the longest time.
+// Every operation takes constant time.
+type LRUPolicy struct {
+	// unpinned lists the unpinned frames from the least to the most recently used
+	unpinned *list.List
+	elems    map[int]*list.Element
+}
+
+func NewLRUPolicy() *LRUPolicy {
+	return &LRUPolicy{
+		unpinned: list.New(),
+		elems:    make(map[int]*list.Element),
+	}
+}
+
+func (p *LRUPolicy) Pinned(frame int) {
+	if e, found := p.elems[frame]; found {
+		p.unpinned.Remove(e)
+		delete(p.elems, frame)
+	}
+}
+
+// Unpinned makes the frame the most recently used, or the least recently used one
+// if it was read by a sequential scan
+func (p *LRUPolicy) Unpinned(frame int, sequential bool) {
+	p.Pinned(frame)
+	if sequential {
+		p.elems[frame] = p.unpinned.PushFront(frame)
+	} else {
+		p.elems[frame] = p.unpinned.PushBack(frame)
+	}
+}
+
+func (p *LRUPolicy) Victim() (int, bool) {
+	e := p.unpinned.Front()
+	if e == nil {
+		return 0, false
+	}
+	frame := p.unpinned.Remove(e).(int)
+	delete(p.elems, frame)
+	return frame, true
+}
+
+// ClockPolicy approximates LRU with a reference bit per frame. The clock hand sweeps the frames,
+// clearing the bits it passes, and stops at the first unpinned frame whose bit is clear.
+type ClockPolicy struct {
+	frames []clockFrame
+	hand   int
+}
+
+type clockFrame struct {
+	unpinned   bool
+	referenced bool
+}
+
+func NewClockPolicy() *ClockPolicy {
+	return &ClockPolicy{}
+}
+
+func (p *ClockPolicy) Pinned(frame int) {
+	p.frames = grow(p.frames, frame)
+	p.frames[frame] = clockFrame{referenced: true}
+}
+
+// Unpinned leaves the reference bit set, unless the frame was read by a sequential scan
+func (p *ClockPolicy) Unpinned(frame int, sequential bool) {
+	p.frames = grow(p.frames, frame)
+	p.frames[frame].unpinned = true
+	if sequential {
+		p.frames[frame].referenced = false
+	}
+}
+
+// Victim sweeps the frames at most twice: the first sweep may clear every reference bit
+func (p *ClockPolicy) Victim() (int, bool) {
+	for range 2 * len(p.frames) {
+		frame := p.hand
+		p.hand = (p.hand + 1) % len(p.frames)
+		f := &p.frames[frame]
+		if !f.unpinned {
+			continue
+		}
+		if f.referenced {
+			f.referenced = false
+			continue
+		}
+		*f = clockFrame{}
+		return frame, true
+	}
+	return 0, false
+}
+
+// LRUKPolicy replaces the unpinned frame whose K-th most recent pin is the oldest.
+// Frames pinned fewer than K times come first, the least recently pinned of them first,
+// so that a block read once does not push out a block read repeatedly.
+// Victim compares every frame, so it takes time proportional to the size of the pool.
+type LRUKPolicy struct {
+	k      int
+	clock  int
+	frames []lruKFrame
+}
+
+type lruKFrame struct {
+	unpinned bool
+	// history holds the times of the last K pins, the most recent last
+	history []int
+}
+
+func NewLRUKPolicy(k int) *LRUKPolicy {
+	return &LRUKPolicy{k: max(k, 1)}
+}
+
+func (p *LRUKPolicy) Pinned(frame int) {
+	p.frames = grow(p.frames, frame)
+	p.clock++
+	f := &p.frames[frame]
+	f.unpinned = false
+	f.history = append(f.history, p.clock)
+	if len(f.history) > p.k {
+		f.history = f.history[1:]
+	}
+}
+
+// Unpinned forgets the history of a frame read by a sequential scan,
+// which makes it the first to be replaced
+func (p *LRUKPolicy) Unpinned(frame int, sequential bool) {
+	p.frames = grow(p.frames, frame)
+	f := &p.frames[frame]
+	f.unpinned = true
+	if sequential {
+		f.history = f.history[:0]
+	}
+}
+
+func (p *LRUKPolicy) Victim() (int, bool) {
+	victim := -1
+	for frame := range p.frames {
+		if p.frames[frame].unpinned && (victim < 0 || p.before(frame, victim)) {
+			victim = frame
+		}
+	}
+	if victim < 0 {
+		return 0, false
+	}
+	p.frames[victim] = lruKFrame{}
+	return victim, true
+}
+
+// before returns true if frame f1 should be replaced before f2
+func (p *LRUKPolicy) before(f1, f2 int) bool {
+	h1, h2 := p.frames[f1].history, p.frames[f2].history
+	full1, full2 := len(h1) == p.k, len(h2) == p.k
+	if full1 != full2 {
+		return !full1
+	}
+	if full1 {
+		return h1[0] < h2[0]
+	}
+	return last(h1) < last(h2)
+}
+
+// last returns the most recent time of the history, 0 if it is empty
+func last(history []int) int {
+	if len(history) == 0 {
+		return 0
+	}
+	return history[len(history)-1]
+}
+
+// grow extends the slice so that it has an element at index i
+func grow[T any](s []T, i int) []T {
+	if i < len(s) {
+		return s
+	}
+	return append(s, make([]T, i+1-len(s))...)
+}
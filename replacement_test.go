@@ -0,0 +1,122 @@
+package simpledb
+
+import (
+	"simpledb/log"
+	"simpledb/storage"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// victims returns the frames the policy replaces until every frame is taken
+func victims(p ReplacementPolicy) []int {
+	frames := []int{}
+	for {
+		frame, ok := p.Victim()
+		if !ok {
+			return frames
+		}
+		frames = append(frames, frame)
+	}
+}
+
+func TestReplacementPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     func() ReplacementPolicy
+		victims    []int
+		sequential []int
+	}{
+		{
+			name:       "naive",
+			policy:     func() ReplacementPolicy { return NewNaivePolicy() },
+			victims:    []int{0, 1, 2, 3},
+			sequential: []int{0, 1},
+		},
+		{
+			name:       "lru",
+			policy:     func() ReplacementPolicy { return NewLRUPolicy() },
+			victims:    []int{2, 3, 1, 0},
+			sequential: []int{1, 0},
+		},
+		{
+			name:   "clock",
+			policy: func() ReplacementPolicy { return NewClockPolicy() },
+			// the first sweep clears every reference bit
+			victims:    []int{0, 1, 2, 3},
+			sequential: []int{1, 0},
+		},
+		{
+			name:   "lru-2",
+			policy: func() ReplacementPolicy { return NewLRUKPolicy(2) },
+			// frame 0 is the only one pinned twice
+			victims:    []int{1, 2, 3, 0},
+			sequential: []int{1, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.policy()
+			for frame := range 4 {
+				p.Pinned(frame)
+			}
+			_, ok := p.Victim()
+			require.False(t, ok)
+			for _, frame := range []int{2, 0, 3, 1} {
+				p.Unpinned(frame, false)
+			}
+			p.Pinned(0)
+			p.Unpinned(0, false)
+			require.Equal(t, tt.victims, victims(p))
+
+			// a frame read by a sequential scan goes first
+			p = tt.policy()
+			p.Pinned(0)
+			p.Pinned(1)
+			p.Unpinned(0, false)
+			p.Unpinned(1, true)
+			require.Equal(t, tt.sequential, victims(p))
+		})
+	}
+}
+
+func TestBufferManager_scanResistance(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []BufferManagerOptions
+		resides bool
+	}{
+		{name: "lru", opts: []BufferManagerOptions{WithReplacementPolicy(NewLRUPolicy())}, resides: false},
+		{name: "scan resistant lru", opts: []BufferManagerOptions{WithReplacementPolicy(NewLRUPolicy()), WithScanResistance()}, resides: true},
+		{name: "scan resistant clock", opts: []BufferManagerOptions{WithReplacementPolicy(NewClockPolicy()), WithScanResistance()}, resides: true},
+		{name: "scan resistant lru-2", opts: []BufferManagerOptions{WithReplacementPolicy(NewLRUKPolicy(2)), WithScanResistance()}, resides: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, err := storage.NewFileManager(t.TempDir(), 400)
+			require.NoError(t, err)
+			defer fm.Close()
+			bm := NewBufferManager(fm, &log.LogManager{}, 3, tt.opts...)
+
+			// the hot block is read between every third block of a scan larger than the pool
+			hot := storage.NewBlock("hot", 0)
+			for i := range 9 {
+				if i%3 == 0 {
+					buf, err := bm.Pin(hot)
+					require.NoError(t, err)
+					bm.Unpin(buf)
+				}
+				buf, err := bm.Pin(storage.NewBlock("big", i))
+				require.NoError(t, err)
+				bm.Unpin(buf)
+			}
+			_, err = bm.GetBuf(hot)
+			if tt.resides {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrBlockNotFound)
+			}
+			require.Equal(t, 3, bm.Available())
+		})
+	}
+}
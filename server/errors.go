@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"simpledb"
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/plan"
+	"simpledb/query"
+)
+
+// Code identifies the sentinel error a request failed with,
+// so that the client can match it with errors.Is
+type Code int
+
+const (
+	// Code_UNKNOWN is the code of an error without a sentinel known to the protocol
+	Code_UNKNOWN Code = iota
+	Code_SYNTAX
+	Code_DEADLOCK
+	Code_LOCK_ABORT
+	Code_BUFFER_FULL
+	Code_TX_FINISHED
+	Code_TABLE_NOT_FOUND
+	Code_FIELD_NOT_FOUND
+	Code_TYPE_MISMATCH
+	Code_NOT_CONNECTED
+	Code_VERSION_MISMATCH
+	Code_UNKNOWN_OPERATION
+)
+
+// codeErrors lists the sentinel error of each code, in the order ErrorCode tries them
+var codeErrors = []struct {
+	code Code
+	err  error
+}{
+	{Code_SYNTAX, parse.ErrSyntax},
+	{Code_DEADLOCK, simpledb.ErrDeadlock},
+	{Code_LOCK_ABORT, simpledb.ErrLockAbort},
+	{Code_BUFFER_FULL, simpledb.ErrBufferFull},
+	{Code_TX_FINISHED, simpledb.ErrTxFinished},
+	{Code_TABLE_NOT_FOUND, metadata.ErrTableNotFound},
+	{Code_FIELD_NOT_FOUND, query.ErrFieldNotFound},
+	{Code_TYPE_MISMATCH, plan.ErrTypeMismatch},
+	{Code_NOT_CONNECTED, ErrNotConnected},
+	{Code_VERSION_MISMATCH, ErrVersionMismatch},
+	{Code_UNKNOWN_OPERATION, ErrUnknownOperation},
+}
+
+// ErrorCode returns the code of the first sentinel the error matches
+func ErrorCode(err error) Code {
+	for _, ce := range codeErrors {
+		if errors.Is(err, ce.err) {
+			return ce.code
+		}
+	}
+	return Code_UNKNOWN
+}
+
+// ResponseError is an error reported by the server. It unwraps to the sentinel error of its code.
+type ResponseError struct {
+	Code Code
+	Msg  string
+}
+
+func (e *ResponseError) Error() string {
+	return e.Msg
+}
+
+func (e *ResponseError) Unwrap() error {
+	for _, ce := range codeErrors {
+		if ce.code == e.Code {
+			return ce.err
+		}
+	}
+	return nil
+}
+
+// errorResponse reports the error to the client
+func errorResponse(err error) *Response {
+	return &Response{Err: err.Error(), Code: ErrorCode(err)}
+}
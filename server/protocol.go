@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"simpledb/record"
+)
+
+// ProtocolVersion is sent by the client when it connects
+const ProtocolVersion = 1
+
+// MaxFrameSize bounds the size of a message, so that a corrupt length cannot exhaust memory
+const MaxFrameSize = 64 << 20
+
+var (
+	ErrFrameTooLarge      = errors.New("frame too large")
+	ErrNotConnected       = errors.New("connect must be the first request")
+	ErrVersionMismatch    = errors.New("protocol version mismatch")
+	ErrUnknownOperation   = errors.New("unknown operation")
+	ErrUnexpectedResponse = errors.New("unexpected response")
+)
+
+// Op is the operation of a request
+type Op int
+
+const (
+	Op_CONNECT Op = iota
+	Op_QUERY
+	Op_UPDATE
+	Op_COMMIT
+	Op_ROLLBACK
+	Op_CLOSE
+)
+
+func (op Op) String() string {
+	switch op {
+	case Op_CONNECT:
+		return "connect"
+	case Op_QUERY:
+		return "query"
+	case Op_UPDATE:
+		return "update"
+	case Op_COMMIT:
+		return "commit"
+	case Op_ROLLBACK:
+		return "rollback"
+	case Op_CLOSE:
+		return "close"
+	default:
+		return fmt.Sprintf("op(%d)", int(op))
+	}
+}
+
+// Request is sent by the client. SQL is set for queries and updates,
+// Version for connect.
+type Request struct {
+	Op      Op     `json:"op"`
+	SQL     string `json:"sql,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+// Response answers a request. Err is the message of the error the request failed with
+// and Code identifies it, Result is the output of a query and Affected the number of records
+// changed by an update.
+type Response struct {
+	Err      string  `json:"err,omitempty"`
+	Code     Code    `json:"code,omitempty"`
+	Result   *Result `json:"result,omitempty"`
+	Affected int     `json:"affected,omitempty"`
+}
+
+// Failure returns the error the request failed with as a *ResponseError, nil if it succeeded
+func (r *Response) Failure() error {
+	if r.Err == "" {
+		return nil
+	}
+	return &ResponseError{Code: r.Code, Msg: r.Err}
+}
+
+// Column is an output field of a query
+type Column struct {
+	Name string           `json:"name"`
+	Type record.FieldType `json:"type"`
+}
+
+// Result holds the output records of a query.
+// The value of an integer field is an int64 and that of a varchar field a string.
+type Result struct {
+	Columns []Column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+// UnmarshalJSON restores the int64 values of the integer fields, which JSON reads as numbers
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Columns []Column            `json:"columns"`
+		Rows    [][]json.RawMessage `json:"rows"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	r.Columns = raw.Columns
+	r.Rows = make([][]any, len(raw.Rows))
+	for i, row := range raw.Rows {
+		if len(row) != len(raw.Columns) {
+			return fmt.Errorf("%w: row %d has %d values for %d columns", ErrUnexpectedResponse, i, len(row), len(raw.Columns))
+		}
+		r.Rows[i] = make([]any, len(row))
+		for j, val := range row {
+			if raw.Columns[j].Type == record.FieldType_INTEGER {
+				var n int64
+				err = json.Unmarshal(val, &n)
+				r.Rows[i][j] = n
+			} else {
+				var s string
+				err = json.Unmarshal(val, &s)
+				r.Rows[i][j] = s
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteFrame writes the message as JSON preceded by its length as a 4-byte big-endian integer
+func WriteFrame(w io.Writer, msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(data))
+	}
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	_, err = w.Write(header[:])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadFrame reads a message written by WriteFrame.
+// It returns io.EOF if the stream ends before the frame starts.
+func ReadFrame(r io.Reader, msg any) error {
+	var header [4]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return json.Unmarshal(data, msg)
+}
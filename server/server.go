@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"simpledb"
+	"sync"
+)
+
+var ErrServerClosed = errors.New("server closed")
+
+// Server serves a database over TCP. Each connection has its own Session and is served
+// by its own goroutine, which answers the requests of the connection one at a time.
+type Server struct {
+	db        *simpledb.SimpleDB
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func NewServer(db *simpledb.SimpleDB) *Server {
+	return &Server{
+		db:        db,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves the connections until the server is closed
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the listener until the server is closed, then returns ErrServerClosed.
+// The listener is closed when Serve returns.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes the open ones and waits for their
+// goroutines to finish. The open transactions are rolled back.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var errs []error
+	for l := range s.listeners {
+		errs = append(errs, l.Close())
+	}
+	for conn := range s.conns {
+		errs = append(errs, conn.Close())
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	session := NewSession(s.db)
+	defer func() {
+		err := session.Close()
+		if err != nil {
+			slog.Error("Server.serveConn: rollback failed", slog.String("remote", conn.RemoteAddr().String()), slog.Any("err", err))
+		}
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	connected := false
+	for {
+		req := &Request{}
+		err := ReadFrame(r, req)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				slog.Error("Server.serveConn: bad request", slog.String("remote", conn.RemoteAddr().String()), slog.Any("err", err))
+			}
+			return
+		}
+
+		var resp *Response
+		switch {
+		case req.Op == Op_CONNECT:
+			resp = connect(req)
+			connected = resp.Err == ""
+		case !connected:
+			resp = errorResponse(ErrNotConnected)
+		default:
+			resp = execute(session, req)
+		}
+		err = WriteFrame(w, resp)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			slog.Error("Server.serveConn: response not sent", slog.String("remote", conn.RemoteAddr().String()), slog.Any("err", err))
+			return
+		}
+		if req.Op == Op_CLOSE || !connected {
+			return
+		}
+	}
+}
+
+func connect(req *Request) *Response {
+	if req.Version != ProtocolVersion {
+		return errorResponse(fmt.Errorf("%w: client %d, server %d", ErrVersionMismatch, req.Version, ProtocolVersion))
+	}
+	return &Response{}
+}
+
+// execute runs the request in the session
+func execute(session *Session, req *Request) *Response {
+	var err error
+	resp := &Response{}
+	switch req.Op {
+	case Op_QUERY:
+		resp.Result, err = session.Query(req.SQL)
+	case Op_UPDATE:
+		resp.Affected, err = session.Update(req.SQL)
+	case Op_COMMIT:
+		err = session.Commit()
+	case Op_ROLLBACK, Op_CLOSE:
+		err = session.Rollback()
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnknownOperation, req.Op)
+	}
+	if err != nil {
+		return errorResponse(err)
+	}
+	return resp
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"simpledb"
+	"simpledb/record"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves a new database in a temporary directory and returns its address
+func startServer(t *testing.T) string {
+	t.Helper()
+	db, err := simpledb.NewDB(t.TempDir(), 400, 8)
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := NewServer(db)
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(l)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, srv.Close())
+		require.ErrorIs(t, <-done, ErrServerClosed)
+		require.NoError(t, db.Close())
+	})
+	return l.Addr().String()
+}
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) call(req *Request) *Response {
+	require.NoError(c.t, WriteFrame(c.conn, req))
+	resp := &Response{}
+	require.NoError(c.t, ReadFrame(c.r, resp))
+	return resp
+}
+
+func TestServer(t *testing.T) {
+	addr := startServer(t)
+	c := dial(t, addr)
+
+	resp := c.call(&Request{Op: Op_CONNECT, Version: ProtocolVersion})
+	require.Empty(t, resp.Err)
+	resp = c.call(&Request{Op: Op_UPDATE, SQL: "create table t (a int, b varchar(5))"})
+	require.Empty(t, resp.Err)
+	for _, sql := range []string{
+		"insert into t (a, b) values (1, 'one')",
+		"insert into t (a, b) values (2, 'two')",
+	} {
+		resp = c.call(&Request{Op: Op_UPDATE, SQL: sql})
+		require.Empty(t, resp.Err)
+		require.Equal(t, 1, resp.Affected)
+	}
+	resp = c.call(&Request{Op: Op_COMMIT})
+	require.Empty(t, resp.Err)
+
+	// a rolled back delete leaves the table unchanged
+	resp = c.call(&Request{Op: Op_UPDATE, SQL: "delete from t where a = 1"})
+	require.Empty(t, resp.Err)
+	require.Equal(t, 1, resp.Affected)
+	resp = c.call(&Request{Op: Op_ROLLBACK})
+	require.Empty(t, resp.Err)
+
+	resp = c.call(&Request{Op: Op_QUERY, SQL: "select b, a from t order by a"})
+	require.Empty(t, resp.Err)
+	require.Equal(t, &Result{
+		Columns: []Column{{Name: "b", Type: record.FieldType_VARCHAR}, {Name: "a", Type: record.FieldType_INTEGER}},
+		Rows:    [][]any{{"one", int64(1)}, {"two", int64(2)}},
+	}, resp.Result)
+
+	resp = c.call(&Request{Op: Op_QUERY, SQL: "select c from t"})
+	require.Contains(t, resp.Err, "field not found")
+	resp = c.call(&Request{Op: Op_UPDATE, SQL: "select a from t"})
+	require.Contains(t, resp.Err, "statement is not an update")
+
+	// closing a connection rolls back its transaction
+	resp = c.call(&Request{Op: Op_UPDATE, SQL: "delete from t where a = 2"})
+	require.Empty(t, resp.Err)
+	resp = c.call(&Request{Op: Op_CLOSE})
+	require.Empty(t, resp.Err)
+
+	c = dial(t, addr)
+	resp = c.call(&Request{Op: Op_CONNECT, Version: ProtocolVersion})
+	require.Empty(t, resp.Err)
+	resp = c.call(&Request{Op: Op_QUERY, SQL: "select a from t"})
+	require.Empty(t, resp.Err)
+	require.Len(t, resp.Result.Rows, 2)
+}
+
+func TestServer_connect(t *testing.T) {
+	addr := startServer(t)
+
+	c := dial(t, addr)
+	resp := c.call(&Request{Op: Op_QUERY, SQL: "select a from t"})
+	require.Equal(t, ErrNotConnected.Error(), resp.Err)
+
+	c = dial(t, addr)
+	resp = c.call(&Request{Op: Op_CONNECT, Version: ProtocolVersion + 1})
+	require.Contains(t, resp.Err, ErrVersionMismatch.Error())
+}
+
+func TestReadFrame(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteFrame(buf, &Request{Op: Op_UPDATE, SQL: "delete from t"}))
+	req := &Request{}
+	require.NoError(t, ReadFrame(buf, req))
+	require.Equal(t, &Request{Op: Op_UPDATE, SQL: "delete from t"}, req)
+
+	buf.Reset()
+	binary.Write(buf, binary.BigEndian, uint32(MaxFrameSize+1))
+	require.ErrorIs(t, ReadFrame(buf, req), ErrFrameTooLarge)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"errors"
+	"simpledb"
+	"simpledb/record"
+)
+
+// Session runs the statements of one client in its current transaction.
+// The first statement starts a transaction, which lasts until Commit or Rollback.
+// A statement that fails rolls the transaction back.
+type Session struct {
+	db *simpledb.SimpleDB
+	tx *simpledb.Transaction
+}
+
+func NewSession(db *simpledb.SimpleDB) *Session {
+	return &Session{db: db}
+}
+
+// Query executes a select statement and reads its whole output
+func (s *Session) Query(sql string) (*Result, error) {
+	tx, err := s.transaction()
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.query(sql, tx)
+	if err != nil {
+		return nil, s.abort(err)
+	}
+	return result, nil
+}
+
+func (s *Session) query(sql string, tx *simpledb.Transaction) (*Result, error) {
+	p, err := s.db.Planner().CreateQueryPlan(sql, tx)
+	if err != nil {
+		return nil, err
+	}
+	schema := p.Schema()
+	result := &Result{
+		Columns: make([]Column, 0, len(schema.Fields())),
+		Rows:    [][]any{},
+	}
+	for _, field := range schema.Fields() {
+		result.Columns = append(result.Columns, Column{Name: field, Type: schema.Type(field)})
+	}
+
+	scan, err := p.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer scan.Close()
+	for {
+		ok, err := scan.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return result, nil
+		}
+		row := make([]any, len(result.Columns))
+		for i, col := range result.Columns {
+			if col.Type == record.FieldType_INTEGER {
+				n, err := scan.GetInt(col.Name)
+				if err != nil {
+					return nil, err
+				}
+				row[i] = int64(n)
+			} else {
+				row[i], err = scan.GetString(col.Name)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+}
+
+// Update executes an insert, delete, update or create statement
+// and returns the number of affected records
+func (s *Session) Update(sql string) (int, error) {
+	tx, err := s.transaction()
+	if err != nil {
+		return 0, err
+	}
+	n, err := s.db.Planner().ExecuteUpdate(sql, tx)
+	if err != nil {
+		return 0, s.abort(err)
+	}
+	return n, nil
+}
+
+// Commit commits the current transaction, if any
+func (s *Session) Commit() error {
+	if s.tx == nil {
+		return nil
+	}
+	tx := s.tx
+	s.tx = nil
+	return tx.Commit()
+}
+
+// Rollback rolls back the current transaction, if any
+func (s *Session) Rollback() error {
+	if s.tx == nil {
+		return nil
+	}
+	tx := s.tx
+	s.tx = nil
+	return tx.Rollback()
+}
+
+// Close rolls back the current transaction
+func (s *Session) Close() error {
+	return s.Rollback()
+}
+
+func (s *Session) transaction() (*simpledb.Transaction, error) {
+	if s.tx != nil {
+		return s.tx, nil
+	}
+	tx, err := s.db.NewTransaction()
+	if err != nil {
+		return nil, err
+	}
+	s.tx = tx
+	return tx, nil
+}
+
+// abort rolls back the transaction a statement failed in and returns the statement's error.
+// The rollback of a deadlock victim, which has already rolled back, does nothing.
+func (s *Session) abort(err error) error {
+	rerr := s.Rollback()
+	if rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return err
+}
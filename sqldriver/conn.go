@@ -0,0 +1,282 @@
+package sqldriver
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"simpledb/server"
+)
+
+var (
+	ErrNoArguments    = errors.New("statements take no arguments")
+	ErrTxInProgress   = errors.New("a transaction is already in progress")
+	ErrTxAborted      = errors.New("transaction was rolled back by a failed statement")
+	ErrConnectionLost = errors.New("connection lost")
+)
+
+// session runs statements in a transaction which lasts until commit or rollback.
+// It is a *server.Session for an embedded database and a remoteSession for a server.
+type session interface {
+	Query(sql string) (*server.Result, error)
+	Update(sql string) (int, error)
+	Commit() error
+	Rollback() error
+	Close() error
+}
+
+// remoteSession forwards the statements to a server, one request at a time
+type remoteSession struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func dial(addr string) (*remoteSession, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	rs := &remoteSession{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+	_, err = rs.call(&server.Request{Op: server.Op_CONNECT, Version: server.ProtocolVersion})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return rs, nil
+}
+
+// call sends the request and waits for its response. An error reported by the server
+// is returned as a *server.ResponseError, which matches the sentinel errors with errors.Is;
+// a failure of the connection is wrapped in driver.ErrBadConn,
+// so that database/sql discards the connection.
+func (rs *remoteSession) call(req *server.Request) (*server.Response, error) {
+	err := server.WriteFrame(rs.w, req)
+	if err == nil {
+		err = rs.w.Flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w: %v", driver.ErrBadConn, ErrConnectionLost, err)
+	}
+	resp := &server.Response{}
+	err = server.ReadFrame(rs.r, resp)
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+			return nil, fmt.Errorf("%w: %w: %v", driver.ErrBadConn, ErrConnectionLost, err)
+		}
+		return nil, err
+	}
+	err = resp.Failure()
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (rs *remoteSession) Query(sql string) (*server.Result, error) {
+	resp, err := rs.call(&server.Request{Op: server.Op_QUERY, SQL: sql})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Result == nil {
+		return nil, fmt.Errorf("%w: no result for %q", server.ErrUnexpectedResponse, sql)
+	}
+	return resp.Result, nil
+}
+
+func (rs *remoteSession) Update(sql string) (int, error) {
+	resp, err := rs.call(&server.Request{Op: server.Op_UPDATE, SQL: sql})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Affected, nil
+}
+
+func (rs *remoteSession) Commit() error {
+	_, err := rs.call(&server.Request{Op: server.Op_COMMIT})
+	return err
+}
+
+func (rs *remoteSession) Rollback() error {
+	_, err := rs.call(&server.Request{Op: server.Op_ROLLBACK})
+	return err
+}
+
+// Close tells the server to roll back the open transaction and closes the connection
+func (rs *remoteSession) Close() error {
+	_, err := rs.call(&server.Request{Op: server.Op_CLOSE})
+	return errors.Join(err, rs.conn.Close())
+}
+
+// conn commits each statement on its own, unless it runs in a transaction started by Begin
+type conn struct {
+	s       session
+	inTx    bool
+	aborted bool
+}
+
+func newConn(s session) *conn {
+	return &conn{s: s}
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return c.s.Close()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	if c.inTx {
+		return nil, ErrTxInProgress
+	}
+	c.inTx = true
+	return &tx{c: c}, nil
+}
+
+func (c *conn) query(sql string) (*server.Result, error) {
+	if c.aborted {
+		return nil, ErrTxAborted
+	}
+	result, err := c.s.Query(sql)
+	if err != nil {
+		return nil, c.failed(err)
+	}
+	return result, c.autocommit()
+}
+
+func (c *conn) update(sql string) (int, error) {
+	if c.aborted {
+		return 0, ErrTxAborted
+	}
+	n, err := c.s.Update(sql)
+	if err != nil {
+		return 0, c.failed(err)
+	}
+	return n, c.autocommit()
+}
+
+// autocommit commits a statement run outside of a transaction
+func (c *conn) autocommit() error {
+	if c.inTx {
+		return nil
+	}
+	return c.s.Commit()
+}
+
+// failed records that the failed statement rolled back the transaction it ran in
+func (c *conn) failed(err error) error {
+	if c.inTx {
+		c.aborted = true
+	}
+	return err
+}
+
+// endTx commits or rolls back the transaction started by Begin
+func (c *conn) endTx(commit bool) error {
+	aborted := c.aborted
+	c.inTx = false
+	c.aborted = false
+	if aborted {
+		if commit {
+			return ErrTxAborted
+		}
+		return nil
+	}
+	if commit {
+		return c.s.Commit()
+	}
+	return c.s.Rollback()
+}
+
+type tx struct {
+	c *conn
+}
+
+func (t *tx) Commit() error {
+	return t.c.endTx(true)
+}
+
+func (t *tx) Rollback() error {
+	return t.c.endTx(false)
+}
+
+// stmt is executed by sending its text as it is; statements have no placeholders
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return 0
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, ErrNoArguments
+	}
+	n, err := s.c.update(s.query)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, ErrNoArguments
+	}
+	result, err := s.c.query(s.query)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(result), nil
+}
+
+// rows iterates over a query output, which the session has read in full
+type rows struct {
+	columns []string
+	result  *server.Result
+	next    int
+}
+
+func newRows(result *server.Result) *rows {
+	columns := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		columns[i] = col.Name
+	}
+	return &rows{
+		columns: columns,
+		result:  result,
+	}
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	for i, val := range r.result.Rows[r.next] {
+		dest[i] = val
+	}
+	r.next++
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package sqldriver is a database/sql driver for simpledb, registered as "simpledb".
+//
+// The data source name is either the address of a server, "tcp://host:port",
+// or the directory of an embedded database, "file:dir?blocksize=4096&buffers=64"
+// (the "file:" prefix and the parameters are optional).
+package sqldriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"simpledb"
+	"simpledb/server"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultBlockSize = 4096
+	DefaultBuffers   = 64
+)
+
+var (
+	ErrInvalidDSN     = errors.New("invalid data source name")
+	ErrConflictingDSN = errors.New("database is already open with other parameters")
+)
+
+func init() {
+	sql.Register("simpledb", &Driver{})
+}
+
+// Driver opens connections to a server or to an embedded database
+type Driver struct{}
+
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	if strings.HasPrefix(dsn, "tcp://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDSN, err)
+		}
+		s, err := dial(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return newConn(s), nil
+	}
+
+	dir, blocksize, buffers, err := parseFileDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db, release, err := openEmbedded(dir, blocksize, buffers)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(&embeddedSession{Session: server.NewSession(db), release: release}), nil
+}
+
+// parseFileDSN returns the directory, block size and number of buffers of an embedded database
+func parseFileDSN(dsn string) (string, int, int, error) {
+	dir, params, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if dir == "" {
+		return "", 0, 0, fmt.Errorf("%w: no directory in %q", ErrInvalidDSN, dsn)
+	}
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("%w: %v", ErrInvalidDSN, err)
+	}
+	blocksize, buffers := DefaultBlockSize, DefaultBuffers
+	for name, vals := range values {
+		n, err := strconv.Atoi(vals[0])
+		if err != nil || n <= 0 {
+			return "", 0, 0, fmt.Errorf("%w: %s=%s", ErrInvalidDSN, name, vals[0])
+		}
+		switch name {
+		case "blocksize":
+			blocksize = n
+		case "buffers":
+			buffers = n
+		default:
+			return "", 0, 0, fmt.Errorf("%w: unknown parameter %s", ErrInvalidDSN, name)
+		}
+	}
+	return dir, blocksize, buffers, nil
+}
+
+var embedded struct {
+	mu  sync.Mutex
+	dbs map[string]*embeddedDB
+}
+
+// embeddedDB is a database shared by the connections to its directory
+type embeddedDB struct {
+	db        *simpledb.SimpleDB
+	blocksize int
+	buffers   int
+	refs      int
+}
+
+// openEmbedded returns the database in the directory, opening it on first use, and the function
+// releasing it. Every connection to the directory shares the database, which is closed once
+// the last of them releases it. It returns ErrConflictingDSN if the database is open
+// with another block size or number of buffers.
+func openEmbedded(dir string, blocksize, buffers int) (*simpledb.SimpleDB, func() error, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	embedded.mu.Lock()
+	defer embedded.mu.Unlock()
+	e, found := embedded.dbs[dir]
+	if found {
+		if e.blocksize != blocksize || e.buffers != buffers {
+			return nil, nil, fmt.Errorf("%w: %s has blocksize=%d&buffers=%d", ErrConflictingDSN, dir, e.blocksize, e.buffers)
+		}
+	} else {
+		db, err := simpledb.NewDB(dir, blocksize, buffers)
+		if err != nil {
+			return nil, nil, err
+		}
+		if embedded.dbs == nil {
+			embedded.dbs = make(map[string]*embeddedDB)
+		}
+		e = &embeddedDB{db: db, blocksize: blocksize, buffers: buffers}
+		embedded.dbs[dir] = e
+	}
+	e.refs++
+	var once sync.Once
+	release := func() error {
+		var err error
+		once.Do(func() { err = releaseEmbedded(dir, e) })
+		return err
+	}
+	return e.db, release, nil
+}
+
+// releaseEmbedded drops a reference to the database and closes it if it was the last one
+func releaseEmbedded(dir string, e *embeddedDB) error {
+	embedded.mu.Lock()
+	defer embedded.mu.Unlock()
+	e.refs--
+	if e.refs > 0 {
+		return nil
+	}
+	delete(embedded.dbs, dir)
+	return e.db.Close()
+}
+
+// embeddedSession is a session of an embedded database, which it releases when closed
+type embeddedSession struct {
+	*server.Session
+	release func() error
+}
+
+func (s *embeddedSession) Close() error {
+	err := s.Session.Close()
+	return errors.Join(err, s.release())
+}
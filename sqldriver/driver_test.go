@@ -0,0 +1,185 @@
+package sqldriver
+
+import (
+	"database/sql"
+	"net"
+	"simpledb"
+	"simpledb/metadata"
+	"simpledb/parse"
+	"simpledb/server"
+	"simpledb/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFileDSN(t *testing.T) {
+	tests := []struct {
+		dsn       string
+		dir       string
+		blocksize int
+		buffers   int
+		err       error
+	}{
+		{dsn: "data", dir: "data", blocksize: DefaultBlockSize, buffers: DefaultBuffers},
+		{dsn: "file:/var/db?blocksize=400", dir: "/var/db", blocksize: 400, buffers: DefaultBuffers},
+		{dsn: "file:db?blocksize=400&buffers=8", dir: "db", blocksize: 400, buffers: 8},
+		{dsn: "file:", err: ErrInvalidDSN},
+		{dsn: "db?buffers=none", err: ErrInvalidDSN},
+		{dsn: "db?blocksize=0", err: ErrInvalidDSN},
+		{dsn: "db?pages=8", err: ErrInvalidDSN},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			dir, blocksize, buffers, err := parseFileDSN(tt.dsn)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.dir, dir)
+			require.Equal(t, tt.blocksize, blocksize)
+			require.Equal(t, tt.buffers, buffers)
+		})
+	}
+}
+
+// testDriver runs statements through a connection pool of the data source
+func testDriver(t *testing.T, dsn string) {
+	db, err := sql.Open("simpledb", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("create table t (a int, b varchar(5))")
+	require.NoError(t, err)
+	res, err := db.Exec("insert into t (a, b) values (1, 'one')")
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("insert into t (a, b) values (2, 'two')")
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// a rolled back transaction leaves the table unchanged
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("delete from t")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	// a failed statement aborts its transaction
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("insert into t (a, b) values (3, 'three')")
+	require.NoError(t, err)
+	_, err = tx.Exec("insert into t (c) values (4)")
+	require.Error(t, err)
+	_, err = tx.Exec("insert into t (a, b) values (5, 'five')")
+	require.ErrorIs(t, err, ErrTxAborted)
+	require.ErrorIs(t, tx.Commit(), ErrTxAborted)
+
+	rows, err := db.Query("select a, b from t order by a")
+	require.NoError(t, err)
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, columns)
+	type row struct {
+		a int
+		b string
+	}
+	got := []row{}
+	for rows.Next() {
+		var r row
+		require.NoError(t, rows.Scan(&r.a, &r.b))
+		got = append(got, r)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []row{{1, "one"}, {2, "two"}}, got)
+
+	var b string
+	require.NoError(t, db.QueryRow("select b from t where a = 2").Scan(&b))
+	require.Equal(t, "two", b)
+
+	_, err = db.Exec("delete from t where a = ?", 1)
+	require.Error(t, err)
+	_, err = db.Query("select c from t")
+	require.Error(t, err)
+
+	// the errors match their sentinels, whichever the data source
+	_, err = db.Exec("insert t (a) values (1)")
+	require.ErrorIs(t, err, parse.ErrSyntax)
+	_, err = db.Query("select a from nosuch")
+	require.ErrorIs(t, err, metadata.ErrTableNotFound)
+
+	_, err = db.Exec("create table u (a int)")
+	require.NoError(t, err)
+	_, err = db.Exec("insert into u (a) values (1)")
+	require.NoError(t, err)
+	tx1, err := db.Begin()
+	require.NoError(t, err)
+	tx2, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx1.Exec("update t set a = 10 where a = 2")
+	require.NoError(t, err)
+	_, err = tx2.Exec("update u set a = 20 where a = 1")
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		_, err := tx1.Exec("update u set a = 10 where a = 1")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err = tx2.Exec("update t set a = 20 where a = 2")
+	require.ErrorIs(t, err, simpledb.ErrDeadlock)
+	require.NoError(t, <-done)
+	require.NoError(t, tx1.Commit())
+	require.Error(t, tx2.Commit())
+}
+
+func TestDriver_embedded(t *testing.T) {
+	dir := t.TempDir()
+	testDriver(t, "file:"+dir+"?blocksize=400&buffers=8")
+
+	// closing the last connection closes the database and releases the directory
+	db, err := simpledb.NewDB(dir, 400, 8)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// the connections share the database, which keeps its directory locked until the last one is closed
+	db1, release1, err := openEmbedded(dir, 400, 8)
+	require.NoError(t, err)
+	db2, release2, err := openEmbedded(dir+"/.", 400, 8)
+	require.NoError(t, err)
+	require.Same(t, db1, db2)
+	_, _, err = openEmbedded(dir, 800, 8)
+	require.ErrorIs(t, err, ErrConflictingDSN)
+	_, err = simpledb.NewDB(dir, 400, 8)
+	require.ErrorIs(t, err, storage.ErrLocked)
+	require.NoError(t, release1())
+	require.NoError(t, release1())
+	_, err = simpledb.NewDB(dir, 400, 8)
+	require.ErrorIs(t, err, storage.ErrLocked)
+	require.NoError(t, release2())
+
+	db, err = simpledb.NewDB(dir, 400, 8)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+}
+
+func TestDriver_tcp(t *testing.T) {
+	db, err := simpledb.NewDB(t.TempDir(), 400, 8)
+	require.NoError(t, err)
+	defer db.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := server.NewServer(db)
+	go srv.Serve(l)
+	defer srv.Close()
+
+	testDriver(t, "tcp://"+l.Addr().String())
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// lockDir creates the lock file of the directory, failing if it exists.
+// The file is left behind if the process dies and must then be removed by hand.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, LockFile), os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, ErrLocked
+	}
+	return f, err
+}
+
+func unlockDir(f *os.File) error {
+	err := f.Close()
+	return errors.Join(err, os.Remove(f.Name()))
+}
+
+// syncDir does nothing, since directories cannot be synced on every platform
+func syncDir(dir string) error {
+	return nil
+}
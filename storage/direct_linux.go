@@ -0,0 +1,5 @@
+package storage
+
+import "syscall"
+
+const directFlag = syscall.O_DIRECT
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package storage
+
+// directFlag is 0 where O_DIRECT does not exist, which leaves the files buffered by the OS
+const directFlag = 0
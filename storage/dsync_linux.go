@@ -0,0 +1,5 @@
+package storage
+
+import "syscall"
+
+const dsyncFlag = syscall.O_DSYNC
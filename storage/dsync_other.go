@@ -0,0 +1,8 @@
+//go:build !linux
+
+package storage
+
+import "os"
+
+// dsyncFlag falls back to O_SYNC, which also syncs the file metadata
+const dsyncFlag = os.O_SYNC
@@ -2,10 +2,28 @@ package storage
 
 import (
 	"errors"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
+var (
+	ErrLocked = errors.New("database is locked by another process")
+	ErrClosed = errors.New("file manager is closed")
+)
+
+// TempFilePrefix starts the name of every temporary file, which does not outlive its process.
+// A temporary file is named by the prefix, a number and an optional extension, such as temp12.tbl.
+const TempFilePrefix = "temp"
+
+// LockFile is created in the database directory and locked while the directory is open
+const LockFile = "simpledb.lock"
+
 type FileManager interface {
 	Read(block *Block, page *Page) error
 	Write(block *Block, page *Page) error
@@ -13,83 +31,273 @@ type FileManager interface {
 	Length(filename string) (int, error)
 	Dump(block *Block) error
 	Blocksize() int
+	// Sync forces the written blocks of the file to disk
+	Sync(filename string) error
+	// Remove deletes the file, which does nothing if it does not exist
+	Remove(filename string) error
+	// IsNew returns true if the database directory did not exist or held no file but the lock file
+	IsNew() bool
+	// Close closes the open files and releases the directory
+	Close() error
 }
 
+// fileManager stores the files of a database in a directory, which it locks so that
+// a single process uses the database. The files are opened on first use and kept open.
+// The sync policy decides when the written blocks become durable.
 type fileManager struct {
+	dir       string
 	blocksize int
+	isNew     bool
+	lock      *os.File
+	policy    SyncPolicy
+	interval  time.Duration
+	flags     int
+	group     *groupSyncer
+	mu        sync.Mutex
+	files     map[string]*os.File
+	// dirDirty is set when a file is created, until the directory is synced
+	dirDirty bool
 }
 
-func NewFileManager(blocksize int) FileManager {
-	return &fileManager{
+// NewFileManager opens the database directory, creating it if it does not exist.
+// It returns ErrLocked if another process has the directory open,
+// and removes the temporary files left behind by the previous process.
+func NewFileManager(dir string, blocksize int, opts ...FileManagerOptions) (FileManager, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	isNew := len(entries) == 0 || len(entries) == 1 && entries[0].Name() == LockFile
+
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fm := &fileManager{
+		dir:       dir,
 		blocksize: blocksize,
+		isNew:     isNew,
+		lock:      lock,
+		interval:  GroupSyncIntervalMs * time.Millisecond,
+		files:     make(map[string]*os.File),
+	}
+	for _, opt := range opts {
+		opt(fm)
 	}
+	err = fm.removeTempFiles()
+	if err != nil {
+		fm.Close()
+		return nil, err
+	}
+	if fm.policy == SyncPolicy_GROUP {
+		fm.group = newGroupSyncer(fm, fm.interval)
+	}
+	return fm, nil
 }
 
-func (fm *fileManager) Read(block *Block, page *Page) error {
-	f, err := os.Open(block.Filename)
+func (fm *fileManager) removeTempFiles() error {
+	entries, err := os.ReadDir(fm.dir)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	for _, entry := range entries {
+		if IsTempFile(entry.Name()) {
+			err = os.Remove(filepath.Join(fm.dir, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsTempFile returns true if the file is a temporary file, named by TempFilePrefix and a number
+func IsTempFile(filename string) bool {
+	num, found := strings.CutPrefix(filename, TempFilePrefix)
+	num, _, _ = strings.Cut(num, ".")
+	return found && num != "" && strings.Trim(num, "0123456789") == ""
+}
 
-	_, err = f.ReadAt(page.Buf, int64(block.Num)*int64(fm.blocksize))
+// file returns the open file, opening it on first use.
+// Only writes create the file: opening a missing file otherwise returns os.ErrNotExist.
+func (fm *fileManager) file(filename string, create bool) (*os.File, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return fm.fileLocked(filename, create)
+}
+
+func (fm *fileManager) fileLocked(filename string, create bool) (*os.File, error) {
+	if f, found := fm.files[filename]; found {
+		return f, nil
+	}
+	if fm.lock == nil {
+		return nil, ErrClosed
+	}
+	path := filepath.Join(fm.dir, filename)
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if !create {
+			return nil, err
+		}
+		fm.dirDirty = true
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|fm.flags, 0644)
+	if errors.Is(err, syscall.EINVAL) && fm.flags&directFlag != 0 {
+		// the file system does not support direct I/O
+		fm.flags &^= directFlag
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR|fm.flags, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fm.files[filename] = f
+	return f, nil
+}
+
+// Read reads the block into the page.
+// A block beyond the end of the file, or of a file which does not exist, reads as zeros.
+func (fm *fileManager) Read(block *Block, page *Page) error {
+	f, err := fm.file(block.Filename, false)
+	if errors.Is(err, os.ErrNotExist) {
+		clear(page.Buf)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	n, err := f.ReadAt(page.Buf, int64(block.Num)*int64(fm.blocksize))
+	if errors.Is(err, io.EOF) {
+		clear(page.Buf[n:])
+		return nil
+	}
 	return err
 }
 
 func (fm *fileManager) Write(block *Block, page *Page) error {
-	slog.Info("FileManager.Write", slog.String("block", block.Filename), slog.Int("num", block.Num))
-	f, err := os.OpenFile(block.Filename, os.O_CREATE|os.O_WRONLY, 0644)
+	f, err := fm.file(block.Filename, true)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
 	_, err = f.WriteAt(page.Buf, int64(block.Num)*int64(fm.blocksize))
-	return err
+	if err != nil {
+		return err
+	}
+	if fm.policy == SyncPolicy_ALWAYS {
+		return fm.syncFile(block.Filename)
+	}
+	return nil
 }
 
 // Append appends a empty page to the end of the file
 func (fm *fileManager) Append(filename string) (*Block, error) {
-	blklen, err := fm.Length(filename)
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	f, err := fm.fileLocked(filename, true)
+	if err != nil {
+		return nil, err
+	}
+	blklen, err := fm.length(f)
 	if err != nil {
 		return nil, err
 	}
 
 	blk := NewBlock(filename, blklen)
-
-	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
+	data := alignedBytes(fm.blocksize)
+	_, err = f.WriteAt(data, int64(blklen*fm.blocksize))
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	if fm.policy == SyncPolicy_ALWAYS {
+		err = fm.syncLocked(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blk, nil
+}
 
-	data := make([]byte, fm.blocksize)
-	f.Seek(int64(blklen*fm.blocksize), 0)
-	f.Write(data)
+// Sync forces the file to disk, along with the directory if files have been created.
+// Under SyncPolicy_GROUP it waits for the next round of syncs.
+func (fm *fileManager) Sync(filename string) error {
+	if fm.group != nil {
+		return fm.group.sync(filename)
+	}
+	return fm.syncFile(filename)
+}
 
-	return blk, nil
+func (fm *fileManager) syncFile(filename string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	f, err := fm.fileLocked(filename, false)
+	if errors.Is(err, os.ErrNotExist) {
+		// nothing was written to the file
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fm.syncLocked(f)
+}
+
+func (fm *fileManager) syncLocked(f *os.File) error {
+	if fm.dirDirty {
+		err := syncDir(fm.dir)
+		if err != nil {
+			return err
+		}
+		fm.dirDirty = false
+	}
+	return f.Sync()
 }
 
-// Length returns the number of blocks in the file
+// Length returns the number of blocks in the file, 0 if it does not exist
 func (fm *fileManager) Length(filename string) (int, error) {
-	info, err := os.Stat(filename)
+	f, err := fm.file(filename, false)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fm.length(f)
+}
+
+func (fm *fileManager) length(f *os.File) (int, error) {
+	info, err := f.Stat()
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return 0, nil
-		}
 		return 0, err
 	}
-	return int(info.Size()) / fm.blocksize, err
+	return int(info.Size()) / fm.blocksize, nil
+}
+
+func (fm *fileManager) Remove(filename string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if f, found := fm.files[filename]; found {
+		delete(fm.files, filename)
+		err := f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	err := os.Remove(filepath.Join(fm.dir, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
 }
 
 func (fm *fileManager) Dump(block *Block) error {
-	f, err := os.Open(block.Filename)
+	f, err := fm.file(block.Filename, false)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	buf := make([]byte, fm.blocksize)
+	buf := alignedBytes(fm.blocksize)
 	_, err = f.ReadAt(buf, int64(block.Num)*int64(fm.Blocksize()))
 	if err != nil {
 		return err
@@ -103,6 +311,29 @@ func (fm *fileManager) Blocksize() int {
 	return fm.blocksize
 }
 
+func (fm *fileManager) IsNew() bool {
+	return fm.isNew
+}
+
+// Close finishes the pending syncs, closes the open files and then unlocks the directory
+func (fm *fileManager) Close() error {
+	if fm.group != nil {
+		fm.group.close()
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	var errs []error
+	for filename, f := range fm.files {
+		errs = append(errs, f.Close())
+		delete(fm.files, filename)
+	}
+	if fm.lock != nil {
+		errs = append(errs, unlockDir(fm.lock))
+		fm.lock = nil
+	}
+	return errors.Join(errs...)
+}
+
 type NopFileManager struct {
 	data  []byte
 	bsize int
@@ -116,7 +347,7 @@ func NewNopFileManager(bsize int, data []byte) *NopFileManager {
 }
 
 func (d *NopFileManager) Read(blk *Block, page *Page) error {
-	page.Buf = d.data
+	copy(page.Buf, d.data)
 	return nil
 }
 
@@ -132,6 +363,10 @@ func (d *NopFileManager) Length(filename string) (int, error) {
 	return 0, nil
 }
 
+func (d *NopFileManager) Remove(filename string) error {
+	return nil
+}
+
 func (d *NopFileManager) Dump(blk *Block) error {
 	return nil
 }
@@ -139,3 +374,15 @@ func (d *NopFileManager) Dump(blk *Block) error {
 func (d *NopFileManager) Blocksize() int {
 	return d.bsize
 }
+
+func (d *NopFileManager) IsNew() bool {
+	return false
+}
+
+func (d *NopFileManager) Close() error {
+	return nil
+}
+
+func (d *NopFileManager) Sync(filename string) error {
+	return nil
+}
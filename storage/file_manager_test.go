@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileManager(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	fm, err := NewFileManager(dir, 100)
+	require.NoError(t, err)
+	require.True(t, fm.IsNew())
+
+	// a block beyond the end of the file reads as zeros
+	page := NewPage(100)
+	page.SetString(0, "stale")
+	require.NoError(t, fm.Read(NewBlock("data.tbl", 3), page))
+	require.Equal(t, make([]byte, 100), page.Buf)
+
+	// probing a missing file does not create it
+	page.SetString(0, "stale")
+	require.NoError(t, fm.Read(NewBlock("missing.tbl", 0), page))
+	require.Equal(t, make([]byte, 100), page.Buf)
+	n, err := fm.Length("missing.tbl")
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.NoError(t, fm.Sync("missing.tbl"))
+	_, err = os.Stat(filepath.Join(dir, "missing.tbl"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	blk, err := fm.Append("data.tbl")
+	require.NoError(t, err)
+	require.Equal(t, NewBlock("data.tbl", 0), blk)
+	blk, err = fm.Append("data.tbl")
+	require.NoError(t, err)
+	require.Equal(t, 1, blk.Num)
+	page.SetString(0, "hello")
+	require.NoError(t, fm.Write(blk, page))
+	require.NoError(t, fm.Write(NewBlock("temp3.tbl", 0), page))
+	require.NoError(t, fm.Write(NewBlock("temperature.tbl", 0), page))
+
+	// a removed file reads as empty, and is created again by the next write
+	require.NoError(t, fm.Write(NewBlock("removed.tbl", 0), page))
+	require.NoError(t, fm.Remove("removed.tbl"))
+	require.NoError(t, fm.Remove("removed.tbl"))
+	_, err = os.Stat(filepath.Join(dir, "removed.tbl"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	n, err = fm.Length("removed.tbl")
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// the directory is locked until the file manager is closed
+	_, err = NewFileManager(dir, 100)
+	require.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, fm.Close())
+
+	fm, err = NewFileManager(dir, 100)
+	require.NoError(t, err)
+	defer fm.Close()
+	require.False(t, fm.IsNew())
+	n, err = fm.Length("data.tbl")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	page = NewPage(100)
+	require.NoError(t, fm.Read(NewBlock("data.tbl", 1), page))
+	s, err := page.GetString(0)
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+
+	// only the files of temporary tables are removed
+	_, err = os.Stat(filepath.Join(dir, "temp3.tbl"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(dir, "temperature.tbl"))
+	require.NoError(t, err)
+}
+
+func TestIsTempFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{"temp1.tbl", true},
+		{"temp42", true},
+		{"temp.tbl", false},
+		{"temperature.tbl", false},
+		{"temp1x.tbl", false},
+		{"mytemp1.tbl", false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, IsTempFile(tt.filename), tt.filename)
+	}
+}
+
+func TestNewFileManager_existingDir(t *testing.T) {
+	dir := t.TempDir()
+	fm, err := NewFileManager(dir, 100)
+	require.NoError(t, err)
+	// an empty directory holds a new database
+	require.True(t, fm.IsNew())
+	require.NoError(t, fm.Close())
+
+	fm, err = NewFileManager(dir, 100)
+	require.NoError(t, err)
+	require.True(t, fm.IsNew())
+	_, err = fm.Append("data.tbl")
+	require.NoError(t, err)
+	require.NoError(t, fm.Close())
+
+	fm, err = NewFileManager(dir, 100)
+	require.NoError(t, err)
+	require.False(t, fm.IsNew())
+	require.NoError(t, fm.Close())
+}
+
+func TestFileManager_syncPolicies(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []FileManagerOptions
+	}{
+		{name: "log"},
+		{name: "always", opts: []FileManagerOptions{WithSyncPolicy(SyncPolicy_ALWAYS)}},
+		{name: "group", opts: []FileManagerOptions{WithSyncPolicy(SyncPolicy_GROUP), WithGroupSyncInterval(1)}},
+		{name: "dsync", opts: []FileManagerOptions{WithDSync()}},
+		{name: "direct", opts: []FileManagerOptions{WithDirectIO()}},
+		{name: "direct dsync", opts: []FileManagerOptions{WithDirectIO(), WithDSync()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fm, err := NewFileManager(dir, DirectIOAlignment, tt.opts...)
+			require.NoError(t, err)
+
+			// concurrent syncs of a group share its rounds
+			var wg sync.WaitGroup
+			errs := make([]error, 8)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					filename := fmt.Sprintf("file%d", i%2)
+					page := NewPage(DirectIOAlignment)
+					page.SetInt32(0, int32(i))
+					errs[i] = fm.Write(NewBlock(filename, i), page)
+					if errs[i] == nil {
+						errs[i] = fm.Sync(filename)
+					}
+				}()
+			}
+			wg.Wait()
+			for _, err := range errs {
+				require.NoError(t, err)
+			}
+			require.NoError(t, fm.Close())
+			require.ErrorIs(t, fm.Sync("file0"), ErrClosed)
+			// closing again does nothing
+			require.NoError(t, fm.Close())
+
+			fm, err = NewFileManager(dir, DirectIOAlignment)
+			require.NoError(t, err)
+			defer fm.Close()
+			page := NewPage(DirectIOAlignment)
+			require.NoError(t, fm.Read(NewBlock("file1", 7), page))
+			n, err := page.GetInt32(0)
+			require.NoError(t, err)
+			require.Equal(t, int32(7), n)
+		})
+	}
+}
+
+func TestFileManager_directIO(t *testing.T) {
+	// pages of whole aligned blocks are aligned in memory
+	for range 8 {
+		page := NewPage(2 * DirectIOAlignment)
+		require.Zero(t, uintptr(unsafe.Pointer(&page.Buf[0]))%DirectIOAlignment)
+		require.Len(t, page.Buf, 2*DirectIOAlignment)
+		require.Equal(t, 2*DirectIOAlignment, cap(page.Buf))
+	}
+
+	// a block size which is not aligned keeps the files buffered
+	fm, err := NewFileManager(t.TempDir(), 100, WithDirectIO())
+	require.NoError(t, err)
+	defer fm.Close()
+	require.Zero(t, fm.(*fileManager).flags&directFlag)
+	page := NewPage(100)
+	page.SetInt32(0, 42)
+	require.NoError(t, fm.Write(NewBlock("file", 1), page))
+	require.NoError(t, fm.Read(NewBlock("file", 1), NewPage(100)))
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"encoding/binary"
 	"errors"
+	"unsafe"
 )
 
 var ErrOutOfBounds = errors.New("out of bounds")
@@ -12,14 +13,31 @@ type Page struct {
 	cursor int
 }
 
-// NewPage returns Page struct
+// DirectIOAlignment is the alignment of the memory, offsets and sizes of direct I/O
+const DirectIOAlignment = 4096
+
+// NewPage returns Page struct.
+// A page whose size is a multiple of DirectIOAlignment is aligned on it, so that it can be used for direct I/O.
 func NewPage(size int) *Page {
 	return &Page{
-		Buf:    make([]byte, size),
+		Buf:    alignedBytes(size),
 		cursor: 0,
 	}
 }
 
+// alignedBytes allocates a zeroed slice, aligned on DirectIOAlignment if the size is a multiple of it
+func alignedBytes(size int) []byte {
+	if size == 0 || size%DirectIOAlignment != 0 {
+		return make([]byte, size)
+	}
+	buf := make([]byte, size+DirectIOAlignment)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % DirectIOAlignment); rem != 0 {
+		off = DirectIOAlignment - rem
+	}
+	return buf[off : off+size : off+size]
+}
+
 // NewPageFromBytes returns Page struct from a byte slice
 func NewPageFromBytes(b []byte) *Page {
 	return &Page{
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// SyncPolicy decides when the file manager forces the written blocks to disk
+type SyncPolicy int
+
+const (
+	// SyncPolicy_LOG syncs a file only when Sync is called, as the log manager does when it flushes the log.
+	// The data files are recovered from the log after a crash.
+	SyncPolicy_LOG SyncPolicy = iota
+	// SyncPolicy_ALWAYS syncs the file after every write
+	SyncPolicy_ALWAYS
+	// SyncPolicy_GROUP makes the Sync calls wait for the next round, which syncs each requested file once.
+	// Concurrent commits then share the cost of a sync.
+	SyncPolicy_GROUP
+)
+
+// GroupSyncIntervalMs is the default time between two rounds of SyncPolicy_GROUP
+const GroupSyncIntervalMs = 5
+
+type FileManagerOptions func(fm *fileManager)
+
+func WithSyncPolicy(policy SyncPolicy) FileManagerOptions {
+	return func(fm *fileManager) {
+		fm.policy = policy
+	}
+}
+
+// WithGroupSyncInterval sets the time between two rounds of SyncPolicy_GROUP
+func WithGroupSyncInterval(ms int) FileManagerOptions {
+	return func(fm *fileManager) {
+		fm.interval = time.Duration(ms) * time.Millisecond
+	}
+}
+
+// WithDSync opens the files with O_DSYNC, so that a write returns once its data is on disk
+func WithDSync() FileManagerOptions {
+	return func(fm *fileManager) {
+		fm.flags |= dsyncFlag
+	}
+}
+
+// WithDirectIO opens the files with O_DIRECT, so that the blocks bypass the OS page cache,
+// which would otherwise hold a second copy of the buffer pool. Direct I/O needs blocks
+// and pages aligned on DirectIOAlignment; the files stay buffered if the block size is not
+// a multiple of it, if the platform lacks O_DIRECT, or if the file system refuses it.
+func WithDirectIO() FileManagerOptions {
+	return func(fm *fileManager) {
+		if directFlag != 0 && fm.blocksize%DirectIOAlignment == 0 {
+			fm.flags |= directFlag
+		}
+	}
+}
+
+// groupSyncer syncs the files requested during an interval together
+type groupSyncer struct {
+	requests chan syncRequest
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type syncRequest struct {
+	filename string
+	result   chan error
+}
+
+func newGroupSyncer(fm *fileManager, interval time.Duration) *groupSyncer {
+	gs := &groupSyncer{
+		requests: make(chan syncRequest),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go gs.run(fm, interval)
+	return gs
+}
+
+func (gs *groupSyncer) run(fm *fileManager, interval time.Duration) {
+	defer close(gs.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pending := []syncRequest{}
+	for {
+		select {
+		case req := <-gs.requests:
+			pending = append(pending, req)
+		case <-ticker.C:
+			gs.round(fm, pending)
+			pending = pending[:0]
+		case <-gs.stop:
+			gs.round(fm, pending)
+			return
+		}
+	}
+}
+
+// round syncs every requested file once and answers the requests
+func (gs *groupSyncer) round(fm *fileManager, pending []syncRequest) {
+	errs := make(map[string]error)
+	for _, req := range pending {
+		if _, found := errs[req.filename]; !found {
+			errs[req.filename] = fm.syncFile(req.filename)
+		}
+		req.result <- errs[req.filename]
+	}
+}
+
+// sync waits for the round which syncs the file
+func (gs *groupSyncer) sync(filename string) error {
+	req := syncRequest{filename: filename, result: make(chan error, 1)}
+	select {
+	case gs.requests <- req:
+		return <-req.result
+	case <-gs.done:
+		return ErrClosed
+	}
+}
+
+// close syncs the files still requested and stops the rounds. Closing again does nothing.
+func (gs *groupSyncer) close() {
+	gs.stopOnce.Do(func() { close(gs.stop) })
+	<-gs.done
+}
@@ -1,12 +1,15 @@
-package main
+package simpledb
 
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"simpledb/log"
 	logrecord "simpledb/log/record"
 	"simpledb/storage"
+	"slices"
 	"sync"
+	"time"
 )
 
 type LockState int
@@ -16,70 +19,278 @@ const (
 	LockState_EXCLUSIVE
 )
 
+const (
+	LockTimeoutMs = 10000 // 10 seconds
+
+	// EndOfFile is the block number locked to guard the size of a file
+	EndOfFile = -1
+)
+
 var (
-	ErrAlreadyLocked = errors.New("block already locked")
+	ErrLockAbort  = errors.New("lock wait timed out")
+	ErrDeadlock   = errors.New("deadlock detected")
+	ErrTxFinished = errors.New("transaction already committed or rolled back")
+	ErrNotTemp    = errors.New("not a temporary file")
 )
 
+// DeadlockError reports the transactions forming a wait-for cycle
+// and the one chosen to be aborted
+type DeadlockError struct {
+	Victim int
+	Cycle  []int
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("deadlock detected: transaction %d aborted, cycle %v", e.Victim, e.Cycle)
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return ErrDeadlock
+}
+
+type lockEntry struct {
+	state   LockState
+	holders map[int]struct{}
+}
+
+func (e *lockEntry) heldBy(txid int) bool {
+	_, found := e.holders[txid]
+	return found
+}
+
+// ConcurrencyManager is the lock table shared by every transaction.
+// A conflicting request waits until the lock is granted or the timeout expires.
+// Waiting transactions are tracked in a wait-for graph so that deadlocks are
+// broken as soon as they form.
 type ConcurrencyManager struct {
-	lockTable map[storage.Block]LockState
+	lockTable map[storage.Block]*lockEntry
+	waitsFor  map[int]map[int]struct{}
+	aborted   map[int]*DeadlockError
 	mu        sync.Mutex
+	cond      *sync.Cond
+	timeout   time.Duration
 }
 
-func (cm *ConcurrencyManager) SLock(block *storage.Block) error {
+type ConcurrencyManagerOptions func(cm *ConcurrencyManager)
+
+func WithLockTimeout(ms int) ConcurrencyManagerOptions {
+	return func(cm *ConcurrencyManager) {
+		cm.timeout = time.Duration(ms) * time.Millisecond
+	}
+}
+
+func NewConcurrencyManager(opts ...ConcurrencyManagerOptions) *ConcurrencyManager {
+	cm := &ConcurrencyManager{
+		lockTable: make(map[storage.Block]*lockEntry),
+		waitsFor:  make(map[int]map[int]struct{}),
+		aborted:   make(map[int]*DeadlockError),
+		timeout:   LockTimeoutMs * time.Millisecond,
+	}
+	cm.cond = sync.NewCond(&cm.mu)
+
+	for _, opt := range opts {
+		opt(cm)
+	}
+
+	return cm
+}
+
+// SLock acquires a shared lock on the block for the transaction
+func (cm *ConcurrencyManager) SLock(txid int, block *storage.Block) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-
-	state, found := cm.lockTable[*block]
-	if found {
-		if state == LockState_EXCLUSIVE {
-			return ErrAlreadyLocked
-		} else {
+	defer cm.forget(txid)
+
+	deadline := time.Now().Add(cm.timeout)
+	for {
+		entry, found := cm.lockTable[*block]
+		if !found {
+			cm.lockTable[*block] = &lockEntry{
+				state:   LockState_SHARED,
+				holders: map[int]struct{}{txid: {}},
+			}
 			return nil
 		}
+		if entry.state == LockState_SHARED {
+			entry.holders[txid] = struct{}{}
+			return nil
+		}
+		if entry.heldBy(txid) {
+			// an exclusive lock implies a shared lock
+			return nil
+		}
+		err := cm.block(txid, entry, deadline)
+		if err != nil {
+			return err
+		}
 	}
-	cm.lockTable[*block] = LockState_SHARED
-	return nil
 }
 
-func (cm *ConcurrencyManager) XLock(block *storage.Block) error {
+// XLock acquires an exclusive lock on the block for the transaction.
+// A shared lock held only by the same transaction is upgraded.
+func (cm *ConcurrencyManager) XLock(txid int, block *storage.Block) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
+	defer cm.forget(txid)
+
+	deadline := time.Now().Add(cm.timeout)
+	for {
+		entry, found := cm.lockTable[*block]
+		if !found {
+			cm.lockTable[*block] = &lockEntry{
+				state:   LockState_EXCLUSIVE,
+				holders: map[int]struct{}{txid: {}},
+			}
+			return nil
+		}
+		if entry.heldBy(txid) && len(entry.holders) == 1 {
+			entry.state = LockState_EXCLUSIVE
+			return nil
+		}
+		err := cm.block(txid, entry, deadline)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// block records that the transaction waits for the holders of the entry and waits.
+// If the new wait closes a cycle, the youngest transaction in the cycle is aborted.
+// cm.mu must be held by the caller.
+func (cm *ConcurrencyManager) block(txid int, entry *lockEntry, deadline time.Time) error {
+	blockers := make(map[int]struct{})
+	for holder := range entry.holders {
+		if holder != txid {
+			blockers[holder] = struct{}{}
+		}
+	}
+	cm.waitsFor[txid] = blockers
+
+	if cycle := cm.findCycle(txid); cycle != nil && !cm.aborting(cycle) {
+		err := &DeadlockError{Victim: slices.Max(cycle), Cycle: cycle}
+		if err.Victim == txid {
+			return err
+		}
+		cm.aborted[err.Victim] = err
+		cm.cond.Broadcast()
+	}
 
-	if _, found := cm.lockTable[*block]; found {
-		return ErrAlreadyLocked
+	if !cm.wait(deadline) {
+		return ErrLockAbort
+	}
+	if err, found := cm.aborted[txid]; found {
+		return err
 	}
-	cm.lockTable[*block] = LockState_EXCLUSIVE
 	return nil
 }
 
-func (cm *ConcurrencyManager) Unlock(block *storage.Block) {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+// findCycle returns the transactions on a wait-for cycle through txid, or nil if there is none
+func (cm *ConcurrencyManager) findCycle(txid int) []int {
+	visited := make(map[int]struct{})
+	path := []int{}
+
+	var visit func(n int) bool
+	visit = func(n int) bool {
+		path = append(path, n)
+		for next := range cm.waitsFor[n] {
+			if next == txid {
+				return true
+			}
+			if _, found := visited[next]; found {
+				continue
+			}
+			visited[next] = struct{}{}
+			if visit(next) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(txid) {
+		return path
+	}
+	return nil
+}
 
-	delete(cm.lockTable, *block)
+// aborting returns true if a member of the cycle has already been chosen as a victim
+func (cm *ConcurrencyManager) aborting(cycle []int) bool {
+	for _, txid := range cycle {
+		if _, found := cm.aborted[txid]; found {
+			return true
+		}
+	}
+	return false
 }
 
-func (cm *ConcurrencyManager) Release() {
-	for block := range cm.lockTable {
-		cm.Unlock(&block)
+// forget removes the transaction from the wait-for graph once it stops waiting
+func (cm *ConcurrencyManager) forget(txid int) {
+	delete(cm.waitsFor, txid)
+	delete(cm.aborted, txid)
+}
+
+// wait blocks until a lock is released or the deadline passes.
+// It returns false if the deadline has already passed.
+// cm.mu must be held by the caller.
+func (cm *ConcurrencyManager) wait(deadline time.Time) bool {
+	remain := time.Until(deadline)
+	if remain <= 0 {
+		return false
 	}
+
+	timer := time.AfterFunc(remain, func() {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+		cm.cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	cm.cond.Wait()
+	return true
 }
 
+// Unlock releases the lock the transaction holds on the block and wakes up the waiters
+func (cm *ConcurrencyManager) Unlock(txid int, block *storage.Block) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	entry, found := cm.lockTable[*block]
+	if !found {
+		return
+	}
+	delete(entry.holders, txid)
+	if len(entry.holders) == 0 {
+		delete(cm.lockTable, *block)
+	}
+	cm.cond.Broadcast()
+}
+
+// Transaction follows strict two-phase locking:
+// every lock it acquires is held until Commit or Rollback.
+// The changes to temporary files are not logged, since the files do not outlive their transaction.
+// A finished transaction, including a deadlock victim rolled back by a failed call,
+// returns ErrTxFinished from every call except Rollback, which does nothing.
 type Transaction struct {
-	id     int
-	lm     log.TxLogger
-	bm     *BufferManager
-	cm     *ConcurrencyManager
-	locked map[storage.Block]struct{}
+	id       int
+	lm       log.TxLogger
+	bm       *BufferManager
+	cm       *ConcurrencyManager
+	locked   map[storage.Block]LockState
+	buffers  map[storage.Block]*Buffer
+	pins     map[storage.Block]int
+	finished bool
 }
 
 func NewTransaction(id int, lm log.TxLogger, cm *ConcurrencyManager, bm *BufferManager) *Transaction {
 	return &Transaction{
-		id:     id,
-		lm:     lm,
-		cm:     cm,
-		bm:     bm,
-		locked: make(map[storage.Block]struct{}),
+		id:      id,
+		lm:      lm,
+		cm:      cm,
+		bm:      bm,
+		locked:  make(map[storage.Block]LockState),
+		buffers: make(map[storage.Block]*Buffer),
+		pins:    make(map[storage.Block]int),
 	}
 }
 
@@ -88,27 +299,30 @@ func (tx *Transaction) Start() error {
 }
 
 func (tx *Transaction) Commit() error {
+	if tx.finished {
+		return ErrTxFinished
+	}
 	err := tx.lm.Commit(tx.id)
 	if err != nil {
 		return err
 	}
-	for block := range tx.locked {
-		tx.cm.Unlock(&block)
-	}
+	tx.finish()
 	return nil
 }
 
+// Rollback undoes every change of the transaction, newest first,
+// and then writes the rollback record. It does nothing if the transaction has finished,
+// since the undo would overwrite the values committed by other transactions since.
 func (tx *Transaction) Rollback() error {
-	err := tx.lm.Rollback(tx.id)
-	if err != nil {
-		return err
+	if tx.finished {
+		return nil
 	}
-
 	itr, err := tx.lm.Iterator()
 	if err != nil {
 		return err
 	}
 
+scan:
 	for itr.HasNext() {
 		data, err := itr.Next()
 		if err != nil {
@@ -117,7 +331,14 @@ func (tx *Transaction) Rollback() error {
 		inst := binary.BigEndian.Uint32(data[:4])
 		switch inst {
 		case logrecord.Instruction_CHECKPOINT:
-			break
+			break scan
+		case logrecord.Instruction_START:
+			record := &logrecord.StartRecord{}
+			record.Read(data)
+
+			if record.TxID == tx.id {
+				break scan
+			}
 		case logrecord.Instruction_SETINT32:
 			record := &logrecord.SetInt32Record{}
 			record.Read(data)
@@ -126,11 +347,9 @@ func (tx *Transaction) Rollback() error {
 				continue
 			}
 
-			buf, err := tx.bm.GetBuf(record.Block())
-			if err != nil {
-				return err
-			}
-			err = buf.Contents.SetInt32(record.Offset, record.OldValue)
+			err = tx.undo(record.Block(), func(page *storage.Page) error {
+				return page.SetInt32(record.Offset, record.OldValue)
+			})
 			if err != nil {
 				return err
 			}
@@ -142,31 +361,207 @@ func (tx *Transaction) Rollback() error {
 				continue
 			}
 
-			buf, err := tx.bm.GetBuf(record.Block())
-			if err != nil {
-				return err
-			}
-			err = buf.Contents.SetString(record.Offset, record.OldValue)
+			err = tx.undo(record.Block(), func(page *storage.Page) error {
+				return page.SetString(record.Offset, record.OldValue)
+			})
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	for block := range tx.locked {
-		tx.cm.Unlock(&block)
+	// the restored values must reach the disk before the rollback record does
+	err = tx.bm.FlushAll(tx.id)
+	if err != nil {
+		return err
+	}
+	err = tx.lm.Rollback(tx.id)
+	if err != nil {
+		return err
+	}
+
+	tx.finish()
+	return nil
+}
+
+// finish releases the locks and the buffers of the transaction once it has ended
+func (tx *Transaction) finish() {
+	tx.release()
+	tx.unpinAll()
+	tx.finished = true
+}
+
+// undo restores a value overwritten by the transaction.
+// The block stays pinned only while it is restored, so a rollback touching more blocks
+// than there are buffers does not run out of them.
+func (tx *Transaction) undo(block *storage.Block, restore func(page *storage.Page) error) error {
+	err := tx.Pin(block)
+	if err != nil {
+		return err
+	}
+	defer tx.Unpin(block)
+
+	buf := tx.buffers[*block]
+	err = restore(buf.Contents)
+	if err != nil {
+		return err
+	}
+	buf.SetModified(tx.id, -1)
+	return nil
+}
+
+// Pin pins the block for the transaction until the matching Unpin or the end of the transaction
+func (tx *Transaction) Pin(block *storage.Block) error {
+	if tx.finished {
+		return ErrTxFinished
+	}
+	if _, found := tx.buffers[*block]; !found {
+		buf, err := tx.bm.Pin(block)
+		if err != nil {
+			return err
+		}
+		tx.buffers[*block] = buf
+	}
+	tx.pins[*block]++
+	return nil
+}
+
+// Unpin releases a pin acquired by Pin
+func (tx *Transaction) Unpin(block *storage.Block) {
+	buf, found := tx.buffers[*block]
+	if !found {
+		return
+	}
+	tx.pins[*block]--
+	if tx.pins[*block] > 0 {
+		return
+	}
+	tx.bm.Unpin(buf)
+	delete(tx.buffers, *block)
+	delete(tx.pins, *block)
+}
+
+func (tx *Transaction) unpinAll() {
+	for _, buf := range tx.buffers {
+		tx.bm.Unpin(buf)
+	}
+	clear(tx.buffers)
+	clear(tx.pins)
+}
+
+// Size returns the number of blocks in the file.
+// It locks the end of the file so that no other transaction can append to it.
+func (tx *Transaction) Size(filename string) (int, error) {
+	err := tx.slock(storage.NewBlock(filename, EndOfFile))
+	if err != nil {
+		return 0, err
+	}
+	return tx.bm.fm.Length(filename)
+}
+
+// Append adds an empty block to the end of the file
+func (tx *Transaction) Append(filename string) (*storage.Block, error) {
+	err := tx.xlock(storage.NewBlock(filename, EndOfFile))
+	if err != nil {
+		return nil, err
+	}
+	return tx.bm.fm.Append(filename)
+}
+
+// Remove deletes a temporary file along with its blocks in the buffer pool.
+// The blocks of the file must have been unpinned.
+func (tx *Transaction) Remove(filename string) error {
+	if tx.finished {
+		return ErrTxFinished
+	}
+	if !storage.IsTempFile(filename) {
+		return fmt.Errorf("%w: %s", ErrNotTemp, filename)
+	}
+	tx.bm.Discard(filename)
+	return tx.bm.fm.Remove(filename)
+}
+
+// BlockSize returns the size of a block in bytes
+func (tx *Transaction) BlockSize() int {
+	return tx.bm.fm.Blocksize()
+}
+
+// AvailableBuffs returns the number of unpinned buffers in the buffer pool
+func (tx *Transaction) AvailableBuffs() int {
+	return tx.bm.Available()
+}
+
+// slock acquires a shared lock unless the transaction already locks the block
+func (tx *Transaction) slock(block *storage.Block) error {
+	if tx.finished {
+		return ErrTxFinished
+	}
+	if _, found := tx.locked[*block]; found {
+		return nil
+	}
+	err := tx.cm.SLock(tx.id, block)
+	if err != nil {
+		return tx.abortOnDeadlock(err)
+	}
+	tx.locked[*block] = LockState_SHARED
+	return nil
+}
+
+// xlock acquires an exclusive lock, upgrading a shared lock held by the transaction
+func (tx *Transaction) xlock(block *storage.Block) error {
+	if tx.finished {
+		return ErrTxFinished
 	}
+	if state, found := tx.locked[*block]; found && state == LockState_EXCLUSIVE {
+		return nil
+	}
+	err := tx.cm.XLock(tx.id, block)
+	if err != nil {
+		return tx.abortOnDeadlock(err)
+	}
+	tx.locked[*block] = LockState_EXCLUSIVE
 	return nil
 }
 
+// release releases every lock held by the transaction
+func (tx *Transaction) release() {
+	for block := range tx.locked {
+		tx.cm.Unlock(tx.id, &block)
+	}
+	clear(tx.locked)
+}
+
+// abortOnDeadlock rolls the transaction back when it was chosen as a deadlock victim
+func (tx *Transaction) abortOnDeadlock(err error) error {
+	if !errors.Is(err, ErrDeadlock) {
+		return err
+	}
+	rerr := tx.Rollback()
+	if rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return err
+}
+
+// buffer returns the buffer holding the block. A block the transaction has not pinned
+// stays pinned until the end of the transaction, so that its buffer is not replaced while in use.
+func (tx *Transaction) buffer(block *storage.Block) (*Buffer, error) {
+	if _, found := tx.buffers[*block]; !found {
+		err := tx.Pin(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tx.buffers[*block], nil
+}
+
 func (tx *Transaction) GetInt32(block *storage.Block, offset int) (int32, error) {
-	tx.cm.SLock(block)
-	defer func() {
-		tx.cm.Unlock(block)
-		delete(tx.locked, *block)
-	}()
+	err := tx.slock(block)
+	if err != nil {
+		return 0, err
+	}
 
-	buf, err := tx.bm.GetBuf(block)
+	buf, err := tx.buffer(block)
 	if err != nil {
 		return 0, err
 	}
@@ -175,13 +570,12 @@ func (tx *Transaction) GetInt32(block *storage.Block, offset int) (int32, error)
 }
 
 func (tx *Transaction) GetString(block *storage.Block, offset int) (string, error) {
-	tx.cm.SLock(block)
-	defer func() {
-		tx.cm.Unlock(block)
-		delete(tx.locked, *block)
-	}()
+	err := tx.slock(block)
+	if err != nil {
+		return "", err
+	}
 
-	buf, err := tx.bm.GetBuf(block)
+	buf, err := tx.buffer(block)
 	if err != nil {
 		return "", err
 	}
@@ -189,17 +583,14 @@ func (tx *Transaction) GetString(block *storage.Block, offset int) (string, erro
 }
 
 func (tx *Transaction) SetInt32(block *storage.Block, offset int, n int32) error {
-	tx.cm.XLock(block)
+	err := tx.xlock(block)
+	if err != nil {
+		return err
+	}
 
-	tx.locked[*block] = struct{}{}
-	buf, err := tx.bm.GetBuf(block)
+	buf, err := tx.buffer(block)
 	if err != nil {
-		if errors.Is(err, ErrBlockNotFound) {
-			buf, err = tx.bm.Pin(block)
-		}
-		if err != nil {
-			return err
-		}
+		return err
 	}
 
 	content := buf.Contents
@@ -208,9 +599,12 @@ func (tx *Transaction) SetInt32(block *storage.Block, offset int, n int32) error
 		return err
 	}
 
-	lsn, err := tx.lm.SetInt32(tx.id, block, offset, oldval, n)
-	if err != nil {
-		return err
+	lsn := -1
+	if !storage.IsTempFile(block.Filename) {
+		lsn, err = tx.lm.SetInt32(tx.id, block, offset, oldval, n)
+		if err != nil {
+			return err
+		}
 	}
 	err = content.SetInt32(offset, n)
 	if err != nil {
@@ -222,17 +616,14 @@ func (tx *Transaction) SetInt32(block *storage.Block, offset int, n int32) error
 }
 
 func (tx *Transaction) SetString(block *storage.Block, offset int, v string) error {
-	tx.cm.XLock(block)
+	err := tx.xlock(block)
+	if err != nil {
+		return err
+	}
 
-	tx.locked[*block] = struct{}{}
-	buf, err := tx.bm.GetBuf(block)
+	buf, err := tx.buffer(block)
 	if err != nil {
-		if errors.Is(err, ErrBlockNotFound) {
-			buf, err = tx.bm.Pin(block)
-		}
-		if err != nil {
-			return err
-		}
+		return err
 	}
 
 	content := buf.Contents
@@ -241,9 +632,12 @@ func (tx *Transaction) SetString(block *storage.Block, offset int, v string) err
 		return err
 	}
 
-	lsn, err := tx.lm.SetString(tx.id, block, offset, oldval, v)
-	if err != nil {
-		return err
+	lsn := -1
+	if !storage.IsTempFile(block.Filename) {
+		lsn, err = tx.lm.SetString(tx.id, block, offset, oldval, v)
+		if err != nil {
+			return err
+		}
 	}
 	err = content.SetString(offset, v)
 	if err != nil {
@@ -1,9 +1,12 @@
-package main
+package simpledb
 
 import (
+	"fmt"
 	"simpledb/log"
+	"simpledb/record"
 	"simpledb/storage"
 	"testing"
+	"time"
 
 	mock "github.com/stretchr/testify/mock"
 
@@ -13,45 +16,56 @@ import (
 func TestConcurrencyMananger_SLock(t *testing.T) {
 	testcases := []struct {
 		name   string
-		table  map[storage.Block]LockState
-		expect map[storage.Block]LockState
+		table  map[storage.Block]*lockEntry
+		expect map[storage.Block]*lockEntry
 		err    error
 	}{
 		{
 			name:  "no one locks",
-			table: map[storage.Block]LockState{},
-			expect: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_SHARED,
+			table: map[storage.Block]*lockEntry{},
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_SHARED, holders: map[int]struct{}{1: {}}},
 			},
 			err: nil,
 		},
 		{
 			name: "block already shared locked",
-			table: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_SHARED,
+			table: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_SHARED, holders: map[int]struct{}{2: {}}},
 			},
-			expect: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_SHARED,
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_SHARED, holders: map[int]struct{}{1: {}, 2: {}}},
+			},
+			err: nil,
+		},
+		{
+			name: "block already exclusive locked by itself",
+			table: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{1: {}}},
+			},
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{1: {}}},
 			},
 			err: nil,
 		},
 		{
 			name: "block already exclusive locked",
-			table: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_EXCLUSIVE,
+			table: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{2: {}}},
 			},
-			expect: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_EXCLUSIVE,
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{2: {}}},
 			},
-			err: ErrAlreadyLocked,
+			err: ErrLockAbort,
 		},
 	}
 
 	for _, tt := range testcases {
 		t.Run(tt.name, func(t *testing.T) {
-			cm := &ConcurrencyManager{lockTable: tt.table}
+			cm := NewConcurrencyManager(WithLockTimeout(50))
+			cm.lockTable = tt.table
 			blk := &storage.Block{Filename: "test.db", Num: 0}
-			err := cm.SLock(blk)
+			err := cm.SLock(1, blk)
 			if tt.err != nil {
 				require.ErrorIs(t, err, tt.err)
 			} else {
@@ -65,45 +79,56 @@ func TestConcurrencyMananger_SLock(t *testing.T) {
 func TestConcurrencyMananger_XLock(t *testing.T) {
 	testcases := []struct {
 		name   string
-		table  map[storage.Block]LockState
-		expect map[storage.Block]LockState
+		table  map[storage.Block]*lockEntry
+		expect map[storage.Block]*lockEntry
 		err    error
 	}{
 		{
 			name:  "no one locks",
-			table: map[storage.Block]LockState{},
-			expect: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_EXCLUSIVE,
+			table: map[storage.Block]*lockEntry{},
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{1: {}}},
+			},
+			err: nil,
+		},
+		{
+			name: "upgrade own shared lock",
+			table: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_SHARED, holders: map[int]struct{}{1: {}}},
+			},
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{1: {}}},
 			},
 			err: nil,
 		},
 		{
 			name: "block already shared locked",
-			table: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_SHARED,
+			table: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_SHARED, holders: map[int]struct{}{1: {}, 2: {}}},
 			},
-			expect: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_SHARED,
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_SHARED, holders: map[int]struct{}{1: {}, 2: {}}},
 			},
-			err: ErrAlreadyLocked,
+			err: ErrLockAbort,
 		},
 		{
 			name: "block already exclusive locked",
-			table: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_EXCLUSIVE,
+			table: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{2: {}}},
 			},
-			expect: map[storage.Block]LockState{
-				{Filename: "test.db", Num: 0}: LockState_EXCLUSIVE,
+			expect: map[storage.Block]*lockEntry{
+				{Filename: "test.db", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{2: {}}},
 			},
-			err: ErrAlreadyLocked,
+			err: ErrLockAbort,
 		},
 	}
 
 	for _, tt := range testcases {
 		t.Run(tt.name, func(t *testing.T) {
-			cm := &ConcurrencyManager{lockTable: tt.table}
+			cm := NewConcurrencyManager(WithLockTimeout(50))
+			cm.lockTable = tt.table
 			blk := &storage.Block{Filename: "test.db", Num: 0}
-			err := cm.XLock(blk)
+			err := cm.XLock(1, blk)
 			if tt.err != nil {
 				require.ErrorIs(t, err, tt.err)
 			} else {
@@ -114,6 +139,82 @@ func TestConcurrencyMananger_XLock(t *testing.T) {
 	}
 }
 
+func TestConcurrencyMananger_Wait(t *testing.T) {
+	cm := NewConcurrencyManager(WithLockTimeout(1000))
+	blk := &storage.Block{Filename: "test.db", Num: 0}
+	require.NoError(t, cm.XLock(1, blk))
+
+	done := make(chan error)
+	go func() {
+		done <- cm.SLock(2, blk)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("shared lock granted while exclusive lock is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cm.Unlock(1, blk)
+	require.NoError(t, <-done)
+	require.Equal(t, map[storage.Block]*lockEntry{
+		*blk: {state: LockState_SHARED, holders: map[int]struct{}{2: {}}},
+	}, cm.lockTable)
+}
+
+func TestConcurrencyMananger_Deadlock(t *testing.T) {
+	blkA := &storage.Block{Filename: "test.db", Num: 0}
+	blkB := &storage.Block{Filename: "test.db", Num: 1}
+
+	t.Run("requester is the youngest", func(t *testing.T) {
+		cm := NewConcurrencyManager(WithLockTimeout(1000))
+		require.NoError(t, cm.XLock(1, blkA))
+		require.NoError(t, cm.XLock(2, blkB))
+
+		done := make(chan error)
+		go func() {
+			done <- cm.XLock(1, blkB)
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		err := cm.XLock(2, blkA)
+		var derr *DeadlockError
+		require.ErrorAs(t, err, &derr)
+		require.ErrorIs(t, err, ErrDeadlock)
+		require.Equal(t, 2, derr.Victim)
+		require.ElementsMatch(t, []int{1, 2}, derr.Cycle)
+
+		cm.Unlock(2, blkB)
+		require.NoError(t, <-done)
+	})
+
+	t.Run("waiting transaction is the youngest", func(t *testing.T) {
+		cm := NewConcurrencyManager(WithLockTimeout(1000))
+		require.NoError(t, cm.XLock(1, blkA))
+		require.NoError(t, cm.XLock(2, blkB))
+
+		done := make(chan error)
+		go func() {
+			err := cm.XLock(2, blkA)
+			cm.Unlock(2, blkB)
+			done <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		start := time.Now()
+		require.NoError(t, cm.XLock(1, blkB))
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+
+		err := <-done
+		var derr *DeadlockError
+		require.ErrorAs(t, err, &derr)
+		require.Equal(t, 2, derr.Victim)
+		require.ElementsMatch(t, []int{1, 2}, derr.Cycle)
+		require.Empty(t, cm.waitsFor)
+		require.Empty(t, cm.aborted)
+	})
+}
+
 type MockLogManager struct {
 	mock.Mock
 }
@@ -155,7 +256,7 @@ func TestTransaction_Start(t *testing.T) {
 	mocklog.On("Start", 1).Return(nil).Once()
 
 	require.NoError(t, err)
-	cm := &ConcurrencyManager{lockTable: map[storage.Block]LockState{}}
+	cm := NewConcurrencyManager()
 	bm := NewBufferManager(fm, lm, 2, WithFinalizeTime(100))
 
 	tx := NewTransaction(1, mocklog, cm, bm)
@@ -170,25 +271,29 @@ func TestTransaction_Commit(t *testing.T) {
 	mocklog.On("Commit", 1).Return(nil).Once()
 
 	require.NoError(t, err)
-	cm := &ConcurrencyManager{lockTable: map[storage.Block]LockState{}}
+	cm := NewConcurrencyManager()
 	bm := NewBufferManager(fm, lm, 2, WithFinalizeTime(100))
 
 	tx := NewTransaction(1, mocklog, cm, bm)
 	err = tx.Commit()
 	require.NoError(t, err)
 
-	require.Equal(t, map[storage.Block]LockState{}, cm.lockTable)
+	require.Equal(t, map[storage.Block]*lockEntry{}, cm.lockTable)
 	require.Empty(t, tx.locked)
 }
 
 func TestTransaction_Rollback(t *testing.T) {
 	fm := storage.NewNopFileManager(30, []byte{})
 	lm, err := log.NewLogManager(fm, "test.db")
+	// an empty log block
+	itr, err := log.NewLogIterator(storage.NewNopFileManager(8, []byte{0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00}), storage.NewBlock("test.db", 0))
+	require.NoError(t, err)
 	mocklog := &MockLogManager{}
+	mocklog.On("Iterator").Return(itr, nil).Once()
 	mocklog.On("Rollback", 1).Return(nil).Once()
 
 	require.NoError(t, err)
-	cm := &ConcurrencyManager{lockTable: map[storage.Block]LockState{}}
+	cm := NewConcurrencyManager()
 	bm := NewBufferManager(fm, lm, 2, WithFinalizeTime(100))
 
 	tx := NewTransaction(1, mocklog, cm, bm)
@@ -206,7 +311,7 @@ func TestTransaction_SetInt32(t *testing.T) {
 	mocklog.On("SetInt32", 1, block, 0, int32(0), int32(1)).Return(0, nil).Once()
 
 	require.NoError(t, err)
-	cm := &ConcurrencyManager{lockTable: map[storage.Block]LockState{}}
+	cm := NewConcurrencyManager()
 	bm := NewBufferManager(fm, lm, 2, WithFinalizeTime(100))
 
 	tx := NewTransaction(1, mocklog, cm, bm)
@@ -216,11 +321,11 @@ func TestTransaction_SetInt32(t *testing.T) {
 	err = tx.SetInt32(block, 0, 1)
 	require.NoError(t, err)
 
+	require.Equal(t, map[storage.Block]*lockEntry{
+		{Filename: "test", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{1: {}}},
+	}, cm.lockTable)
 	require.Equal(t, map[storage.Block]LockState{
 		{Filename: "test", Num: 0}: LockState_EXCLUSIVE,
-	}, cm.lockTable)
-	require.Equal(t, map[storage.Block]struct{}{
-		{Filename: "test", Num: 0}: {},
 	}, tx.locked)
 }
 
@@ -233,7 +338,7 @@ func TestTransaction_SetString(t *testing.T) {
 	mocklog.On("SetString", 1, block, 0, "", "fuga").Return(0, nil).Once()
 
 	require.NoError(t, err)
-	cm := &ConcurrencyManager{lockTable: map[storage.Block]LockState{}}
+	cm := NewConcurrencyManager()
 	bm := NewBufferManager(fm, lm, 2, WithFinalizeTime(100))
 
 	tx := NewTransaction(1, mocklog, cm, bm)
@@ -243,10 +348,169 @@ func TestTransaction_SetString(t *testing.T) {
 	err = tx.SetString(block, 0, "fuga")
 	require.NoError(t, err)
 
+	require.Equal(t, map[storage.Block]*lockEntry{
+		{Filename: "test", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{1: {}}},
+	}, cm.lockTable)
 	require.Equal(t, map[storage.Block]LockState{
 		{Filename: "test", Num: 0}: LockState_EXCLUSIVE,
-	}, cm.lockTable)
-	require.Equal(t, map[storage.Block]struct{}{
-		{Filename: "test", Num: 0}: {},
 	}, tx.locked)
 }
+
+func TestTransaction_StrictTwoPhaseLocking(t *testing.T) {
+	fm := storage.NewNopFileManager(30, []byte{})
+	lm, err := log.NewLogManager(fm, "test.db")
+	require.NoError(t, err)
+	block := storage.NewBlock("test", 0)
+	mocklog := &MockLogManager{}
+	mocklog.On("Commit", 1).Return(nil).Once()
+	mocklog.On("SetInt32", 2, block, 0, int32(0), int32(1)).Return(0, nil).Once()
+
+	cm := NewConcurrencyManager(WithLockTimeout(50))
+	bm := NewBufferManager(fm, lm, 2, WithFinalizeTime(100))
+	tx1 := NewTransaction(1, mocklog, cm, bm)
+	tx2 := NewTransaction(2, mocklog, cm, bm)
+
+	_, err = tx1.GetInt32(block, 0)
+	require.NoError(t, err)
+	require.Equal(t, map[storage.Block]LockState{
+		{Filename: "test", Num: 0}: LockState_SHARED,
+	}, tx1.locked)
+
+	// the shared lock is kept after the read
+	err = tx2.SetInt32(block, 0, 1)
+	require.ErrorIs(t, err, ErrLockAbort)
+	require.Empty(t, tx2.locked)
+
+	// commit releases only the locks of the committing transaction
+	require.NoError(t, tx1.Commit())
+	require.Empty(t, tx1.locked)
+	require.Empty(t, cm.lockTable)
+
+	require.NoError(t, tx2.SetInt32(block, 0, 1))
+	require.Equal(t, map[storage.Block]*lockEntry{
+		{Filename: "test", Num: 0}: {state: LockState_EXCLUSIVE, holders: map[int]struct{}{2: {}}},
+	}, cm.lockTable)
+}
+
+func TestTransaction_Pin(t *testing.T) {
+	fm := storage.NewNopFileManager(30, []byte{})
+	lm, err := log.NewLogManager(fm, "test.db")
+	require.NoError(t, err)
+	block := storage.NewBlock("test", 0)
+	mocklog := &MockLogManager{}
+	mocklog.On("Commit", 1).Return(nil).Once()
+
+	bm := NewBufferManager(fm, lm, 2, WithFinalizeTime(100))
+	tx := NewTransaction(1, mocklog, NewConcurrencyManager(), bm)
+
+	require.NoError(t, tx.Pin(block))
+	require.NoError(t, tx.Pin(block))
+	buf := tx.buffers[*block]
+	require.True(t, buf.IsPinned())
+
+	tx.Unpin(block)
+	require.True(t, buf.IsPinned())
+	tx.Unpin(block)
+	require.False(t, buf.IsPinned())
+
+	require.NoError(t, tx.Pin(block))
+	require.NoError(t, tx.Commit())
+	require.False(t, buf.IsPinned())
+	require.Empty(t, tx.buffers)
+}
+
+func TestTransaction_TableScan(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDB(dir, 100, 3)
+	require.NoError(t, err)
+
+	schema := record.NewSchema()
+	schema.AddIntField("A")
+	schema.AddStringField("B", 9)
+	layout := record.NewLayout(schema)
+	tblname := "T"
+
+	tx1, err := db.NewTransaction()
+	require.NoError(t, err)
+	ts, err := record.NewTableScan(tx1, tblname, layout)
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		require.NoError(t, ts.Insert())
+		require.NoError(t, ts.SetInt("A", int32(i)))
+		require.NoError(t, ts.SetString("B", fmt.Sprintf("rec%d", i)))
+	}
+	ts.Close()
+	require.NoError(t, tx1.Commit())
+
+	tx2, err := db.NewTransaction()
+	require.NoError(t, err)
+	ts, err = record.NewTableScan(tx2, tblname, layout)
+	require.NoError(t, err)
+	for i := 0; ; i++ {
+		ok, err := ts.Next()
+		require.NoError(t, err)
+		if !ok {
+			require.Equal(t, 20, i)
+			break
+		}
+		n, err := ts.GetInt("A")
+		require.NoError(t, err)
+		require.Equal(t, int32(i), n)
+		s, err := ts.GetString("B")
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("rec%d", i), s)
+	}
+	ts.Close()
+	require.NoError(t, tx2.Commit())
+}
+
+func TestTransaction_finished(t *testing.T) {
+	db, err := NewDB(t.TempDir(), 400, 8)
+	require.NoError(t, err)
+	defer db.Close()
+
+	setup, err := db.NewTransaction()
+	require.NoError(t, err)
+	blkA, err := setup.Append("test")
+	require.NoError(t, err)
+	blkB, err := setup.Append("test")
+	require.NoError(t, err)
+	require.NoError(t, setup.Commit())
+
+	// a deadlock rolls back the younger transaction
+	tx1, err := db.NewTransaction()
+	require.NoError(t, err)
+	tx2, err := db.NewTransaction()
+	require.NoError(t, err)
+	require.NoError(t, tx1.SetInt32(blkA, 0, 1))
+	require.NoError(t, tx2.SetInt32(blkB, 0, 2))
+	done := make(chan error)
+	go func() {
+		err := tx1.SetInt32(blkB, 0, 1)
+		if err == nil {
+			err = tx1.Commit()
+		}
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.ErrorIs(t, tx2.SetInt32(blkA, 0, 2), ErrDeadlock)
+	require.NoError(t, <-done)
+
+	// the victim neither undoes its changes again nor runs any further
+	require.NoError(t, tx2.Rollback())
+	require.ErrorIs(t, tx2.SetInt32(blkA, 0, 2), ErrTxFinished)
+	_, err = tx2.GetInt32(blkA, 0)
+	require.ErrorIs(t, err, ErrTxFinished)
+	require.ErrorIs(t, tx2.Pin(blkA), ErrTxFinished)
+	require.ErrorIs(t, tx2.Commit(), ErrTxFinished)
+	require.ErrorIs(t, tx1.Commit(), ErrTxFinished)
+
+	tx3, err := db.NewTransaction()
+	require.NoError(t, err)
+	for _, block := range []*storage.Block{blkA, blkB} {
+		n, err := tx3.GetInt32(block, 0)
+		require.NoError(t, err)
+		require.Equal(t, int32(1), n)
+	}
+	require.NoError(t, tx3.Commit())
+}
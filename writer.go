@@ -0,0 +1,146 @@
+package simpledb
+
+import (
+	"errors"
+	"log/slog"
+	"simpledb/storage"
+	"sync"
+	"time"
+)
+
+const (
+	// WriterIntervalMs is the default time between two rounds of the background writer
+	WriterIntervalMs = 100
+	// WriterMaxPages is the default number of buffers the background writer writes in a round
+	WriterMaxPages = 32
+)
+
+// WithBackgroundWriter starts a goroutine which writes up to maxPages modified, unpinned buffers
+// every interval, so that replacing a buffer seldom has to wait for a write.
+// Close stops the goroutine.
+func WithBackgroundWriter(ms, maxPages int) BufferManagerOptions {
+	return func(bm *BufferManager) {
+		bm.writerInterval = time.Duration(ms) * time.Millisecond
+		bm.writerMaxPages = maxPages
+	}
+}
+
+// backgroundWriter cleans the buffers ahead of their replacement
+type backgroundWriter struct {
+	maxPages int
+	// hand is the frame the next round starts from, so that the rounds cover the whole pool
+	hand int
+	// page holds the copy of the buffer being written
+	page *storage.Page
+	// err is the error of the last round
+	err      error
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newBackgroundWriter(bm *BufferManager, interval time.Duration, maxPages int) *backgroundWriter {
+	w := &backgroundWriter{
+		maxPages: maxPages,
+		page:     storage.NewPage(bm.fm.Blocksize()),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run(bm, interval)
+	return w
+}
+
+func (w *backgroundWriter) run(bm *BufferManager, interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.err = w.round(bm)
+			if w.err != nil {
+				slog.Error("backgroundWriter.run: round failed", slog.Any("err", w.err))
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// round writes up to maxPages modified, unpinned buffers. A buffer which cannot be written
+// stays modified, so that the next round or its replacement tries again.
+func (w *backgroundWriter) round(bm *BufferManager) error {
+	var errs []error
+	for _, buf := range bm.dirtyBuffers(&w.hand, w.maxPages) {
+		err := bm.writeBack(buf, w.page)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dirtyBuffers returns up to limit modified, unpinned buffers, sweeping the pool from the hand
+func (bm *BufferManager) dirtyBuffers(hand *int, limit int) []*Buffer {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bufs := []*Buffer{}
+	for range bm.count {
+		buf := bm.pool[*hand]
+		*hand = (*hand + 1) % bm.count
+		if buf.block != nil && !buf.IsPinned() && buf.ModifyingTx() >= 0 {
+			bufs = append(bufs, buf)
+			if len(bufs) == limit {
+				break
+			}
+		}
+	}
+	return bufs
+}
+
+// writeBack writes a modified, unpinned buffer without holding up the other pins.
+// The contents are copied while no transaction can pin the buffer, and the buffer stays locked
+// until the copy is written, so that it neither is replaced nor marked modified in the meantime.
+func (bm *BufferManager) writeBack(buf *Buffer, page *storage.Page) error {
+	bm.mu.Lock()
+	if buf.IsPinned() {
+		bm.mu.Unlock()
+		return nil
+	}
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if buf.txnum < 0 {
+		bm.mu.Unlock()
+		return nil
+	}
+	block, lsn := buf.block, buf.lsn
+	copy(page.Buf, buf.Contents.Buf)
+	bm.mu.Unlock()
+
+	// write-ahead logging: the log records of the modifications reach the disk first
+	err := bm.lm.Flush(lsn)
+	if err != nil {
+		return err
+	}
+	err = bm.fm.Write(block, page)
+	if err != nil {
+		return err
+	}
+	buf.txnum = -1
+	return nil
+}
+
+// close stops the rounds and returns the error of the last one. Closing again does nothing.
+func (w *backgroundWriter) close() error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+	return w.err
+}
+
+// Close stops the background writer and returns the error of its last round
+func (bm *BufferManager) Close() error {
+	if bm.writer == nil {
+		return nil
+	}
+	return bm.writer.close()
+}
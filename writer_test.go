@@ -0,0 +1,107 @@
+package simpledb
+
+import (
+	"simpledb/log"
+	"simpledb/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// modify sets an int32 in the buffer as transaction 1 would and returns the LSN of its log record
+func modify(t *testing.T, lm *log.LogManager, buf *Buffer, n int32) int {
+	t.Helper()
+	lsn, err := lm.SetInt32(1, buf.Block(), 80, 0, n)
+	require.NoError(t, err)
+	require.NoError(t, buf.Contents.SetInt32(80, n))
+	buf.SetModified(1, lsn)
+	return lsn
+}
+
+// onDisk returns the int32 of the block written by modify
+func onDisk(t *testing.T, fm storage.FileManager, block *storage.Block) int32 {
+	t.Helper()
+	page := storage.NewPage(fm.Blocksize())
+	require.NoError(t, fm.Read(block, page))
+	n, err := page.GetInt32(80)
+	require.NoError(t, err)
+	return n
+}
+
+func TestBackgroundWriter_round(t *testing.T) {
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	defer fm.Close()
+	lm, err := log.NewLogManager(fm, "test.log")
+	require.NoError(t, err)
+	bm := NewBufferManager(fm, lm, 3)
+	blocks := []*storage.Block{
+		storage.NewBlock("writertest", 0),
+		storage.NewBlock("writertest", 1),
+		storage.NewBlock("writertest", 2),
+	}
+	bufs := make([]*Buffer, len(blocks))
+	for i, block := range blocks {
+		bufs[i], err = bm.Pin(block)
+		require.NoError(t, err)
+	}
+	lsn := modify(t, lm, bufs[0], 10)
+	modify(t, lm, bufs[1], 11)
+	modify(t, lm, bufs[2], 12)
+	bm.Unpin(bufs[0])
+	bm.Unpin(bufs[2])
+
+	w := &backgroundWriter{maxPages: 1, page: storage.NewPage(fm.Blocksize())}
+
+	// a round writes at most maxPages buffers, after their log records
+	require.NoError(t, w.round(bm))
+	require.Equal(t, int32(10), onDisk(t, fm, blocks[0]))
+	require.Equal(t, -1, bufs[0].ModifyingTx())
+	require.GreaterOrEqual(t, lm.SavedLSN(), lsn)
+	require.Equal(t, int32(0), onDisk(t, fm, blocks[2]))
+
+	// pinned buffers are left alone
+	require.NoError(t, w.round(bm))
+	require.Equal(t, int32(0), onDisk(t, fm, blocks[1]))
+	require.Equal(t, 1, bufs[1].ModifyingTx())
+	require.Equal(t, int32(12), onDisk(t, fm, blocks[2]))
+
+	bm.Unpin(bufs[1])
+	require.NoError(t, w.round(bm))
+	require.Equal(t, int32(11), onDisk(t, fm, blocks[1]))
+	for _, buf := range bufs {
+		require.Equal(t, -1, buf.ModifyingTx())
+	}
+	require.Equal(t, 3, bm.Available())
+}
+
+func TestBufferManager_Close(t *testing.T) {
+	fm, err := storage.NewFileManager(t.TempDir(), 400)
+	require.NoError(t, err)
+	defer fm.Close()
+	lm, err := log.NewLogManager(fm, "test.log")
+	require.NoError(t, err)
+	bm := NewBufferManager(fm, lm, 2, WithBackgroundWriter(5, 8))
+	block := storage.NewBlock("writertest", 0)
+
+	buf, err := bm.Pin(block)
+	require.NoError(t, err)
+	modify(t, lm, buf, 42)
+	bm.Unpin(buf)
+
+	require.Eventually(t, func() bool { return buf.ModifyingTx() < 0 }, time.Second, time.Millisecond)
+	require.Equal(t, int32(42), onDisk(t, fm, block))
+	require.NoError(t, bm.Close())
+	// closing again does nothing
+	require.NoError(t, bm.Close())
+}
+
+func TestSimpleDB_closeTwice(t *testing.T) {
+	db, err := NewDB(t.TempDir(), 400, 8,
+		WithFileManagerOptions(storage.WithSyncPolicy(storage.SyncPolicy_GROUP)),
+		WithBufferManagerOptions(WithBackgroundWriter(WriterIntervalMs, WriterMaxPages)))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Close())
+}